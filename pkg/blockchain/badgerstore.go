@@ -0,0 +1,146 @@
+package blockchain
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var tipKey = []byte("lh")
+
+// BadgerStore is a ChainStore in a Badger database on disk.
+type BadgerStore struct {
+	db *badger.DB
+}
+
+func NewBadgerStore(path string) (*BadgerStore, error) {
+	db, err := badger.Open(badger.DefaultOptions(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return &BadgerStore{db: db}, nil
+}
+
+// OpenDefaultStore opens the Badger store in the data directory of the
+// current network.
+func OpenDefaultStore() (*BadgerStore, error) {
+	return NewBadgerStore(dbPath)
+}
+
+func (s *BadgerStore) GetBlock(hash []byte) (*Block, error) {
+	data, err := s.get(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return Deserialize(data), nil
+}
+
+func (s *BadgerStore) Tip() ([]byte, error) {
+	return s.get(tipKey)
+}
+
+func (s *BadgerStore) GetIndex(key []byte) ([]byte, error) {
+	return s.get(key)
+}
+
+func (s *BadgerStore) ScanIndex(prefix []byte, fn func(key, value []byte) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			err = fn(it.Item().KeyCopy(nil), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *BadgerStore) Update(fn func(batch StoreBatch) error) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return fn(badgerBatch{txn: txn})
+	})
+}
+
+// CollectGarbage rewrites value log files that are mostly deleted data, so
+// that pruned blocks free their disk space.
+func (s *BadgerStore) CollectGarbage() error {
+	for {
+		err := s.db.RunValueLogGC(0.5)
+		if errors.Is(err, badger.ErrNoRewrite) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *BadgerStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *BadgerStore) get(key []byte) ([]byte, error) {
+	var value []byte
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		value, err = getValue(txn, key)
+		return err
+	})
+
+	return value, err
+}
+
+type badgerBatch struct {
+	txn *badger.Txn
+}
+
+func (b badgerBatch) PutBlock(block *Block) error {
+	return b.txn.Set(block.Hash, block.Serialize())
+}
+
+func (b badgerBatch) DeleteBlock(hash []byte) error {
+	return b.txn.Delete(hash)
+}
+
+func (b badgerBatch) SetTip(hash []byte) error {
+	return b.txn.Set(tipKey, hash)
+}
+
+func (b badgerBatch) GetIndex(key []byte) ([]byte, error) {
+	return getValue(b.txn, key)
+}
+
+func (b badgerBatch) PutIndex(key, value []byte) error {
+	return b.txn.Set(key, value)
+}
+
+func (b badgerBatch) DeleteIndex(key []byte) error {
+	return b.txn.Delete(key)
+}
+
+func getValue(txn *badger.Txn, key []byte) ([]byte, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return item.ValueCopy(nil)
+}
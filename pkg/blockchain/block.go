@@ -6,22 +6,31 @@ import (
 	"encoding/gob"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/zivlakmilos/go-blockchain/pkg/utils"
 )
 
 type Block struct {
+	Timestamp    int64
 	Hash         []byte
 	Transactions []*Transaction
 	PrevHash     []byte
 	Nonce        int
+	Height       int
 }
 
-func NewBlock(txs []*Transaction, prevHash []byte) *Block {
+func NewBlock(txs []*Transaction, prevHash []byte, height int) *Block {
+	return newBlockAt(txs, prevHash, height, time.Now().Unix())
+}
+
+func newBlockAt(txs []*Transaction, prevHash []byte, height int, timestamp int64) *Block {
 	b := &Block{
+		Timestamp:    timestamp,
 		Hash:         []byte{},
 		Transactions: txs,
 		PrevHash:     prevHash,
+		Height:       height,
 	}
 
 	p := NewProofOfWork(b)
@@ -34,7 +43,7 @@ func NewBlock(txs []*Transaction, prevHash []byte) *Block {
 }
 
 func Genesis(coinbase *Transaction) *Block {
-	return NewBlock([]*Transaction{coinbase}, []byte{})
+	return NewBlock([]*Transaction{coinbase}, []byte{}, 0)
 }
 
 func (b *Block) HashTransactions() []byte {
@@ -52,6 +61,7 @@ func (b *Block) HashTransactions() []byte {
 func (b *Block) String() string {
 	var builder strings.Builder
 
+	builder.WriteString(fmt.Sprintf("=== Block %x (height %d, time %d)\n", b.Hash, b.Height, b.Timestamp))
 	for _, tx := range b.Transactions {
 		builder.WriteString(fmt.Sprintf("%v\n", tx))
 	}
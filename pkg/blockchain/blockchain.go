@@ -2,295 +2,351 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/ecdsa"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"runtime"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/zivlakmilos/go-blockchain/pkg/utils"
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
 )
 
-const (
-	dbPath      = "/tmp/blocks"
-	dbFile      = "/tmp/blocks/MANIFEST"
-	genesisData = "First Transaction from Genesis"
+const genesisData = "First Transaction from Genesis"
+
+var ErrTxNotFound = errors.New("transaction does not exist")
+
+var (
+	dbPath = utils.DataPath("blocks")
+	dbFile = filepath.Join(dbPath, "MANIFEST")
 )
 
 type BlockChain struct {
 	LastHash []byte
-	Database *badger.DB
+	Store    ChainStore
+	events   *EventBus
 }
 
-func NewBlockChain(address string) *BlockChain {
-	if dbExists() {
-		fmt.Printf("Blockchain already exists")
-		runtime.Goexit()
+// NewBlockChain stores a genesis block paying address in an empty store.
+func NewBlockChain(store ChainStore, address string) (*BlockChain, error) {
+	if _, err := store.Tip(); err == nil {
+		return nil, fmt.Errorf("blockchain already exists")
 	}
 
-	var lastHash []byte
+	cbtx := CoinbaseTx(address, genesisData)
+	genesis := Genesis(cbtx)
+	log.Printf("Genesis proved")
 
-	opts := badger.DefaultOptions(dbPath)
+	err := storeGenesis(store, genesis)
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := badger.Open(opts)
-	utils.HandleError(err)
+	return &BlockChain{
+		LastHash: genesis.Hash,
+		Store:    store,
+		events:   NewEventBus(),
+	}, nil
+}
 
-	err = db.Update(func(txn *badger.Txn) error {
-		if _, err := txn.Get([]byte("lh")); err == badger.ErrKeyNotFound {
-			cbtx := CoinbaseTx(address, genesisData)
-			genesis := Genesis(cbtx)
-			log.Printf("Genesis proved")
-			err = txn.Set(genesis.Hash, genesis.Serialize())
-			utils.HandleError(err)
+func storeGenesis(store ChainStore, genesis *Block) error {
+	return store.Update(func(batch StoreBatch) error {
+		err := batch.PutBlock(genesis)
+		if err != nil {
+			return err
+		}
 
-			err = txn.Set([]byte("lh"), genesis.Hash)
-			lastHash = genesis.Hash
+		err = indexBlock(batch, genesis)
+		if err != nil {
+			return err
+		}
 
+		err = updateUTXOSet(batch, genesis)
+		if err != nil {
 			return err
 		}
 
-		item, err := txn.Get([]byte("lh"))
-		utils.HandleError(err)
-		lastHash, err = item.ValueCopy(lastHash)
-		return err
+		return batch.SetTip(genesis.Hash)
 	})
-	utils.HandleError(err)
+}
 
-	return &BlockChain{
+// OpenBlockChain continues the chain already in store. The header index and
+// the UTXO set are built first when the store does not have them yet.
+func OpenBlockChain(store ChainStore) (*BlockChain, error) {
+	lastHash, err := store.Tip()
+	if err == ErrNotFound {
+		return nil, fmt.Errorf("no existing blockchain found, create one")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	chain := &BlockChain{
 		LastHash: lastHash,
-		Database: db,
+		Store:    store,
+		events:   NewEventBus(),
 	}
-}
 
-func OpenBlockChain(address string) *BlockChain {
-	if !dbExists() {
-		fmt.Printf("No existing blockchain found, create one!")
-		runtime.Goexit()
+	indexTip, err := store.GetIndex(indexTipKey)
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+	if !bytes.Equal(indexTip, lastHash) {
+		log.Printf("Indexing block headers")
+		err = chain.reindexHeaders()
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	var lastHash []byte
+	utxoTip, err := store.GetIndex(utxoTipKey)
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+	if !bytes.Equal(utxoTip, lastHash) {
+		log.Printf("Building the UTXO set")
+		err = chain.reindexUTXO()
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	opts := badger.DefaultOptions(dbPath)
+	return chain, nil
+}
 
-	db, err := badger.Open(opts)
+func (c *BlockChain) Close() error {
+	return c.Store.Close()
+}
+
+// AddBlock mines a block with txs on top of the current tip and accepts it.
+func (c *BlockChain) AddBlock(txs []*Transaction) *Block {
+	block, err := c.nextBlock(txs)
 	utils.HandleError(err)
 
-	err = db.Update(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte("lh"))
-		utils.HandleError(err)
-		lastHash, err = item.ValueCopy(lastHash)
-		return err
-	})
+	err = c.AcceptBlock(block)
 	utils.HandleError(err)
 
-	return &BlockChain{
-		LastHash: lastHash,
-		Database: db,
+	return block
+}
+
+// nextBlock mines a block with txs on top of the current tip. Its timestamp
+// is the current time, or one second after the tip's when blocks come
+// faster than that.
+func (c *BlockChain) nextBlock(txs []*Transaction) (*Block, error) {
+	tip, err := c.GetHeader(c.LastHash)
+	if err != nil {
+		return nil, err
 	}
+
+	timestamp := max(time.Now().Unix(), tip.Timestamp+1)
+
+	return newBlockAt(txs, c.LastHash, c.GetBestHeight()+1, timestamp), nil
 }
 
-func (c *BlockChain) AddBlock(txs []*Transaction) {
-	block := NewBlock(txs, c.LastHash)
+func (c *BlockChain) GetBlock(hash []byte) (*Block, error) {
+	return getBlock(c.Store, hash)
+}
 
-	err := c.Database.Update(func(txn *badger.Txn) error {
-		err := txn.Set(block.Hash, block.Serialize())
-		utils.HandleError(err)
-		err = txn.Set([]byte("lh"), block.Hash)
-		return err
-	})
+func (c *BlockChain) GetBestHeight() int {
+	header, err := c.GetHeader(c.LastHash)
 	utils.HandleError(err)
 
-	c.LastHash = block.Hash
+	return header.Height
 }
 
-func (c *BlockChain) FindUnspentTransactions(pubKeyHash []byte) []Transaction {
+func (c *BlockChain) FindUnspentTransactions(lock Script) []Transaction {
 	unspentTxs := []Transaction{}
 
-	spentTXOs := map[string][]int{}
-
-	iter := c.Iterator()
-	for iter.Next() {
-		block := iter.Value()
-
-		for _, tx := range block.Transactions {
-			txID := hex.EncodeToString(tx.ID)
-
-			for outIdx, txo := range tx.Outputs {
-				if !txo.IsLockedWithKey(pubKeyHash) {
-					continue
-				}
-
-				if spentTXOs[txID] != nil {
-					found := false
-					for _, spentOut := range spentTXOs[txID] {
-						if spentOut == outIdx {
-							found = true
-						}
-					}
-					if found {
-						continue
-					}
-				}
-
-				unspentTxs = append(unspentTxs, *tx)
-			}
+	unspentIDs := map[string]bool{}
+	for _, utxo := range c.FindUnspentOutputs(lock) {
+		unspentIDs[hex.EncodeToString(utxo.TxID)] = true
+	}
 
-			if tx.IsCoinbase() {
-				continue
-			}
+	for id := range unspentIDs {
+		txID, _ := hex.DecodeString(id)
+		tx, err := c.FindTransaction(txID)
+		utils.HandleError(err)
 
-			for _, txi := range tx.Inputs {
-				if txi.UsesKey(pubKeyHash) {
-					txInID := hex.EncodeToString(txi.ID)
-					spentTXOs[txInID] = append(spentTXOs[txInID], txi.Out)
-				}
-			}
-		}
+		unspentTxs = append(unspentTxs, tx)
 	}
 
 	return unspentTxs
 }
 
-func (c *BlockChain) FindUTXO(pubKeyHash []byte) []TxOutput {
+func (c *BlockChain) FindUTXO(lock Script) []TxOutput {
 	UTXOs := []TxOutput{}
-	unspentTransactions := c.FindUnspentTransactions(pubKeyHash)
 
-	for _, tx := range unspentTransactions {
-		for _, out := range tx.Outputs {
-			if out.IsLockedWithKey(pubKeyHash) {
-				UTXOs = append(UTXOs, out)
-			}
-		}
+	for _, utxo := range c.FindUnspentOutputs(lock) {
+		UTXOs = append(UTXOs, utxo.Output)
 	}
 
 	return UTXOs
 }
 
-func (c *BlockChain) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int) {
-	unspentOuts := map[string][]int{}
-	accumulated := 0
+// FindSpendableOutputs selects outputs locked with lock worth at least
+// amount, and at least one output when amount is 0. Outputs already spent by
+// mempool transactions or still time locked at the next block are left out.
+func (c *BlockChain) FindSpendableOutputs(lock Script, amount int, selector CoinSelector) (int, []UnspentOutput, error) {
+	spendable := []UnspentOutput{}
 
-	unspentTxs := c.FindUnspentTransactions(pubKeyHash)
+	nextHeight := c.GetBestHeight() + 1
+	now := time.Now().Unix()
+	pending := c.mempoolSpentOutputs()
 
-	for _, tx := range unspentTxs {
-		if accumulated >= amount {
-			break
+	for _, utxo := range c.FindUnspentOutputs(lock) {
+		if pending[outpointKey(utxo.TxID, utxo.Index)] {
+			continue
 		}
 
-		txID := hex.EncodeToString(tx.ID)
+		_, lockTime, sequence := utxo.Output.Script.SplitTimeLock()
+		if utxo.Height+sequence > nextHeight {
+			continue
+		}
+		if !(&Transaction{LockTime: lockTime}).IsFinal(nextHeight, now) {
+			continue
+		}
 
-		for idx, out := range tx.Outputs {
-			if out.IsLockedWithKey(pubKeyHash) {
-				accumulated += out.Value
-				unspentOuts[txID] = append(unspentOuts[txID], idx)
+		spendable = append(spendable, utxo)
+	}
 
-				if accumulated >= amount {
-					break
-				}
-			}
+	// Every transaction needs an input, even one that only carries data.
+	selected, err := selector.Select(spendable, max(amount, 1))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Selectors keep clear of dust change over what they were asked for,
+	// which is more than amount when it is 0.
+	picked := map[string]bool{}
+	for _, utxo := range selected {
+		picked[outpointKey(utxo.TxID, utxo.Index)] = true
+	}
+	unselected := []UnspentOutput{}
+	for _, utxo := range spendable {
+		if !picked[outpointKey(utxo.TxID, utxo.Index)] {
+			unselected = append(unselected, utxo)
 		}
 	}
+	selected = avoidDustChange(selected, unselected, amount)
 
-	return accumulated, unspentOuts
+	return sumOutputs(selected), selected, nil
 }
 
 func (c *BlockChain) FindTransaction(ID []byte) (Transaction, error) {
-	iter := c.Iterator()
+	tx, _, err := c.FindTransactionBlock(ID)
+	return tx, err
+}
 
-	for iter.Next() {
-		block := iter.Value()
+// FindTransactionBlock returns a mined transaction and the block holding it.
+// It fails with ErrTxNotFound, or with ErrPruned when the transaction is not
+// in the blocks a pruned node still has.
+func (c *BlockChain) FindTransactionBlock(ID []byte) (Transaction, *Block, error) {
+	var found *Transaction
+	var foundBlock *Block
 
+	err := c.forEachBlock(func(block *Block) bool {
 		for _, tx := range block.Transactions {
 			if bytes.Equal(tx.ID, ID) {
-				return *tx, nil
+				found = tx
+				foundBlock = block
+				return false
 			}
 		}
+
+		return true
+	})
+	if found != nil {
+		return *found, foundBlock, nil
+	}
+	if err != nil {
+		return Transaction{}, nil, fmt.Errorf("transaction %x not found in the unpruned blocks: %w", ID, err)
 	}
 
-	return Transaction{}, fmt.Errorf("transaction does not exists")
+	return Transaction{}, nil, ErrTxNotFound
 }
 
-func (c *BlockChain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey) {
-	prevTXs := map[string]Transaction{}
+func (c *BlockChain) SignTransaction(tx *Transaction, privKey wallet.Signer) error {
+	prevTXs, err := c.PrevTransactions(tx)
+	if err != nil {
+		return err
+	}
 
-	for _, txIn := range tx.Inputs {
-		prevTX, err := c.FindTransaction(txIn.ID)
-		utils.HandleError(err)
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+	return tx.Sign(privKey, prevTXs)
+}
+
+func (c *BlockChain) VerifyTransaction(tx *Transaction) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+
+	prevTXs, err := c.PrevTransactions(tx)
+	if err != nil {
+		return err
 	}
 
-	tx.Sign(privKey, prevTXs)
+	return tx.Verify(prevTXs)
 }
 
-func (c *BlockChain) VerifyTransaction(tx *Transaction) bool {
+// PrevTransactions returns the transactions whose outputs tx spends, taken
+// from the UTXO set. Only the spent outputs are filled in.
+func (c *BlockChain) PrevTransactions(tx *Transaction) (map[string]Transaction, error) {
 	prevTXs := map[string]Transaction{}
 
 	for _, txIn := range tx.Inputs {
-		prevTX, err := c.FindTransaction(txIn.ID)
-		utils.HandleError(err)
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+		utxo, err := c.FindUnspentOutput(txIn.ID, txIn.Out)
+		if err != nil {
+			return nil, err
+		}
+
+		prevTX := prevTXs[hex.EncodeToString(txIn.ID)]
+		prevTX.ID = utxo.TxID
+		for len(prevTX.Outputs) <= txIn.Out {
+			prevTX.Outputs = append(prevTX.Outputs, TxOutput{})
+		}
+		prevTX.Outputs[txIn.Out] = utxo.Output
+
+		prevTXs[hex.EncodeToString(txIn.ID)] = prevTX
 	}
 
-	return tx.Verify(prevTXs)
+	return prevTXs, nil
 }
 
 func (c *BlockChain) String() string {
 	var builder strings.Builder
 
-	iter := c.Iterator()
-	for iter.Next() {
-		block := iter.Value()
+	err := c.forEachBlock(func(block *Block) bool {
 		builder.WriteString(block.String())
 		builder.WriteString("\n")
+		return true
+	})
+	if errors.Is(err, ErrPruned) {
+		builder.WriteString(fmt.Sprintf("=== Blocks up to height %d are pruned\n", c.PrunedHeight()))
+	} else {
+		utils.HandleError(err)
 	}
 
 	return builder.String()
 }
 
-func (c *BlockChain) Iterator() *BlockChainIterator {
-	return &BlockChainIterator{
-		CurrentHash:  c.LastHash,
-		CurrentBlock: nil,
-		Database:     c.Database,
-	}
-}
-
-type BlockChainIterator struct {
-	CurrentBlock *Block
-	Database     *badger.DB
-	CurrentHash  []byte
-}
-
-func (i *BlockChainIterator) Next() bool {
-	var block *Block
-
-	if i.CurrentHash == nil || len(i.CurrentHash) == 0 {
-		return false
+// forEachBlock calls fn for every block from the tip back to genesis until
+// fn returns false. It fails with ErrPruned on reaching a pruned block.
+func (c *BlockChain) forEachBlock(fn func(block *Block) bool) error {
+	it := c.Iterator()
+	for block := range it.All() {
+		if !fn(block) {
+			return nil
+		}
 	}
 
-	err := i.Database.View(func(txn *badger.Txn) error {
-		var encodedBlock []byte
-
-		item, err := txn.Get(i.CurrentHash)
-		utils.HandleError(err)
-		encodedBlock, err = item.ValueCopy(encodedBlock)
-		block = Deserialize(encodedBlock)
-
-		return err
-	})
-	utils.HandleError(err)
-
-	i.CurrentHash = block.PrevHash
-	i.CurrentBlock = block
-
-	return true
+	return it.Err()
 }
 
-func (i BlockChainIterator) Value() *Block {
-	return i.CurrentBlock
+// ChainExists reports whether a blockchain has been created on this node.
+func ChainExists() bool {
+	return dbExists()
 }
 
 func dbExists() bool {
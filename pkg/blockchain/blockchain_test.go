@@ -0,0 +1,101 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+func TestSend(t *testing.T) {
+	wallets := newTestWallets()
+	from := wallets.AddWallet(wallet.KeySecp256k1)
+	to := wallets.AddWallet(wallet.KeyEd25519)
+
+	chain, err := NewBlockChain(NewMemoryStore(), from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	tx := NewTransaction(from, to, 30, chain, wallets, LargestFirst{}, TxOptions{})
+	mined, err := chain.SubmitTransaction(tx)
+	if err != nil || !mined {
+		t.Fatalf("SubmitTransaction = %v, %v, want mined", mined, err)
+	}
+
+	if got := balance(chain, from); got != BlockSubsidy-30 {
+		t.Errorf("sender balance = %d, want %d", got, BlockSubsidy-30)
+	}
+	if got := balance(chain, to); got != 30 {
+		t.Errorf("recipient balance = %d, want 30", got)
+	}
+
+	spent, err := chain.SpentOutputs(chain.LastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spent) != 1 || len(spent[0]) != 1 || spent[0][0].Value != BlockSubsidy {
+		t.Errorf("spent outputs of the payment block = %v, want the genesis output", spent)
+	}
+
+	// A lock time keeps the payment in the mempool until its height.
+	tx = NewTransaction(to, from, 10, chain, wallets, LargestFirst{}, TxOptions{LockTime: int64(chain.GetBestHeight() + 2)})
+	mined, err = chain.SubmitTransaction(tx)
+	if err != nil || mined {
+		t.Fatalf("SubmitTransaction of a time locked transaction = %v, %v, want pending", mined, err)
+	}
+	if len(chain.MempoolTransactions()) != 1 {
+		t.Fatalf("mempool holds %d transactions, want 1", len(chain.MempoolTransactions()))
+	}
+
+	chain.MineBlock(from)
+	if got := balance(chain, to); got != 30 {
+		t.Errorf("recipient balance before the lock time = %d, want 30", got)
+	}
+
+	chain.MineBlock(from)
+	if got := balance(chain, to); got != 20 {
+		t.Errorf("recipient balance after the lock time = %d, want 20", got)
+	}
+	if len(chain.MempoolTransactions()) != 0 {
+		t.Errorf("mempool still holds %d transactions", len(chain.MempoolTransactions()))
+	}
+}
+
+func TestNotarize(t *testing.T) {
+	wallets := newTestWallets()
+	from := wallets.AddWallet(wallet.KeyP256)
+
+	chain, err := NewBlockChain(NewMemoryStore(), from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	digest := sha256.Sum256([]byte("document"))
+
+	tx, err := NewDataTransaction(from, digest[:], chain, wallets, LargestFirst{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.SubmitTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := chain.FindNotarization(digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(n.TxID, tx.ID) || n.Height != 1 {
+		t.Errorf("notarization is in %x at height %d, want %x at 1", n.TxID, n.Height, tx.ID)
+	}
+	if got := balance(chain, from); got != BlockSubsidy {
+		t.Errorf("balance after notarizing = %d, want %d", got, BlockSubsidy)
+	}
+
+	if _, err := NewDataTransaction(from, digest[:], chain, newTestWallets(), LargestFirst{}); err == nil {
+		t.Error("notarized from an address that is not in the wallets")
+	}
+}
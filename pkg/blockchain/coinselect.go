@@ -0,0 +1,245 @@
+package blockchain
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const (
+	// DustThreshold is the smallest change output a new transaction
+	// creates. Smaller change is left as fee, for the miner of a block with
+	// a coinbase to collect, and is burned otherwise.
+	DustThreshold = 5
+	bnbMaxTries   = 100000
+)
+
+var ErrInsufficientFunds = fmt.Errorf("not enough funds")
+
+type UnspentOutput struct {
+	TxID   []byte
+	Index  int
+	Output TxOutput
+	Height int
+}
+
+type CoinSelector interface {
+	Select(utxos []UnspentOutput, amount int) ([]UnspentOutput, error)
+}
+
+func NewCoinSelector(name string) (CoinSelector, error) {
+	switch name {
+	case "", "largest":
+		return LargestFirst{}, nil
+	case "smallest":
+		return SmallestFirst{}, nil
+	case "bnb":
+		return BranchAndBound{}, nil
+	case "random":
+		return NewRandomImprove(), nil
+	}
+
+	return nil, fmt.Errorf("unknown coin selection strategy %q", name)
+}
+
+type LargestFirst struct{}
+
+func (s LargestFirst) Select(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	return selectInOrder(sortedByValueDesc(utxos), amount)
+}
+
+type SmallestFirst struct{}
+
+func (s SmallestFirst) Select(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	return selectInOrder(sortedByValue(utxos), amount)
+}
+
+type BranchAndBound struct {
+	Fallback CoinSelector
+}
+
+func (s BranchAndBound) Select(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	sorted := sortedByValueDesc(utxos)
+
+	remaining := make([]int, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + sorted[i].Output.Value
+	}
+
+	if remaining[0] < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	tries := 0
+	picked := make([]bool, len(sorted))
+
+	var search func(idx, total int) bool
+	search = func(idx, total int) bool {
+		tries++
+		if total == amount {
+			return true
+		}
+		if idx == len(sorted) || total > amount || total+remaining[idx] < amount || tries > bnbMaxTries {
+			return false
+		}
+
+		picked[idx] = true
+		if search(idx+1, total+sorted[idx].Output.Value) {
+			return true
+		}
+		picked[idx] = false
+
+		return search(idx+1, total)
+	}
+
+	if search(0, 0) {
+		selected := []UnspentOutput{}
+		for idx, utxo := range sorted {
+			if picked[idx] {
+				selected = append(selected, utxo)
+			}
+		}
+
+		return selected, nil
+	}
+
+	fallback := s.Fallback
+	if fallback == nil {
+		fallback = LargestFirst{}
+	}
+
+	return fallback.Select(utxos, amount)
+}
+
+// RandomImprove picks outputs at random until the amount is covered, then
+// adds more that bring the change closer to the amount. Rand is the source of
+// the shuffle; when it is nil the default source is used.
+type RandomImprove struct {
+	Rand *rand.Rand
+}
+
+func NewRandomImprove() RandomImprove {
+	return RandomImprove{
+		Rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s RandomImprove) Select(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	shuffle := rand.Shuffle
+	if s.Rand != nil {
+		shuffle = s.Rand.Shuffle
+	}
+
+	pool := append([]UnspentOutput{}, utxos...)
+	shuffle(len(pool), func(i, j int) {
+		pool[i], pool[j] = pool[j], pool[i]
+	})
+
+	selected := []UnspentOutput{}
+	total := 0
+
+	for total < amount {
+		if len(pool) == 0 {
+			return nil, ErrInsufficientFunds
+		}
+
+		total += pool[0].Output.Value
+		selected = append(selected, pool[0])
+		pool = pool[1:]
+	}
+
+	ideal := 2 * amount
+	upper := 3 * amount
+
+	unselected := []UnspentOutput{}
+	for _, utxo := range pool {
+		next := total + utxo.Output.Value
+		if next > upper || distance(next, ideal) >= distance(total, ideal) {
+			unselected = append(unselected, utxo)
+			continue
+		}
+
+		total = next
+		selected = append(selected, utxo)
+	}
+
+	return avoidDustChange(selected, unselected, amount), nil
+}
+
+func selectInOrder(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	selected := []UnspentOutput{}
+	total := 0
+
+	for idx, utxo := range utxos {
+		total += utxo.Output.Value
+		selected = append(selected, utxo)
+
+		if total >= amount {
+			return avoidDustChange(selected, utxos[idx+1:], amount), nil
+		}
+	}
+
+	return nil, ErrInsufficientFunds
+}
+
+// avoidDustChange adds the smallest unselected output that lifts the change
+// above DustThreshold. If no such output exists the selection is returned
+// unchanged and the dust is left to the fee.
+func avoidDustChange(selected, unselected []UnspentOutput, amount int) []UnspentOutput {
+	change := sumOutputs(selected) - amount
+	if change == 0 || change >= DustThreshold {
+		return selected
+	}
+
+	best := -1
+	for idx, utxo := range unselected {
+		if change+utxo.Output.Value < DustThreshold {
+			continue
+		}
+		if best == -1 || utxo.Output.Value < unselected[best].Output.Value {
+			best = idx
+		}
+	}
+
+	if best == -1 {
+		return selected
+	}
+
+	return append(selected, unselected[best])
+}
+
+func sortedByValue(utxos []UnspentOutput) []UnspentOutput {
+	sorted := append([]UnspentOutput{}, utxos...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Output.Value < sorted[j].Output.Value
+	})
+
+	return sorted
+}
+
+func sortedByValueDesc(utxos []UnspentOutput) []UnspentOutput {
+	sorted := append([]UnspentOutput{}, utxos...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Output.Value > sorted[j].Output.Value
+	})
+
+	return sorted
+}
+
+func sumOutputs(utxos []UnspentOutput) int {
+	total := 0
+	for _, utxo := range utxos {
+		total += utxo.Output.Value
+	}
+
+	return total
+}
+
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
@@ -0,0 +1,184 @@
+package blockchain
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// testUTXOs returns one unspent output per value, each from its own
+// transaction.
+func testUTXOs(values ...int) []UnspentOutput {
+	utxos := []UnspentOutput{}
+	for idx, value := range values {
+		utxos = append(utxos, UnspentOutput{
+			TxID:   []byte{byte(idx)},
+			Output: TxOutput{Value: value},
+		})
+	}
+
+	return utxos
+}
+
+func selectedValues(utxos []UnspentOutput) []int {
+	values := []int{}
+	for _, utxo := range utxos {
+		values = append(values, utxo.Output.Value)
+	}
+
+	return values
+}
+
+func TestCoinSelectors(t *testing.T) {
+	selectors := map[string]CoinSelector{
+		"largest":      LargestFirst{},
+		"smallest":     SmallestFirst{},
+		"bnb":          BranchAndBound{},
+		"random":       RandomImprove{Rand: rand.New(rand.NewSource(1))},
+		"random zero":  RandomImprove{},
+		"random fresh": NewRandomImprove(),
+	}
+	utxos := testUTXOs(40, 3, 25, 8, 60, 12)
+
+	for name, selector := range selectors {
+		t.Run(name, func(t *testing.T) {
+			for _, amount := range []int{1, 30, 100, 148} {
+				selected, err := selector.Select(utxos, amount)
+				if err != nil {
+					t.Fatalf("Select(%d): %v", amount, err)
+				}
+				if total := sumOutputs(selected); total < amount {
+					t.Fatalf("Select(%d) picked %v worth %d", amount, selectedValues(selected), total)
+				}
+
+				seen := map[byte]bool{}
+				for _, utxo := range selected {
+					if seen[utxo.TxID[0]] {
+						t.Fatalf("Select(%d) picked output %d twice", amount, utxo.TxID[0])
+					}
+					seen[utxo.TxID[0]] = true
+				}
+			}
+
+			if _, err := selector.Select(utxos, 149); err != ErrInsufficientFunds {
+				t.Fatalf("Select above the balance = %v, want ErrInsufficientFunds", err)
+			}
+		})
+	}
+}
+
+func TestCoinSelectorOrder(t *testing.T) {
+	utxos := testUTXOs(40, 3, 25, 8, 60, 12)
+
+	tests := []struct {
+		name     string
+		selector CoinSelector
+		amount   int
+		want     []int
+	}{
+		{"largest", LargestFirst{}, 70, []int{60, 40}},
+		{"smallest", SmallestFirst{}, 23, []int{3, 8, 12}},
+		{"bnb exact", BranchAndBound{}, 33, []int{25, 8}},
+		{"bnb fallback", BranchAndBound{}, 147, []int{60, 40, 25, 12, 8, 3}},
+		{"bnb smallest fallback", BranchAndBound{Fallback: SmallestFirst{}}, 146, []int{3, 8, 12, 25, 40, 60}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selected, err := test.selector.Select(utxos, test.amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := selectedValues(selected); !slices.Equal(got, test.want) {
+				t.Fatalf("Select(%d) = %v, want %v", test.amount, got, test.want)
+			}
+		})
+	}
+}
+
+func TestAvoidDustChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int
+		amount int
+		want   []int
+	}{
+		{"no change", []int{10, 20}, 10, []int{10}},
+		{"change above dust", []int{10, 20}, 5, []int{10}},
+		{"dust lifted by the smallest output", []int{30, 2, 12, 10}, 11, []int{2, 10, 12}},
+		{"dust left to the fee", []int{10, 1}, 8, []int{1, 10}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selected, err := SmallestFirst{}.Select(testUTXOs(test.values...), test.amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := selectedValues(selected); !slices.Equal(got, test.want) {
+				t.Fatalf("Select(%d) = %v, want %v", test.amount, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDustChangeIsFee(t *testing.T) {
+	wallets := newTestWallets()
+	from := wallets.AddWallet(wallet.KeyP256)
+	to := wallets.AddWallet(wallet.KeyP256)
+
+	chain, err := NewBlockChain(NewMemoryStore(), from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	tx := NewTransaction(from, to, BlockSubsidy-DustThreshold+1, chain, wallets, LargestFirst{}, TxOptions{})
+	if len(tx.Outputs) != 1 {
+		t.Fatalf("transaction has %d outputs, want the payment without dust change", len(tx.Outputs))
+	}
+
+	mined, err := chain.SubmitTransaction(tx)
+	if err != nil || !mined {
+		t.Fatalf("SubmitTransaction = %v, %v, want mined", mined, err)
+	}
+
+	// Change of exactly DustThreshold is kept.
+	tx = NewTransaction(to, from, BlockSubsidy-2*DustThreshold+1, chain, wallets, LargestFirst{}, TxOptions{})
+	if len(tx.Outputs) != 2 || tx.Outputs[1].Value != DustThreshold {
+		t.Fatalf("transaction outputs %v, want the payment and change of %d", tx.Outputs, DustThreshold)
+	}
+}
+
+func TestDataTransactionChange(t *testing.T) {
+	wallets := newTestWallets()
+	from := wallets.AddWallet(wallet.KeyP256)
+	to := wallets.AddWallet(wallet.KeyP256)
+
+	chain, err := NewBlockChain(NewMemoryStore(), from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	// to has an output of 1 and a block reward.
+	submitMined(t, chain, NewTransaction(from, to, 1, chain, wallets, LargestFirst{}, TxOptions{}))
+	chain.MineBlock(to)
+
+	// The data output is worth nothing, so all of the input is change and 1
+	// alone would be dust.
+	tx, err := NewDataTransaction(to, []byte("document"), chain, wallets, SmallestFirst{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.Inputs) != 2 || len(tx.Outputs) != 2 || tx.Outputs[1].Value != BlockSubsidy+1 {
+		t.Fatalf("transaction spends %d inputs into %v, want both inputs back as change", len(tx.Inputs), tx.Outputs)
+	}
+
+	submitMined(t, chain, tx)
+	if got := balance(chain, to); got != BlockSubsidy+1 {
+		t.Fatalf("to has %d after notarizing, want %d", got, BlockSubsidy+1)
+	}
+}
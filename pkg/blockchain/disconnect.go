@@ -0,0 +1,229 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// DisconnectBlock takes the tip block off the chain and returns it, making
+// its parent the tip. The outputs the block spent go back into the UTXO set,
+// and its transactions other than the coinbase go back to the mempool unless
+// they spend outputs of the same block. Subscribers to Events are told with
+// BlockDisconnected. The genesis block and blocks whose spent outputs are no
+// longer kept, because they were pruned or loaded from a UTXO snapshot,
+// cannot be disconnected.
+func (c *BlockChain) DisconnectBlock() (*Block, error) {
+	block, err := c.GetBlock(c.LastHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if block.Height == 0 {
+		return nil, fmt.Errorf("cannot disconnect the genesis block")
+	}
+
+	spent, err := spentUTXOs(c.Store, block.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("cannot disconnect block %x: %w", block.Hash, err)
+	}
+	if len(spent) != len(block.Transactions) {
+		return nil, fmt.Errorf("cannot disconnect block %x: its spent outputs do not match its transactions", block.Hash)
+	}
+
+	returned := returnedTransactions(block)
+
+	err = c.Store.Update(func(batch StoreBatch) error {
+		err := unindexBlock(batch, block)
+		if err != nil {
+			return err
+		}
+
+		err = revertUTXOSet(batch, block, spent)
+		if err != nil {
+			return err
+		}
+
+		err = unindexHTLCSpends(batch, block, spent)
+		if err != nil {
+			return err
+		}
+
+		err = unindexNotarizations(batch, block)
+		if err != nil {
+			return err
+		}
+
+		for _, tx := range returned {
+			err = batch.PutIndex(mempoolKey(tx.ID), tx.Serialize())
+			if err != nil {
+				return err
+			}
+		}
+
+		err = batch.DeleteBlock(block.Hash)
+		if err != nil {
+			return err
+		}
+
+		return batch.SetTip(block.PrevHash)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.LastHash = block.PrevHash
+
+	events := []Event{BlockDisconnected{Block: block}}
+	for _, tx := range returned {
+		events = append(events, TxAccepted{Tx: tx})
+	}
+	events = append(events, balanceEvents(block, spent, true)...)
+	c.events.publish(events...)
+
+	return block, nil
+}
+
+// returnedTransactions returns the transactions of a disconnected block that
+// can go back to the mempool: all but the coinbase and the ones spending
+// outputs created in the same block.
+func returnedTransactions(block *Block) []*Transaction {
+	created := map[string]bool{}
+	for _, tx := range block.Transactions {
+		created[string(tx.ID)] = true
+	}
+
+	txs := []*Transaction{}
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		ok := true
+		for _, txIn := range tx.Inputs {
+			if created[string(txIn.ID)] {
+				ok = false
+			}
+		}
+		if ok {
+			txs = append(txs, tx)
+		}
+	}
+
+	return txs
+}
+
+// unindexBlock drops the height of a disconnected block and takes its body
+// off the stored size. Its header is kept, so that whoever saw the block can
+// walk back from it to where the chain forked.
+func unindexBlock(batch StoreBatch, block *Block) error {
+	header, err := getHeader(batch, block.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = batch.DeleteIndex(heightKey(block.Height))
+	if err != nil {
+		return err
+	}
+
+	size, err := bodySize(batch)
+	if err != nil {
+		return err
+	}
+
+	err = putBodySize(batch, max(size-int64(header.Size), 0))
+	if err != nil {
+		return err
+	}
+
+	return batch.PutIndex(indexTipKey, block.PrevHash)
+}
+
+// revertUTXOSet undoes updateUTXOSet: the outputs block created leave the
+// set and the ones it spent come back. Transactions are undone last first,
+// so outputs created and spent within the block end up gone.
+func revertUTXOSet(batch StoreBatch, block *Block, spent [][]UnspentOutput) error {
+	for txIdx := len(block.Transactions) - 1; txIdx >= 0; txIdx-- {
+		tx := block.Transactions[txIdx]
+
+		for idx := range tx.Outputs {
+			err := batch.DeleteIndex(utxoKey(tx.ID, idx))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, utxo := range spent[txIdx] {
+			encoded, err := encodeUnspentOutput(utxo)
+			if err != nil {
+				return err
+			}
+
+			err = batch.PutIndex(utxoKey(utxo.TxID, utxo.Index), encoded)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := batch.DeleteIndex(spentKey(block.Hash))
+	if err != nil {
+		return err
+	}
+
+	return batch.PutIndex(utxoTipKey, block.PrevHash)
+}
+
+// unindexHTLCSpends undoes indexHTLCSpends.
+func unindexHTLCSpends(batch StoreBatch, block *Block, spent [][]UnspentOutput) error {
+	for txIdx := range block.Transactions {
+		for _, utxo := range spent[txIdx] {
+			if _, _, _, _, ok := utxo.Output.Script.HTLC(); !ok {
+				continue
+			}
+
+			err := batch.DeleteIndex(htlcSpendKey(utxo.TxID, utxo.Index))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// unindexNotarizations drops the notarizations anchored in block. Those are
+// the earliest anchors of their data, so no older one is left to keep.
+func unindexNotarizations(batch StoreBatch, block *Block) error {
+	for _, tx := range block.Transactions {
+		for _, out := range tx.Outputs {
+			data, ok := out.Script.Data()
+			if !ok {
+				continue
+			}
+
+			encoded, err := batch.GetIndex(notarizationKey(data))
+			if err == ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			n, err := decodeNotarization(encoded)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(n.BlockHash, block.Hash) {
+				continue
+			}
+
+			err = batch.DeleteIndex(notarizationKey(data))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+func TestDisconnectBlock(t *testing.T) {
+	wallets := newTestWallets()
+	alice, bob := wallets.AddWallet(wallet.KeyP256), wallets.AddWallet(wallet.KeyP256)
+
+	chain, err := NewBlockChain(NewMemoryStore(), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	genesis := chain.LastHash
+	count, total, err := chain.UTXOSetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One block paying Bob, claiming an HTLC and notarizing data.
+	hash := sha256.Sum256([]byte("secret"))
+	htlc, err := NewHTLCTransaction(alice, bob, 40, hash[:], 100, chain, wallets, LargestFirst{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitMined(t, chain, htlc)
+	beforeClaim := chain.LastHash
+
+	claim, err := NewHTLCClaimTransaction(htlc.ID, 0, []byte("secret"), bob, chain, wallets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notarized := []byte("document digest")
+	notarization, err := NewDataTransaction(alice, notarized, chain, wallets, LargestFirst{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range []*Transaction{claim, notarization} {
+		if err := chain.AddToMempool(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	block := chain.MineBlock(bob)
+
+	disconnected, err := chain.DisconnectBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(disconnected.Hash, block.Hash) || !bytes.Equal(chain.LastHash, beforeClaim) {
+		t.Fatalf("disconnected %x, tip %x, want %x and %x", disconnected.Hash, chain.LastHash, block.Hash, beforeClaim)
+	}
+
+	if got := balance(chain, bob); got != 0 {
+		t.Errorf("Bob has %d after the disconnect, want 0", got)
+	}
+	if _, err := chain.FindHTLCPreimage(htlc.ID, 0); err == nil {
+		t.Error("found the preimage of a disconnected claim")
+	}
+	if _, err := chain.FindNotarization(notarized); err == nil {
+		t.Error("found a notarization of a disconnected block")
+	}
+	if _, err := chain.GetBlock(block.Hash); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetBlock of a disconnected block = %v, want ErrNotFound", err)
+	}
+	if header, err := chain.GetHeader(block.Hash); err != nil || chain.OnChain(header) {
+		t.Errorf("header of a disconnected block: %v, want it kept off the chain", err)
+	}
+	if mempool := chain.MempoolTransactions(); len(mempool) != 2 {
+		t.Fatalf("mempool has %d transactions after the disconnect, want 2", len(mempool))
+	}
+
+	// Mining again confirms the same transactions in a new block.
+	again := chain.MineBlock(alice)
+	if len(again.Transactions) != 3 || bytes.Equal(again.Hash, block.Hash) {
+		t.Fatalf("new block has %d transactions, want the coinbase and the 2 returned", len(again.Transactions))
+	}
+	if got := balance(chain, bob); got != 40 {
+		t.Errorf("Bob has %d after mining again, want 40", got)
+	}
+	if n, err := chain.FindNotarization(notarized); err != nil || !bytes.Equal(n.BlockHash, again.Hash) {
+		t.Errorf("notarization after mining again: %v", err)
+	}
+
+	// Back to genesis, where the UTXO set started.
+	for chain.GetBestHeight() > 0 {
+		if _, err := chain.DisconnectBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(chain.LastHash, genesis) {
+		t.Fatalf("tip %x after disconnecting every block, want genesis %x", chain.LastHash, genesis)
+	}
+	gotCount, gotTotal, err := chain.UTXOSetStats()
+	if err != nil || gotCount != count || gotTotal != total {
+		t.Fatalf("UTXO set of %d outputs worth %d, want %d worth %d", gotCount, gotTotal, count, total)
+	}
+	if _, err := chain.DisconnectBlock(); err == nil {
+		t.Fatal("disconnected the genesis block")
+	}
+}
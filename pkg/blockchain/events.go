@@ -0,0 +1,269 @@
+package blockchain
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// Event is a change to a chain or its mempool, published on the chain's
+// EventBus: BlockConnected, BlockDisconnected, TxAccepted,
+// TxRemovedFromMempool or WalletBalanceChanged.
+type Event interface {
+	chainEvent()
+}
+
+// BlockConnected is published when a block becomes the new tip.
+type BlockConnected struct {
+	Block *Block
+}
+
+// BlockDisconnected is published when the tip block is taken off the chain.
+type BlockDisconnected struct {
+	Block *Block
+}
+
+// TxAccepted is published when a transaction is added to the mempool,
+// including the transactions of a disconnected block that go back to it.
+// Transactions mined right away are only seen in BlockConnected.
+type TxAccepted struct {
+	Tx *Transaction
+}
+
+// RemovalReason says why a transaction left the mempool.
+type RemovalReason int
+
+const (
+	// RemovedMined transactions were mined in a connected block.
+	RemovedMined RemovalReason = iota
+	// RemovedInvalid transactions can no longer be mined, for example
+	// because their inputs were spent by another transaction.
+	RemovedInvalid
+)
+
+func (r RemovalReason) String() string {
+	switch r {
+	case RemovedMined:
+		return "mined"
+	case RemovedInvalid:
+		return "invalid"
+	default:
+		return "unknown"
+	}
+}
+
+// TxRemovedFromMempool is published when a transaction leaves the mempool.
+type TxRemovedFromMempool struct {
+	Tx     *Transaction
+	Reason RemovalReason
+}
+
+// WalletBalanceChanged is published after BlockConnected for every locking
+// script the block pays to or spends from. After BlockDisconnected it undoes
+// the block: the outputs the block spent are received back and the ones it
+// created are sent. Address is the zero Address for scripts without one.
+type WalletBalanceChanged struct {
+	Lock     Script
+	Address  wallet.Address
+	Block    *Block
+	Received int
+	Sent     int
+}
+
+func (BlockConnected) chainEvent()       {}
+func (BlockDisconnected) chainEvent()    {}
+func (TxAccepted) chainEvent()           {}
+func (TxRemovedFromMempool) chainEvent() {}
+func (WalletBalanceChanged) chainEvent() {}
+
+// Overflow says what happens to an event published while a subscriber's
+// buffer is full. Publishing never waits for subscribers.
+type Overflow int
+
+const (
+	// CloseOnOverflow ends the subscription, so a subscriber that must see
+	// every event knows to read the chain again.
+	CloseOnOverflow Overflow = iota
+	// DropOnOverflow drops the event and counts it.
+	DropOnOverflow
+)
+
+// ErrSubscriberOverflow ends a CloseOnOverflow subscription that fell
+// behind.
+var ErrSubscriberOverflow = errors.New("subscriber fell behind and missed events")
+
+// EventBus delivers chain events to subscribers, in the order they happened.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]bool
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: map[*Subscription]bool{},
+	}
+}
+
+// Subscription receives the events published after it was made.
+type Subscription struct {
+	bus      *EventBus
+	events   chan Event
+	overflow Overflow
+	// dropped and err are guarded by bus.mu.
+	dropped int
+	err     error
+}
+
+// Subscribe returns a subscription buffering up to buffer events.
+func (b *EventBus) Subscribe(buffer int, overflow Overflow) *Subscription {
+	sub := &Subscription{
+		bus:      b,
+		events:   make(chan Event, buffer),
+		overflow: overflow,
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = true
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns ErrSubscriberOverflow if the subscription ended because it fell
+// behind, and nil otherwise.
+func (s *Subscription) Err() error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	return s.err
+}
+
+// Dropped returns how many events a DropOnOverflow subscription missed.
+func (s *Subscription) Dropped() int {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	return s.dropped
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.remove(s)
+}
+
+// remove ends sub. The caller holds b.mu.
+func (b *EventBus) remove(sub *Subscription) {
+	if b.subscribers[sub] {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (b *EventBus) hasSubscribers() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers) > 0
+}
+
+func (b *EventBus) publish(events ...Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, event := range events {
+		for sub := range b.subscribers {
+			select {
+			case sub.events <- event:
+			default:
+				if sub.overflow == DropOnOverflow {
+					sub.dropped++
+				} else {
+					sub.err = ErrSubscriberOverflow
+					b.remove(sub)
+				}
+			}
+		}
+	}
+}
+
+// Events returns the bus the chain publishes its events on.
+func (c *BlockChain) Events() *EventBus {
+	return c.events
+}
+
+// minedEvents returns the TxRemovedFromMempool events for connecting block.
+// It must be called before block is stored, while its transactions are
+// still in the mempool.
+func (c *BlockChain) minedEvents(block *Block) ([]Event, error) {
+	events := []Event{}
+
+	for _, tx := range block.Transactions {
+		_, err := c.Store.GetIndex(mempoolKey(tx.ID))
+		if err == nil {
+			events = append(events, TxRemovedFromMempool{Tx: tx, Reason: RemovedMined})
+		} else if err != ErrNotFound {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
+
+// balanceEvents returns the WalletBalanceChanged events of connecting block,
+// or of disconnecting it when disconnected is set, given the outputs it
+// spent.
+func balanceEvents(block *Block, spent [][]UnspentOutput, disconnected bool) []Event {
+	// Balances are published in the order their scripts first appear.
+	balances := []*WalletBalanceChanged{}
+	byLock := map[string]*WalletBalanceChanged{}
+	balance := func(lock Script) *WalletBalanceChanged {
+		inner, _, _ := lock.SplitTimeLock()
+		changed, ok := byLock[string(inner)]
+		if !ok {
+			address, _ := ScriptAddress(inner)
+			changed = &WalletBalanceChanged{Lock: inner, Address: address, Block: block}
+			byLock[string(inner)] = changed
+			balances = append(balances, changed)
+		}
+		return changed
+	}
+
+	for txIdx, tx := range block.Transactions {
+		for _, utxo := range spent[txIdx] {
+			if disconnected {
+				balance(utxo.Output.Script).Received += utxo.Output.Value
+			} else {
+				balance(utxo.Output.Script).Sent += utxo.Output.Value
+			}
+		}
+
+		for _, txOut := range tx.Outputs {
+			if _, ok := txOut.Script.Data(); ok {
+				continue
+			}
+
+			if disconnected {
+				balance(txOut.Script).Sent += txOut.Value
+			} else {
+				balance(txOut.Script).Received += txOut.Value
+			}
+		}
+	}
+
+	events := []Event{}
+	for _, changed := range balances {
+		events = append(events, *changed)
+	}
+
+	return events
+}
@@ -0,0 +1,135 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// received returns the events waiting on sub.
+func received(sub *Subscription) []Event {
+	events := []Event{}
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestSubscriptionOverflow(t *testing.T) {
+	bus := NewEventBus()
+	closing := bus.Subscribe(2, CloseOnOverflow)
+	dropping := bus.Subscribe(2, DropOnOverflow)
+	roomy := bus.Subscribe(8, CloseOnOverflow)
+
+	bus.publish(TxAccepted{}, TxAccepted{}, TxAccepted{}, TxAccepted{})
+
+	if got := len(received(closing)); got != 2 {
+		t.Fatalf("CloseOnOverflow subscriber got %d events, want the 2 it had room for", got)
+	}
+	if _, ok := <-closing.Events(); ok {
+		t.Fatal("CloseOnOverflow subscription is still open")
+	}
+	if closing.Err() != ErrSubscriberOverflow {
+		t.Fatalf("Err() = %v, want ErrSubscriberOverflow", closing.Err())
+	}
+
+	if got := len(received(dropping)); got != 2 {
+		t.Fatalf("DropOnOverflow subscriber got %d events, want 2", got)
+	}
+	if dropping.Dropped() != 2 || dropping.Err() != nil {
+		t.Fatalf("Dropped() = %d, Err() = %v, want 2 and nil", dropping.Dropped(), dropping.Err())
+	}
+
+	// A subscriber that kept up is still subscribed after the others fell
+	// behind.
+	bus.publish(TxAccepted{})
+	if got := len(received(roomy)); got != 5 || roomy.Err() != nil {
+		t.Fatalf("subscriber with room got %d events and %v, want 5 and nil", got, roomy.Err())
+	}
+	if got := len(received(dropping)); got != 1 {
+		t.Fatalf("DropOnOverflow subscriber got %d events once drained, want 1", got)
+	}
+
+	roomy.Close()
+	if _, ok := <-roomy.Events(); ok || roomy.Err() != nil {
+		t.Fatal("Close did not end the subscription cleanly")
+	}
+	roomy.Close()
+}
+
+func TestChainEvents(t *testing.T) {
+	wallets := newTestWallets()
+	from := wallets.AddWallet(wallet.KeyP256)
+	to := wallets.AddWallet(wallet.KeyP256)
+
+	chain, err := NewBlockChain(NewMemoryStore(), from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	sub := chain.Events().Subscribe(64, CloseOnOverflow)
+	defer sub.Close()
+
+	// Waits in the mempool for a few blocks.
+	tx := NewTransaction(from, to, 30, chain, wallets, LargestFirst{}, TxOptions{LockTime: 3})
+	if err := chain.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	if events := received(sub); len(events) != 1 || events[0].(TxAccepted).Tx != tx {
+		t.Fatalf("got %v, want TxAccepted", events)
+	}
+
+	var block *Block
+	for len(chain.MempoolTransactions()) > 0 {
+		block = chain.MineBlock(to)
+	}
+	events := received(sub)
+
+	// The block that mined tx, its removal from the mempool and the two
+	// balances it changed.
+	last := events[len(events)-4:]
+	if connected, ok := last[0].(BlockConnected); !ok || connected.Block != block {
+		t.Fatalf("got %v, want BlockConnected of the block mining the transaction", last[0])
+	}
+	if removed, ok := last[1].(TxRemovedFromMempool); !ok || removed.Reason != RemovedMined || string(removed.Tx.ID) != string(tx.ID) {
+		t.Fatalf("got %v, want TxRemovedFromMempool of the mined transaction", last[1])
+	}
+
+	balances := map[string]WalletBalanceChanged{}
+	for _, event := range last[2:] {
+		changed := event.(WalletBalanceChanged)
+		balances[changed.Address.String()] = changed
+	}
+	if got := balances[to]; got.Received != BlockSubsidy+30 || got.Sent != 0 {
+		t.Errorf("recipient balance change %+v, want %d received", got, BlockSubsidy+30)
+	}
+	if got := balances[from]; got.Received != BlockSubsidy-30 || got.Sent != BlockSubsidy {
+		t.Errorf("sender balance change %+v, want change of %d for %d sent", got, BlockSubsidy-30, BlockSubsidy)
+	}
+
+	if _, err := chain.DisconnectBlock(); err != nil {
+		t.Fatal(err)
+	}
+	events = received(sub)
+	if disconnected, ok := events[0].(BlockDisconnected); !ok || disconnected.Block.Height != block.Height {
+		t.Fatalf("got %v, want BlockDisconnected", events[0])
+	}
+	if accepted, ok := events[1].(TxAccepted); !ok || string(accepted.Tx.ID) != string(tx.ID) {
+		t.Fatalf("got %v, want the mined transaction back in the mempool", events[1])
+	}
+	for _, event := range events[2:] {
+		changed := event.(WalletBalanceChanged)
+		want := balances[changed.Address.String()]
+		if changed.Received != want.Sent || changed.Sent != want.Received {
+			t.Errorf("disconnect balance change %+v does not undo %+v", changed, want)
+		}
+	}
+}
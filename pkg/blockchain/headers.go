@@ -0,0 +1,202 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+)
+
+const (
+	headerPrefix = "header-"
+	heightPrefix = "height-"
+)
+
+var (
+	// indexTipKey holds the newest block whose header is indexed, so that
+	// chains stored before every block had a header are indexed on open.
+	indexTipKey = []byte("index-tip")
+	// bodySizeKey holds the total serialized size of the stored block
+	// bodies.
+	bodySizeKey = []byte("body-size")
+)
+
+// indexReader is the read side shared by ChainStore and StoreBatch.
+type indexReader interface {
+	GetIndex(key []byte) ([]byte, error)
+}
+
+// BlockHeader is what a pruned node keeps of a block. Every stored block has
+// one, so walking headers never decodes block bodies.
+type BlockHeader struct {
+	Timestamp int64
+	Hash      []byte
+	PrevHash  []byte
+	TxHash    []byte
+	Nonce     int
+	Height    int
+	// Size is the serialized size of the block body.
+	Size int
+}
+
+func (b *Block) Header() BlockHeader {
+	return BlockHeader{
+		Timestamp: b.Timestamp,
+		Hash:      b.Hash,
+		PrevHash:  b.PrevHash,
+		TxHash:    b.HashTransactions(),
+		Nonce:     b.Nonce,
+		Height:    b.Height,
+		Size:      len(b.Serialize()),
+	}
+}
+
+// GetHeader returns the header of a block, whether or not its body has been
+// pruned.
+func (c *BlockChain) GetHeader(hash []byte) (*BlockHeader, error) {
+	return getHeader(c.Store, hash)
+}
+
+// OnChain reports whether header is the block at its height on the current
+// chain, rather than a block that was disconnected.
+func (c *BlockChain) OnChain(header *BlockHeader) bool {
+	return onChain(c.Store, header)
+}
+
+func onChain(store indexReader, header *BlockHeader) bool {
+	hash, err := store.GetIndex(heightKey(header.Height))
+
+	return err == nil && bytes.Equal(hash, header.Hash)
+}
+
+func getHeader(store indexReader, hash []byte) (*BlockHeader, error) {
+	data, err := store.GetIndex(headerKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	var header BlockHeader
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &header, nil
+}
+
+func putHeader(batch StoreBatch, header *BlockHeader) error {
+	var encoded bytes.Buffer
+	err := gob.NewEncoder(&encoded).Encode(header)
+	if err != nil {
+		return err
+	}
+
+	return batch.PutIndex(headerKey(header.Hash), encoded.Bytes())
+}
+
+// indexBlock records the header and height of a block stored in the same
+// batch, and adds its body to the stored size.
+func indexBlock(batch StoreBatch, block *Block) error {
+	header := block.Header()
+
+	err := putHeader(batch, &header)
+	if err != nil {
+		return err
+	}
+
+	err = batch.PutIndex(heightKey(block.Height), block.Hash)
+	if err != nil {
+		return err
+	}
+
+	size, err := bodySize(batch)
+	if err != nil {
+		return err
+	}
+
+	err = putBodySize(batch, size+int64(header.Size))
+	if err != nil {
+		return err
+	}
+
+	return batch.PutIndex(indexTipKey, block.Hash)
+}
+
+// reindexHeaders indexes the blocks of a chain stored before every block had
+// a header, walking back from the tip to genesis or the first block without
+// a body or a header.
+func (c *BlockChain) reindexHeaders() error {
+	size := int64(0)
+
+	for hash := c.LastHash; len(hash) > 0; {
+		header, err := c.GetHeader(hash)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+
+		block, blockErr := c.Store.GetBlock(hash)
+		if blockErr != nil && blockErr != ErrNotFound {
+			return blockErr
+		}
+		if blockErr == nil {
+			indexed := block.Header()
+			header = &indexed
+			size += int64(header.Size)
+		}
+		if header == nil {
+			break
+		}
+
+		err = c.Store.Update(func(batch StoreBatch) error {
+			err := putHeader(batch, header)
+			if err != nil {
+				return err
+			}
+
+			return batch.PutIndex(heightKey(header.Height), header.Hash)
+		})
+		if err != nil {
+			return err
+		}
+
+		hash = header.PrevHash
+	}
+
+	return c.Store.Update(func(batch StoreBatch) error {
+		err := putBodySize(batch, size)
+		if err != nil {
+			return err
+		}
+
+		return batch.PutIndex(indexTipKey, c.LastHash)
+	})
+}
+
+// bodySize returns the total serialized size of the stored block bodies.
+func bodySize(store indexReader) (int64, error) {
+	data, err := store.GetIndex(bodySizeKey)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("invalid stored body size")
+	}
+
+	return int64(binary.BigEndian.Uint64(data)), nil
+}
+
+func putBodySize(batch StoreBatch, size int64) error {
+	return batch.PutIndex(bodySizeKey, binary.BigEndian.AppendUint64(nil, uint64(size)))
+}
+
+func headerKey(hash []byte) []byte {
+	return append([]byte(headerPrefix), hash...)
+}
+
+func heightKey(height int) []byte {
+	return binary.BigEndian.AppendUint64([]byte(heightPrefix), uint64(height))
+}
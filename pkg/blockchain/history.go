@@ -0,0 +1,63 @@
+package blockchain
+
+// AddressActivity is a mined transaction that pays to or spends from the
+// outputs of one locking script.
+type AddressActivity struct {
+	TxID      []byte
+	BlockHash []byte
+	Height    int
+	Timestamp int64
+	Received  int
+	Sent      int
+}
+
+// AddressHistory returns the transactions paying to or spending from outputs
+// locked with lock, oldest first. A pruned node only searches the blocks it
+// still has, and cannot see spends of outputs from pruned blocks; the second
+// result is the first height searched.
+func (c *BlockChain) AddressHistory(lock Script) ([]AddressActivity, int, error) {
+	from := c.PrunedHeight() + 1
+	history := []AddressActivity{}
+	if from > c.GetBestHeight() {
+		return history, from, nil
+	}
+
+	owned := map[string]int{}
+
+	it := c.RangeIterator(from, -1)
+	for block := range it.All() {
+		for _, tx := range block.Transactions {
+			activity := AddressActivity{
+				TxID:      tx.ID,
+				BlockHash: block.Hash,
+				Height:    block.Height,
+				Timestamp: block.Timestamp,
+			}
+
+			if !tx.IsCoinbase() {
+				for _, txIn := range tx.Inputs {
+					key := outpointKey(txIn.ID, txIn.Out)
+					if value, ok := owned[key]; ok {
+						activity.Sent += value
+						delete(owned, key)
+					}
+				}
+			}
+
+			received := false
+			for idx, txOut := range tx.Outputs {
+				if txOut.IsLockedWith(lock) {
+					activity.Received += txOut.Value
+					owned[outpointKey(tx.ID, idx)] = txOut.Value
+					received = true
+				}
+			}
+
+			if received || activity.Sent > 0 {
+				history = append(history, activity)
+			}
+		}
+	}
+
+	return history, from, it.Err()
+}
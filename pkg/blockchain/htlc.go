@@ -0,0 +1,216 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+const htlcSpendPrefix = "htlc-"
+
+// HTLCScript locks an output so that the recipient can claim it with the
+// preimage of hash, or the sender can take it back once timeout (a block
+// height or Unix time) has passed.
+func HTLCScript(hash, recipientPubKeyHash, senderPubKeyHash []byte, timeout int64) Script {
+	return NewScript().
+		AddOp(OpIf).
+		AddOp(OpSha256).
+		AddData(hash).
+		AddOp(OpEqualVerify).
+		AddOp(OpDup).
+		AddOp(OpHash160).
+		AddData(recipientPubKeyHash).
+		AddOp(OpElse).
+		AddData(encodeNum(int(timeout))).
+		AddOp(OpCheckLockTime).
+		AddOp(OpDrop).
+		AddOp(OpDup).
+		AddOp(OpHash160).
+		AddData(senderPubKeyHash).
+		AddOp(OpEndIf).
+		AddOp(OpEqualVerify).
+		AddOp(OpCheckSig)
+}
+
+// HTLC returns the hash, recipient and sender key hashes and timeout of an
+// HTLCScript.
+func (s Script) HTLC() ([]byte, []byte, []byte, int64, bool) {
+	ins, err := s.parse()
+	if err != nil || len(ins) != 17 {
+		return nil, nil, nil, 0, false
+	}
+
+	hash := ins[2].Data
+	recipient := ins[6].Data
+	timeout := decodeNum(ins[8].Data)
+	sender := ins[13].Data
+
+	if !bytes.Equal(s, HTLCScript(hash, recipient, sender, timeout)) {
+		return nil, nil, nil, 0, false
+	}
+
+	return hash, recipient, sender, timeout, true
+}
+
+func NewHTLCTransaction(from, to string, amount int, hash []byte, timeout int64, chain *BlockChain, wallets *wallet.Wallets, selector CoinSelector) (*Transaction, error) {
+	if len(hash) != sha256.Size {
+		return nil, fmt.Errorf("HTLC hash must be %d bytes", sha256.Size)
+	}
+
+	w, err := wallets.GetWallet(from)
+	if err != nil {
+		return nil, err
+	}
+	senderPubKeyHash := wallet.PublicKeyHash(w.PublicKey)
+
+	recipient, err := wallet.ParseAddress(to)
+	if err != nil {
+		return nil, err
+	}
+	if recipient.IsScriptHash() {
+		return nil, fmt.Errorf("HTLC recipient must be a single key address")
+	}
+	recipientPubKeyHash := recipient.Hash
+
+	payment := TxOutput{
+		Value:  amount,
+		Script: HTLCScript(hash, recipientPubKeyHash, senderPubKeyHash, timeout),
+	}
+
+	tx, err := newUnsignedTransaction(PayToPubKeyHashScript(senderPubKeyHash), from, payment, chain, selector, 0)
+	if err != nil {
+		return nil, err
+	}
+	err = chain.SignTransaction(tx, w.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// NewHTLCClaimTransaction spends an HTLC output to address, revealing the
+// preimage on chain.
+func NewHTLCClaimTransaction(htlcTxID []byte, out int, preimage []byte, address string, chain *BlockChain, wallets *wallet.Wallets) (*Transaction, error) {
+	return newHTLCSpendTransaction(htlcTxID, out, preimage, address, chain, wallets)
+}
+
+// NewHTLCRefundTransaction returns an HTLC output to its sender. The
+// transaction cannot be mined before the HTLC timeout.
+func NewHTLCRefundTransaction(htlcTxID []byte, out int, address string, chain *BlockChain, wallets *wallet.Wallets) (*Transaction, error) {
+	return newHTLCSpendTransaction(htlcTxID, out, nil, address, chain, wallets)
+}
+
+func newHTLCSpendTransaction(htlcTxID []byte, out int, preimage []byte, address string, chain *BlockChain, wallets *wallet.Wallets) (*Transaction, error) {
+	utxo, err := chain.FindUnspentOutput(htlcTxID, out)
+	if err != nil {
+		return nil, err
+	}
+
+	prevOut := utxo.Output
+	hash, recipient, sender, timeout, ok := prevOut.Script.HTLC()
+	if !ok {
+		return nil, fmt.Errorf("output %x:%d is not an HTLC", htlcTxID, out)
+	}
+
+	claim := preimage != nil
+	if claim {
+		digest := sha256.Sum256(preimage)
+		if !bytes.Equal(digest[:], hash) {
+			return nil, fmt.Errorf("preimage does not match the HTLC hash")
+		}
+	}
+
+	w, err := wallets.GetWallet(address)
+	if err != nil {
+		return nil, err
+	}
+	pubKeyHash := wallet.PublicKeyHash(w.PublicKey)
+
+	if claim && !bytes.Equal(pubKeyHash, recipient) {
+		return nil, fmt.Errorf("%s is not the HTLC recipient", address)
+	}
+	if !claim && !bytes.Equal(pubKeyHash, sender) {
+		return nil, fmt.Errorf("%s is not the HTLC sender", address)
+	}
+
+	tx := &Transaction{
+		Inputs: []TxInput{
+			{ID: htlcTxID, Out: out},
+		},
+		Outputs: []TxOutput{
+			*NewTXOutput(prevOut.Value, address),
+		},
+	}
+	if !claim {
+		tx.LockTime = timeout
+	}
+	tx.ID = tx.Hash()
+
+	signature := signHash(w.PrivateKey, tx.SigHash(0, prevOut))
+	unlock := NewScript().AddData(signature).AddData(w.PublicKey)
+	if claim {
+		unlock = unlock.AddData(preimage).AddData([]byte{1})
+	} else {
+		unlock = unlock.AddData([]byte{})
+	}
+	tx.Inputs[0].Script = unlock
+
+	return tx, nil
+}
+
+// FindHTLCPreimage looks up the transaction that claimed an HTLC output and
+// returns the preimage it revealed.
+func (c *BlockChain) FindHTLCPreimage(htlcTxID []byte, out int) ([]byte, error) {
+	unlock, err := c.Store.GetIndex(htlcSpendKey(htlcTxID, out))
+	if err == ErrNotFound {
+		return nil, fmt.Errorf("HTLC %s:%d has not been claimed", hex.EncodeToString(htlcTxID), out)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := Script(unlock).PushedData()
+	if !ok || len(data) != 4 {
+		return nil, fmt.Errorf("HTLC %s:%d was refunded", hex.EncodeToString(htlcTxID), out)
+	}
+
+	return data[2], nil
+}
+
+// indexHTLCSpends records the unlocking script of every input of block that
+// spends an HTLC output, so that the preimage of a claim can be found without
+// searching the blocks. It reads the block's spent outputs, so it runs after
+// updateUTXOSet.
+func indexHTLCSpends(batch StoreBatch, block *Block) error {
+	spent, err := spentUTXOs(batch, block.Hash)
+	if err != nil {
+		return err
+	}
+
+	for txIdx, tx := range block.Transactions {
+		for inIdx, prevOut := range spent[txIdx] {
+			if _, _, _, _, ok := prevOut.Output.Script.HTLC(); !ok {
+				continue
+			}
+
+			txIn := tx.Inputs[inIdx]
+			err := batch.PutIndex(htlcSpendKey(txIn.ID, txIn.Out), txIn.Script)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func htlcSpendKey(txID []byte, out int) []byte {
+	key := append([]byte(htlcSpendPrefix), txID...)
+
+	return binary.BigEndian.AppendUint32(key, uint32(out))
+}
@@ -0,0 +1,147 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// submitMined submits tx and fails unless it is mined right away.
+func submitMined(t *testing.T, chain *BlockChain, tx *Transaction) {
+	t.Helper()
+
+	mined, err := chain.SubmitTransaction(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mined {
+		t.Fatalf("transaction %x was not mined", tx.ID)
+	}
+}
+
+// TestAtomicSwap trades 40 of Alice's coins on the alpha chain for 60 of
+// Bob's coins on the beta chain.
+func TestAtomicSwap(t *testing.T) {
+	alice, bob := newTestWallets(), newTestWallets()
+	aliceAlpha, aliceBeta := alice.AddWallet(wallet.KeyP256), alice.AddWallet(wallet.KeySchnorr)
+	bobAlpha, bobBeta := bob.AddWallet(wallet.KeySecp256k1), bob.AddWallet(wallet.KeyEd25519)
+
+	alpha, err := NewBlockChain(NewMemoryStore(), aliceAlpha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alpha.Close()
+
+	beta, err := NewBlockChain(NewMemoryStore(), bobBeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer beta.Close()
+
+	// Alice locks coins for Bob on alpha behind a secret only she knows.
+	preimage := []byte("alice's secret")
+	hash := sha256.Sum256(preimage)
+
+	alphaHTLC, err := NewHTLCTransaction(aliceAlpha, bobAlpha, 40, hash[:], 10, alpha, alice, LargestFirst{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitMined(t, alpha, alphaHTLC)
+
+	// Bob locks coins for Alice on beta with the same hash and a shorter
+	// timeout.
+	betaHTLC, err := NewHTLCTransaction(bobBeta, aliceBeta, 60, hash[:], 5, beta, bob, LargestFirst{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitMined(t, beta, betaHTLC)
+
+	if _, err := beta.FindHTLCPreimage(betaHTLC.ID, 0); err == nil {
+		t.Fatal("found the preimage of an unclaimed HTLC")
+	}
+	if _, err := NewHTLCClaimTransaction(betaHTLC.ID, 0, []byte("guess"), aliceBeta, beta, alice); err == nil {
+		t.Fatal("claimed an HTLC with the wrong preimage")
+	}
+	if _, err := NewHTLCClaimTransaction(betaHTLC.ID, 0, preimage, bobBeta, beta, bob); err == nil {
+		t.Fatal("the sender claimed an HTLC")
+	}
+
+	// Alice claims on beta, revealing the preimage.
+	claim, err := NewHTLCClaimTransaction(betaHTLC.ID, 0, preimage, aliceBeta, beta, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitMined(t, beta, claim)
+
+	// Bob learns the preimage from beta and claims on alpha.
+	revealed, err := beta.FindHTLCPreimage(betaHTLC.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(revealed, preimage) {
+		t.Fatalf("revealed preimage = %q, want %q", revealed, preimage)
+	}
+
+	claim, err = NewHTLCClaimTransaction(alphaHTLC.ID, 0, revealed, bobAlpha, alpha, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitMined(t, alpha, claim)
+
+	if got := balance(alpha, bobAlpha); got != 40 {
+		t.Errorf("Bob has %d on alpha, want 40", got)
+	}
+	if got := balance(beta, aliceBeta); got != 60 {
+		t.Errorf("Alice has %d on beta, want 60", got)
+	}
+	if got := balance(alpha, aliceAlpha); got != BlockSubsidy-40 {
+		t.Errorf("Alice has %d left on alpha, want %d", got, BlockSubsidy-40)
+	}
+}
+
+func TestHTLCRefund(t *testing.T) {
+	wallets := newTestWallets()
+	sender, recipient := wallets.AddWallet(wallet.KeyP256), wallets.AddWallet(wallet.KeyP256)
+
+	chain, err := NewBlockChain(NewMemoryStore(), sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	hash := sha256.Sum256([]byte("never revealed"))
+	timeout := int64(4)
+
+	htlc, err := NewHTLCTransaction(sender, recipient, 40, hash[:], timeout, chain, wallets, LargestFirst{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitMined(t, chain, htlc)
+
+	if _, err := NewHTLCRefundTransaction(htlc.ID, 0, recipient, chain, wallets); err == nil {
+		t.Fatal("the recipient refunded an HTLC")
+	}
+
+	// The refund waits in the mempool until the timeout height.
+	refund, err := NewHTLCRefundTransaction(htlc.ID, 0, sender, chain, wallets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mined, err := chain.SubmitTransaction(refund)
+	if err != nil || mined {
+		t.Fatalf("SubmitTransaction of an early refund = %v, %v, want pending", mined, err)
+	}
+
+	for chain.GetBestHeight() < int(timeout) {
+		chain.MineBlock(recipient)
+	}
+
+	if got := balance(chain, sender); got != BlockSubsidy {
+		t.Errorf("sender has %d after the refund, want %d", got, BlockSubsidy)
+	}
+	if _, err := chain.FindHTLCPreimage(htlc.ID, 0); err == nil {
+		t.Error("found a preimage of a refunded HTLC")
+	}
+}
@@ -0,0 +1,201 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+)
+
+// Iterator walks the chain from the tip back to genesis.
+func (c *BlockChain) Iterator() *BlockChainIterator {
+	return &BlockChainIterator{
+		CurrentHash:  c.LastHash,
+		CurrentBlock: nil,
+		Store:        c.Store,
+	}
+}
+
+// BlockChainIterator walks blocks from the tip back to genesis. Next returns
+// false after genesis or on the first error, which Err reports; a pruned
+// block ends the walk with ErrPruned.
+type BlockChainIterator struct {
+	CurrentBlock *Block
+	Store        ChainStore
+	CurrentHash  []byte
+	err          error
+}
+
+func (i *BlockChainIterator) Next() bool {
+	if i.err != nil || len(i.CurrentHash) == 0 {
+		return false
+	}
+
+	block, err := getBlock(i.Store, i.CurrentHash)
+	if err != nil {
+		i.err = err
+		i.CurrentBlock = nil
+		return false
+	}
+
+	i.CurrentHash = block.PrevHash
+	i.CurrentBlock = block
+
+	return true
+}
+
+func (i *BlockChainIterator) Value() *Block {
+	return i.CurrentBlock
+}
+
+// Err returns the error that ended the walk, or nil if it reached genesis
+// or has not ended yet.
+func (i *BlockChainIterator) Err() error {
+	return i.err
+}
+
+// All returns the remaining blocks as a sequence for a range loop. Check Err
+// after the loop.
+func (i *BlockChainIterator) All() iter.Seq[*Block] {
+	return func(yield func(*Block) bool) {
+		for i.Next() {
+			if !yield(i.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// RangeIterator walks the blocks from height from to height to, inclusive,
+// in height order. A negative to walks up to the tip. The range is fixed to
+// the tip at the time of the call; blocks added later are not included. An
+// invalid range or a pruned block ends the walk with an error from Err.
+//
+// The iterator reads one block at a time and holds nothing open, so a caller
+// may stop calling Next at any point.
+func (c *BlockChain) RangeIterator(from, to int) *RangeIterator {
+	best := c.GetBestHeight()
+	if to < 0 || to > best {
+		to = best
+	}
+
+	it := &RangeIterator{
+		chain:  c,
+		height: from,
+		to:     to,
+	}
+	if from < 0 || from > to {
+		it.err = fmt.Errorf("invalid height range %d..%d, the tip is at height %d", from, to, best)
+	}
+
+	return it
+}
+
+// ForwardIterator walks every block from genesis up to the tip.
+func (c *BlockChain) ForwardIterator() *RangeIterator {
+	return c.RangeIterator(0, -1)
+}
+
+// HeaderIterator is like RangeIterator, but reads only the block headers,
+// so it can also walk pruned blocks. Value returns nil on it; use Header.
+func (c *BlockChain) HeaderIterator(from, to int) *RangeIterator {
+	it := c.RangeIterator(from, to)
+	it.headersOnly = true
+
+	return it
+}
+
+type RangeIterator struct {
+	chain       *BlockChain
+	height      int
+	to          int
+	headersOnly bool
+
+	block  *Block
+	header *BlockHeader
+	err    error
+}
+
+func (i *RangeIterator) Next() bool {
+	i.block = nil
+	i.header = nil
+	if i.err != nil || i.height > i.to {
+		return false
+	}
+
+	i.header, i.err = i.chain.GetHeaderByHeight(i.height)
+	if i.err != nil {
+		i.header = nil
+		return false
+	}
+
+	if !i.headersOnly {
+		i.block, i.err = i.chain.GetBlock(i.header.Hash)
+		if i.err != nil {
+			i.block = nil
+			i.header = nil
+			return false
+		}
+	}
+
+	i.height++
+
+	return true
+}
+
+// Value returns the current block, or nil on a header iterator.
+func (i *RangeIterator) Value() *Block {
+	return i.block
+}
+
+func (i *RangeIterator) Header() *BlockHeader {
+	return i.header
+}
+
+// Err returns the error that ended the walk, or nil if it reached the end
+// of the range or has not ended yet.
+func (i *RangeIterator) Err() error {
+	return i.err
+}
+
+// All returns the remaining blocks as a sequence for a range loop. Check Err
+// after the loop.
+func (i *RangeIterator) All() iter.Seq[*Block] {
+	return func(yield func(*Block) bool) {
+		for i.Next() {
+			if !yield(i.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// Headers returns the headers of the remaining blocks as a sequence for a
+// range loop. Check Err after the loop.
+func (i *RangeIterator) Headers() iter.Seq[*BlockHeader] {
+	return func(yield func(*BlockHeader) bool) {
+		for i.Next() {
+			if !yield(i.Header()) {
+				return
+			}
+		}
+	}
+}
+
+// GetHeaderByHeight returns the header of the block at height on the
+// current chain.
+func (c *BlockChain) GetHeaderByHeight(height int) (*BlockHeader, error) {
+	if height < 0 || height > c.GetBestHeight() {
+		return nil, fmt.Errorf("no block at height %d: %w", height, ErrNotFound)
+	}
+
+	hash, err := c.Store.GetIndex(heightKey(height))
+	if errors.Is(err, ErrNotFound) && height <= c.PrunedHeight() {
+		// A chain loaded from a UTXO snapshot has no headers below it.
+		return nil, fmt.Errorf("header at height %d: %w", height, ErrPruned)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetHeader(hash)
+}
@@ -0,0 +1,98 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRangeIterator(t *testing.T) {
+	chain, w := newTestChain(t)
+	for range 5 {
+		chain.MineBlock(string(w.Address()))
+	}
+
+	hashes := [][]byte{}
+	for it := chain.Iterator(); it.Next(); {
+		hashes = append([][]byte{it.Value().Hash}, hashes...)
+	}
+
+	tests := []struct {
+		name     string
+		from, to int
+		want     []int
+	}{
+		{"whole chain", 0, -1, []int{0, 1, 2, 3, 4, 5}},
+		{"middle", 2, 4, []int{2, 3, 4}},
+		{"single block", 3, 3, []int{3}},
+		{"past the tip", 4, 10, []int{4, 5}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, headersOnly := range []bool{false, true} {
+				it := chain.RangeIterator(test.from, test.to)
+				if headersOnly {
+					it = chain.HeaderIterator(test.from, test.to)
+				}
+
+				got := []int{}
+				for it.Next() {
+					header := it.Header()
+					if !bytes.Equal(header.Hash, hashes[header.Height]) {
+						t.Fatalf("header at height %d is %x, want %x", header.Height, header.Hash, hashes[header.Height])
+					}
+					if !headersOnly && !bytes.Equal(it.Value().Hash, header.Hash) {
+						t.Fatalf("block at height %d does not match its header", header.Height)
+					}
+					got = append(got, header.Height)
+				}
+				if err := it.Err(); err != nil {
+					t.Fatal(err)
+				}
+
+				if len(got) != len(test.want) {
+					t.Fatalf("heights = %v, want %v", got, test.want)
+				}
+				for idx := range got {
+					if got[idx] != test.want[idx] {
+						t.Fatalf("heights = %v, want %v", got, test.want)
+					}
+				}
+			}
+		})
+	}
+
+	for _, bounds := range [][2]int{{-1, 3}, {4, 2}, {7, -1}} {
+		it := chain.RangeIterator(bounds[0], bounds[1])
+		if it.Next() || it.Err() == nil {
+			t.Errorf("range %d..%d did not fail", bounds[0], bounds[1])
+		}
+	}
+
+	it := chain.ForwardIterator()
+	chain.MineBlock(string(w.Address()))
+	count := 0
+	for range it.All() {
+		count++
+	}
+	if count != len(hashes) {
+		t.Errorf("iterator walked %d blocks, want the %d at the time it was made", count, len(hashes))
+	}
+}
+
+func TestGetHeaderByHeight(t *testing.T) {
+	chain, w := newTestChain(t)
+	block := chain.MineBlock(string(w.Address()))
+
+	header, err := chain.GetHeaderByHeight(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(header.Hash, block.Hash) || header.Size != len(block.Serialize()) {
+		t.Fatalf("header at height 1 does not describe block %x", block.Hash)
+	}
+
+	if _, err := chain.GetHeaderByHeight(2); err == nil {
+		t.Error("found a header above the tip")
+	}
+}
@@ -0,0 +1,178 @@
+package blockchain
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore is a ChainStore kept in memory, for tests and simulations that
+// should not touch the disk. Blocks are stored serialized, so callers never
+// share them with the store.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	blocks map[string][]byte
+	index  map[string][]byte
+	tip    []byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		blocks: map[string][]byte{},
+		index:  map[string][]byte{},
+	}
+}
+
+func (s *MemoryStore) GetBlock(hash []byte) (*Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.blocks[string(hash)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return Deserialize(data), nil
+}
+
+func (s *MemoryStore) Tip() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.tip == nil {
+		return nil, ErrNotFound
+	}
+
+	return bytes.Clone(s.tip), nil
+}
+
+func (s *MemoryStore) GetIndex(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.index[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return bytes.Clone(value), nil
+}
+
+func (s *MemoryStore) ScanIndex(prefix []byte, fn func(key, value []byte) error) error {
+	s.mu.RLock()
+	keys := []string{}
+	values := map[string][]byte{}
+	for key, value := range s.index {
+		if strings.HasPrefix(key, string(prefix)) {
+			keys = append(keys, key)
+			values[key] = bytes.Clone(value)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		err := fn([]byte(key), values[key])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Update(fn func(batch StoreBatch) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := &memoryBatch{
+		store:  s,
+		blocks: map[string][]byte{},
+		index:  map[string][]byte{},
+	}
+
+	err := fn(batch)
+	if err != nil {
+		return err
+	}
+
+	for hash, data := range batch.blocks {
+		if data == nil {
+			delete(s.blocks, hash)
+			continue
+		}
+		s.blocks[hash] = data
+	}
+	for key, value := range batch.index {
+		if value == nil {
+			delete(s.index, key)
+			continue
+		}
+		s.index[key] = value
+	}
+	if batch.tip != nil {
+		s.tip = batch.tip
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// memoryBatch holds writes until the Update that created it succeeds. A nil
+// block or index value marks a deleted key.
+type memoryBatch struct {
+	store  *MemoryStore
+	blocks map[string][]byte
+	index  map[string][]byte
+	tip    []byte
+}
+
+func (b *memoryBatch) PutBlock(block *Block) error {
+	b.blocks[string(block.Hash)] = block.Serialize()
+
+	return nil
+}
+
+func (b *memoryBatch) DeleteBlock(hash []byte) error {
+	b.blocks[string(hash)] = nil
+
+	return nil
+}
+
+func (b *memoryBatch) SetTip(hash []byte) error {
+	b.tip = bytes.Clone(hash)
+
+	return nil
+}
+
+func (b *memoryBatch) GetIndex(key []byte) ([]byte, error) {
+	value, ok := b.index[string(key)]
+	if !ok {
+		value, ok = b.store.index[string(key)]
+	}
+	if !ok || value == nil {
+		return nil, ErrNotFound
+	}
+
+	return bytes.Clone(value), nil
+}
+
+func (b *memoryBatch) PutIndex(key, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	b.index[string(key)] = bytes.Clone(value)
+
+	return nil
+}
+
+func (b *memoryBatch) DeleteIndex(key []byte) error {
+	b.index[string(key)] = nil
+
+	return nil
+}
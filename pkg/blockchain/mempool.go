@@ -0,0 +1,127 @@
+package blockchain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/utils"
+)
+
+const mempoolPrefix = "mempool-"
+
+// AddToMempool stores a signed transaction that is waiting to be mined, for
+// example because its LockTime has not been reached yet. It must be valid at
+// the next block apart from its time locks.
+func (c *BlockChain) AddToMempool(tx *Transaction) error {
+	err := c.CheckTransaction(tx, c.GetBestHeight()+1, time.Now().Unix(), map[string]bool{})
+	if err != nil && !isNotFinal(err) {
+		return err
+	}
+
+	pending := c.mempoolSpentOutputs()
+	for _, txIn := range tx.Inputs {
+		if pending[outpointKey(txIn.ID, txIn.Out)] {
+			return fmt.Errorf("transaction %x conflicts with the mempool", tx.ID)
+		}
+	}
+
+	err = c.Store.Update(func(batch StoreBatch) error {
+		return batch.PutIndex(mempoolKey(tx.ID), tx.Serialize())
+	})
+	if err != nil {
+		return err
+	}
+
+	c.events.publish(TxAccepted{Tx: tx})
+
+	return nil
+}
+
+// SubmitTransaction mines tx right away if it is final at the next block and
+// otherwise keeps it in the mempool. It reports whether tx was mined. A block
+// mined here has no coinbase, so any fee tx leaves is burned.
+func (c *BlockChain) SubmitTransaction(tx *Transaction) (bool, error) {
+	if !tx.IsFinal(c.GetBestHeight()+1, time.Now().Unix()) {
+		return false, c.AddToMempool(tx)
+	}
+
+	block, err := c.nextBlock([]*Transaction{tx})
+	if err != nil {
+		return false, err
+	}
+
+	err = c.AcceptBlock(block)
+	if isNotFinal(err) {
+		return false, c.AddToMempool(tx)
+	}
+
+	return err == nil, err
+}
+
+func (c *BlockChain) MempoolTransactions() []*Transaction {
+	txs := []*Transaction{}
+
+	err := c.Store.ScanIndex([]byte(mempoolPrefix), func(key, value []byte) error {
+		txs = append(txs, DeserializeTransaction(value))
+		return nil
+	})
+	utils.HandleError(err)
+
+	return txs
+}
+
+// MineBlock mines a block paying the reward to address and including every
+// mempool transaction that is final at the new height. Mempool transactions
+// that can no longer be mined are dropped.
+func (c *BlockChain) MineBlock(address string) *Block {
+	height := c.GetBestHeight() + 1
+	now := time.Now().Unix()
+
+	txs := []*Transaction{
+		CoinbaseTx(address, fmt.Sprintf("Reward for block %d at %d", height, now)),
+	}
+
+	spent := map[string]bool{}
+	for _, tx := range c.MempoolTransactions() {
+		err := c.CheckTransaction(tx, height, now, spent)
+		if isNotFinal(err) {
+			continue
+		}
+		if err != nil {
+			c.removeFromMempool(tx)
+			continue
+		}
+
+		txs = append(txs, tx)
+		for _, txIn := range tx.Inputs {
+			spent[outpointKey(txIn.ID, txIn.Out)] = true
+		}
+	}
+
+	return c.AddBlock(txs)
+}
+
+func (c *BlockChain) removeFromMempool(tx *Transaction) {
+	err := c.Store.Update(func(batch StoreBatch) error {
+		return batch.DeleteIndex(mempoolKey(tx.ID))
+	})
+	utils.HandleError(err)
+
+	c.events.publish(TxRemovedFromMempool{Tx: tx, Reason: RemovedInvalid})
+}
+
+func (c *BlockChain) mempoolSpentOutputs() map[string]bool {
+	spent := map[string]bool{}
+
+	for _, tx := range c.MempoolTransactions() {
+		for _, txIn := range tx.Inputs {
+			spent[outpointKey(txIn.ID, txIn.Out)] = true
+		}
+	}
+
+	return spent
+}
+
+func mempoolKey(txID []byte) []byte {
+	return append([]byte(mempoolPrefix), txID...)
+}
@@ -0,0 +1,124 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// Aggregated key inputs of a partial transaction are signed in two MuSig
+// rounds, each run by every cosigner on its own host: CommitNonces adds the
+// public nonces, and once the file carries all of them SignPartial adds the
+// partial signatures. The secret nonces stay in each cosigner's wallets file
+// between the rounds.
+
+// CommitNonces adds a MuSig nonce from signer to every aggregated key input
+// it is one of the keys of, and remembers the secret nonces in wallets. It
+// returns the number of nonces added. Wallets must be saved before the
+// partial transaction is passed on.
+func (p *PartialTransaction) CommitNonces(signer wallet.Signer, wallets *wallet.Wallets) (int, error) {
+	committed := 0
+
+	for idx := range p.Tx.Inputs {
+		in := &p.Inputs[idx]
+
+		keyIdx := aggregateKeyIndex(in, signer)
+		if keyIdx < 0 || len(in.Nonces[keyIdx]) != 0 {
+			continue
+		}
+
+		hash := p.Tx.SigHash(idx, p.PrevOutputs[idx])
+
+		session, err := wallet.NewMuSigSession(signer, in.AggregateKeys, hash)
+		if err != nil {
+			return committed, fmt.Errorf("input %d: %w", idx, err)
+		}
+
+		wallets.PutMuSigNonce(signer.PublicKey(), hash, session.SecretNonce())
+		in.Nonces[keyIdx] = session.PublicNonce()
+		committed++
+	}
+
+	return committed, nil
+}
+
+// SignPartial adds a partial signature from signer to every aggregated key
+// input it committed a nonce to, once all cosigners have committed theirs.
+// It returns the number of partial signatures added. The secret nonces are
+// removed from wallets, which must be saved before the partial transaction
+// is passed on, so a nonce is never used twice.
+func (p *PartialTransaction) SignPartial(signer wallet.Signer, wallets *wallet.Wallets) (int, error) {
+	signed := 0
+
+	for idx := range p.Tx.Inputs {
+		in := &p.Inputs[idx]
+
+		keyIdx := aggregateKeyIndex(in, signer)
+		if keyIdx < 0 || len(in.Partials[keyIdx]) != 0 {
+			continue
+		}
+
+		if count := countFilled(in.Nonces); count < len(in.AggregateKeys) {
+			return signed, fmt.Errorf("input %d has %d of %d nonces", idx, count, len(in.AggregateKeys))
+		}
+
+		hash := p.Tx.SigHash(idx, p.PrevOutputs[idx])
+
+		secretNonce, ok := wallets.TakeMuSigNonce(signer.PublicKey(), hash)
+		if !ok {
+			return signed, fmt.Errorf("input %d: no nonce of this key is waiting to sign", idx)
+		}
+
+		session, err := wallet.ResumeMuSigSession(signer, in.AggregateKeys, hash, secretNonce)
+		if err != nil {
+			return signed, fmt.Errorf("input %d: %w", idx, err)
+		}
+
+		partial, err := session.Sign(in.Nonces)
+		if err != nil {
+			return signed, fmt.Errorf("input %d: %w", idx, err)
+		}
+
+		in.Partials[keyIdx] = partial
+		signed++
+	}
+
+	return signed, nil
+}
+
+// aggregateUnlock combines the partial signatures of an aggregated key input
+// into its unlocking script.
+func (p *PartialTransaction) aggregateUnlock(idx int) (Script, error) {
+	in := p.Inputs[idx]
+
+	if count := countFilled(in.Partials); count < len(in.AggregateKeys) {
+		return nil, fmt.Errorf("input %d has %d of %d partial signatures", idx, count, len(in.AggregateKeys))
+	}
+
+	aggKey, err := wallet.AggregatePublicKeys(in.AggregateKeys)
+	if err != nil {
+		return nil, fmt.Errorf("input %d: %w", idx, err)
+	}
+
+	signature, err := wallet.AggregateMuSig(in.AggregateKeys, p.Tx.SigHash(idx, p.PrevOutputs[idx]), in.Nonces, in.Partials)
+	if err != nil {
+		return nil, fmt.Errorf("input %d: %w", idx, err)
+	}
+
+	return PayToPubKeyHashUnlockScript(signature, aggKey), nil
+}
+
+// aggregateKeyIndex returns the position of signer among the keys of an
+// aggregated key input, or -1.
+func aggregateKeyIndex(in *PartialInput, signer wallet.Signer) int {
+	pubKey := signer.PublicKey()
+
+	for idx, key := range in.AggregateKeys {
+		if bytes.Equal(key, pubKey) {
+			return idx
+		}
+	}
+
+	return -1
+}
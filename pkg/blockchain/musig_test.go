@@ -0,0 +1,141 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+func newTestWallets() *wallet.Wallets {
+	return &wallet.Wallets{
+		Wallets:     map[string]*wallet.Wallet{},
+		Scripts:     map[string][]byte{},
+		Aggregates:  map[string][][]byte{},
+		Labels:      map[string]string{},
+		Contacts:    map[string]string{},
+		MuSigNonces: map[string][]byte{},
+	}
+}
+
+// passPartial serializes p and reads it back, as when the file is handed to
+// the next cosigner.
+func passPartial(t *testing.T, p *PartialTransaction) *PartialTransaction {
+	t.Helper()
+
+	data, err := p.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err = DeserializePartialTransaction(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func balance(chain *BlockChain, address string) int {
+	parsed, _ := wallet.ParseAddress(address)
+
+	total := 0
+	for _, out := range chain.FindUTXO(LockingScript(parsed)) {
+		total += out.Value
+	}
+
+	return total
+}
+
+func TestMuSigRounds(t *testing.T) {
+	cosigners := []*wallet.Wallets{}
+	signers := []wallet.Signer{}
+	pubKeys := [][]byte{}
+	for range 3 {
+		wallets := newTestWallets()
+		w := wallet.NewWallet(wallet.KeySchnorr)
+		wallets.Add(w)
+
+		cosigners = append(cosigners, wallets)
+		signers = append(signers, w.PrivateKey)
+		pubKeys = append(pubKeys, w.PublicKey)
+	}
+
+	coordinator := newTestWallets()
+	aggAddress, err := coordinator.AddAggregate(pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := NewBlockChain(NewMemoryStore(), aggAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	to := string(wallet.NewWallet(wallet.KeyP256).Address())
+	start := balance(chain, aggAddress)
+
+	ptx, err := NewPartialTransaction(aggAddress, to, 60, chain, coordinator, LargestFirst{}, TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for idx, signer := range signers {
+		ptx = passPartial(t, ptx)
+
+		if idx > 0 {
+			if _, err := ptx.SignPartial(signer, cosigners[0]); err == nil {
+				t.Fatal("signed before all nonces were committed")
+			}
+		}
+
+		committed, err := ptx.CommitNonces(signer, cosigners[idx])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if committed != len(ptx.Inputs) {
+			t.Fatalf("cosigner %d committed %d nonces, want %d", idx, committed, len(ptx.Inputs))
+		}
+	}
+
+	if _, err := ptx.Finalize(); err == nil {
+		t.Fatal("finalized without partial signatures")
+	}
+
+	var unsigned *PartialTransaction
+	for idx, signer := range signers {
+		ptx = passPartial(t, ptx)
+		if idx == 0 {
+			unsigned = passPartial(t, ptx)
+		}
+
+		signed, err := ptx.SignPartial(signer, cosigners[idx])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signed != len(ptx.Inputs) {
+			t.Fatalf("cosigner %d signed %d inputs, want %d", idx, signed, len(ptx.Inputs))
+		}
+	}
+
+	if _, err := unsigned.SignPartial(signers[0], cosigners[0]); err == nil {
+		t.Fatal("signed twice with the same nonce")
+	}
+
+	tx, err := passPartial(t, ptx).Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := chain.SubmitTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	chain.MineBlock(to)
+
+	if got := balance(chain, aggAddress); got != start-60 {
+		t.Errorf("aggregated address balance = %d, want %d", got, start-60)
+	}
+	if got := balance(chain, to); got < 60 {
+		t.Errorf("recipient balance = %d, want at least 60", got)
+	}
+}
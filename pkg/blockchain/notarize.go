@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+const notarizationPrefix = "nt-"
+
+// Notarization records where a data output was anchored on chain.
+type Notarization struct {
+	Data      []byte
+	TxID      []byte
+	BlockHash []byte
+	Height    int
+	Timestamp int64
+}
+
+// NewDataTransaction anchors data on chain in an unspendable output, funded
+// and signed by the from address which gets all of its coins back as change.
+func NewDataTransaction(from string, data []byte, chain *BlockChain, wallets *wallet.Wallets, selector CoinSelector) (*Transaction, error) {
+	script, err := DataScript(data)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := wallets.GetWallet(from)
+	if err != nil {
+		return nil, err
+	}
+	pubKeyHash := wallet.PublicKeyHash(w.PublicKey)
+
+	payment := TxOutput{
+		Value:  0,
+		Script: script,
+	}
+
+	tx, err := newUnsignedTransaction(PayToPubKeyHashScript(pubKeyHash), from, payment, chain, selector, 0)
+	if err != nil {
+		return nil, err
+	}
+	err = chain.SignTransaction(tx, w.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (c *BlockChain) FindNotarization(data []byte) (*Notarization, error) {
+	encoded, err := c.Store.GetIndex(notarizationKey(data))
+	if err == ErrNotFound {
+		return nil, fmt.Errorf("%x is not notarized", data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeNotarization(encoded)
+}
+
+func decodeNotarization(encoded []byte) (*Notarization, error) {
+	var n Notarization
+
+	err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(&n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+func indexNotarizations(batch StoreBatch, block *Block) error {
+	for _, tx := range block.Transactions {
+		for _, out := range tx.Outputs {
+			data, ok := out.Script.Data()
+			if !ok {
+				continue
+			}
+
+			// Keep the earliest anchor of the same data.
+			if _, err := batch.GetIndex(notarizationKey(data)); err == nil {
+				continue
+			}
+
+			var encoded bytes.Buffer
+			err := gob.NewEncoder(&encoded).Encode(Notarization{
+				Data:      data,
+				TxID:      tx.ID,
+				BlockHash: block.Hash,
+				Height:    block.Height,
+				Timestamp: block.Timestamp,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = batch.PutIndex(notarizationKey(data), encoded.Bytes())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func notarizationKey(data []byte) []byte {
+	return append([]byte(notarizationPrefix), data...)
+}
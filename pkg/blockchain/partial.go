@@ -0,0 +1,292 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// PartialInput collects what is needed to unlock one input. Single key inputs
+// use one signature slot and record the signing key; multisig inputs carry
+// their redeem script and one slot per public key of it. Aggregated key
+// inputs have no signature slots; they carry the keys behind the aggregated
+// key and one MuSig nonce and partial signature slot per key.
+type PartialInput struct {
+	RedeemScript  Script
+	PubKey        []byte
+	Signatures    [][]byte
+	AggregateKeys [][]byte
+	Nonces        [][]byte
+	Partials      [][]byte
+}
+
+// PartialTransaction is an unsigned or partially signed transaction together
+// with the outputs it spends, so it can be signed on a host without the chain
+// database and passed between wallets until it is complete.
+type PartialTransaction struct {
+	Tx          Transaction
+	PrevOutputs []TxOutput
+	Inputs      []PartialInput
+}
+
+// NewPartialTransaction creates an unsigned transaction paying amount from a
+// single key, multisig or aggregated key address. Only the address is needed,
+// not its keys; the redeem script of a multisig address and the keys behind
+// an aggregated key are taken from wallets.
+func NewPartialTransaction(from, to string, amount int, chain *BlockChain, wallets *wallet.Wallets, selector CoinSelector, opts TxOptions) (*PartialTransaction, error) {
+	address, err := wallet.ParseAddress(from)
+	if err != nil {
+		return nil, err
+	}
+	lock := LockingScript(address)
+
+	var redeem Script
+	if address.IsScriptHash() {
+		script, ok := wallets.GetScript(from)
+		if !ok {
+			return nil, fmt.Errorf("redeem script of %s is not in the wallet", from)
+		}
+		redeem = script
+	}
+
+	aggKeys, _ := wallets.GetAggregate(from)
+
+	recipient, err := wallet.ParseAddress(to)
+	if err != nil {
+		return nil, err
+	}
+
+	payment := &TxOutput{Value: amount, Script: LockingScript(recipient)}
+	if opts.RelativeLock > 0 {
+		payment.Script = RelativeTimeLockScript(opts.RelativeLock, payment.Script)
+	}
+
+	tx, err := newUnsignedTransaction(lock, from, *payment, chain, selector, opts.LockTime)
+	if err != nil {
+		return nil, err
+	}
+
+	prevTXs, err := chain.PrevTransactions(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PartialTransaction{
+		Tx:          *tx,
+		PrevOutputs: []TxOutput{},
+		Inputs:      []PartialInput{},
+	}
+
+	for _, txIn := range tx.Inputs {
+		prevTX := prevTXs[hex.EncodeToString(txIn.ID)]
+		p.PrevOutputs = append(p.PrevOutputs, prevTX.Outputs[txIn.Out])
+
+		in := PartialInput{
+			RedeemScript: redeem,
+			Signatures:   make([][]byte, 1),
+		}
+		if redeem != nil {
+			_, pubKeys, ok := redeem.MultiSig()
+			if !ok {
+				return nil, fmt.Errorf("redeem script of %s is not a multisig script", from)
+			}
+			in.Signatures = make([][]byte, len(pubKeys))
+		}
+		if aggKeys != nil {
+			in.Signatures = [][]byte{}
+			in.AggregateKeys = aggKeys
+			in.Nonces = make([][]byte, len(aggKeys))
+			in.Partials = make([][]byte, len(aggKeys))
+		}
+		p.Inputs = append(p.Inputs, in)
+	}
+
+	return p, nil
+}
+
+func DeserializePartialTransaction(data []byte) (*PartialTransaction, error) {
+	var p PartialTransaction
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&p)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p.Inputs) != len(p.Tx.Inputs) || len(p.PrevOutputs) != len(p.Tx.Inputs) {
+		return nil, fmt.Errorf("partial transaction does not describe all %d inputs", len(p.Tx.Inputs))
+	}
+
+	for idx, in := range p.Inputs {
+		slots := 1
+		if in.RedeemScript != nil {
+			_, pubKeys, ok := in.RedeemScript.MultiSig()
+			if !ok {
+				return nil, fmt.Errorf("input %d has an unsupported redeem script", idx)
+			}
+			slots = len(pubKeys)
+		}
+		if in.AggregateKeys != nil {
+			if len(in.Nonces) != len(in.AggregateKeys) || len(in.Partials) != len(in.AggregateKeys) {
+				return nil, fmt.Errorf("input %d needs a nonce and partial signature slot for each of %d keys", idx, len(in.AggregateKeys))
+			}
+			slots = 0
+		}
+
+		if len(in.Signatures) != slots {
+			return nil, fmt.Errorf("input %d has %d signature slots, expected %d", idx, len(in.Signatures), slots)
+		}
+	}
+
+	return &p, nil
+}
+
+func (p *PartialTransaction) Serialize() ([]byte, error) {
+	var encoded bytes.Buffer
+
+	encoder := gob.NewEncoder(&encoded)
+	err := encoder.Encode(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return encoded.Bytes(), nil
+}
+
+// Sign adds a signature from privKey to every input it can unlock and returns
+// the number of signatures added. It only uses the previous outputs carried
+// by the partial transaction.
+func (p *PartialTransaction) Sign(privKey wallet.Signer) int {
+	pubKey := privKey.PublicKey()
+	pubKeyHash := wallet.PublicKeyHash(pubKey)
+	signed := 0
+
+	for idx := range p.Tx.Inputs {
+		prevOut := p.PrevOutputs[idx]
+		in := &p.Inputs[idx]
+
+		if in.AggregateKeys != nil {
+			continue
+		}
+
+		if in.RedeemScript == nil {
+			inner, _, _ := prevOut.Script.SplitTimeLock()
+			if !bytes.Equal(inner.PubKeyHash(), pubKeyHash) {
+				continue
+			}
+
+			in.PubKey = pubKey
+			in.Signatures[0] = signHash(privKey, p.Tx.SigHash(idx, prevOut))
+			signed++
+			continue
+		}
+
+		_, pubKeys, _ := in.RedeemScript.MultiSig()
+		for keyIdx, key := range pubKeys {
+			if !bytes.Equal(key, pubKey) {
+				continue
+			}
+
+			in.Signatures[keyIdx] = signHash(privKey, p.Tx.SigHash(idx, prevOut))
+			signed++
+		}
+	}
+
+	return signed
+}
+
+// Finalize builds the unlocking scripts once every input has enough
+// signatures and returns the completed transaction.
+func (p *PartialTransaction) Finalize() (*Transaction, error) {
+	tx := p.Tx
+
+	tx.Inputs = append([]TxInput{}, p.Tx.Inputs...)
+
+	for idx, in := range p.Inputs {
+		if in.AggregateKeys != nil {
+			unlock, err := p.aggregateUnlock(idx)
+			if err != nil {
+				return nil, err
+			}
+
+			tx.Inputs[idx].Script = unlock
+			continue
+		}
+
+		if in.RedeemScript == nil {
+			if len(in.Signatures[0]) == 0 {
+				return nil, fmt.Errorf("input %d is not signed", idx)
+			}
+
+			tx.Inputs[idx].Script = PayToPubKeyHashUnlockScript(in.Signatures[0], in.PubKey)
+			continue
+		}
+
+		m, _, ok := in.RedeemScript.MultiSig()
+		if !ok {
+			return nil, fmt.Errorf("input %d has no multisig redeem script", idx)
+		}
+
+		unlock := NewScript().AddOp(OpFalse)
+		count := 0
+		for _, sig := range in.Signatures {
+			if len(sig) == 0 || count == m {
+				continue
+			}
+			unlock = unlock.AddData(sig)
+			count++
+		}
+
+		if count < m {
+			return nil, fmt.Errorf("input %d has %d of %d required signatures", idx, count, m)
+		}
+
+		tx.Inputs[idx].Script = unlock.AddData(in.RedeemScript)
+	}
+
+	return &tx, nil
+}
+
+func (p *PartialTransaction) String() string {
+	var builder strings.Builder
+
+	builder.WriteString(p.Tx.String())
+
+	for idx, in := range p.Inputs {
+		builder.WriteString(fmt.Sprintf("  Spends %d:\n", idx))
+		builder.WriteString(fmt.Sprintf("    Value:      %d\n", p.PrevOutputs[idx].Value))
+		builder.WriteString(fmt.Sprintf("    Script:     %s\n", p.PrevOutputs[idx].Script))
+
+		if in.AggregateKeys != nil {
+			builder.WriteString(fmt.Sprintf("    Nonces:     %d of %d\n", countFilled(in.Nonces), len(in.AggregateKeys)))
+			builder.WriteString(fmt.Sprintf("    Partials:   %d of %d\n", countFilled(in.Partials), len(in.AggregateKeys)))
+			continue
+		}
+
+		count := countFilled(in.Signatures)
+
+		required := 1
+		if m, _, ok := in.RedeemScript.MultiSig(); ok {
+			required = m
+		}
+		builder.WriteString(fmt.Sprintf("    Signatures: %d of %d\n", count, required))
+	}
+
+	return builder.String()
+}
+
+// countFilled returns the number of non-empty slots.
+func countFilled(slots [][]byte) int {
+	count := 0
+	for _, slot := range slots {
+		if len(slot) != 0 {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+func TestMultiSigPartialSigning(t *testing.T) {
+	funders := newTestWallets()
+	funder := funders.AddWallet(wallet.KeyP256)
+
+	chain, err := NewBlockChain(NewMemoryStore(), funder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	// Each cosigner keeps their key in their own wallet; the coordinator
+	// only knows the redeem script.
+	cosigners := []*wallet.Wallets{}
+	pubKeys := [][]byte{}
+	for range 3 {
+		w := newTestWallets()
+		address := w.AddWallet(wallet.KeyP256)
+		cosigners = append(cosigners, w)
+		pubKeys = append(pubKeys, w.Wallets[address].PublicKey)
+	}
+	redeem, err := MultiSigScript(2, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coordinator := newTestWallets()
+	multisig := coordinator.AddScript(redeem)
+
+	submitMined(t, chain, NewTransaction(funder, multisig, 60, chain, funders, LargestFirst{}, TxOptions{}))
+
+	// createrawtx
+	payee := string(wallet.NewWallet(wallet.KeyP256).Address())
+	p, err := NewPartialTransaction(multisig, payee, 25, chain, coordinator, LargestFirst{}, TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Finalize(); err == nil || !strings.Contains(err.Error(), "0 of 2") {
+		t.Fatalf("Finalize without signatures = %v", err)
+	}
+
+	// signrawtx by the first and the third cosigner, each on their own copy.
+	sign := func(p *PartialTransaction, w *wallet.Wallets) *PartialTransaction {
+		t.Helper()
+
+		p = passPartial(t, p)
+		for _, key := range w.Wallets {
+			if signed := p.Sign(key.PrivateKey); signed != 1 {
+				t.Fatalf("signed %d inputs, want 1", signed)
+			}
+		}
+
+		return passPartial(t, p)
+	}
+
+	p = sign(p, cosigners[0])
+	if _, err := p.Finalize(); err == nil || !strings.Contains(err.Error(), "1 of 2") {
+		t.Fatalf("Finalize with one signature = %v", err)
+	}
+	if signed := p.Sign(funders.Wallets[funder].PrivateKey); signed != 0 {
+		t.Fatalf("a key outside the multisig signed %d inputs", signed)
+	}
+	p = sign(p, cosigners[2])
+
+	tx, err := p.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The signatures cover the outputs.
+	tampered := *tx
+	tampered.Outputs = append([]TxOutput{}, tx.Outputs...)
+	tampered.Outputs[0].Value--
+	tampered.ID = tampered.Hash()
+	if _, err := chain.SubmitTransaction(&tampered); err == nil {
+		t.Fatal("submitted a transaction changed after signing")
+	}
+
+	submitMined(t, chain, tx)
+	if got := balance(chain, payee); got != 25 {
+		t.Fatalf("payee has %d, want 25", got)
+	}
+	if got := balance(chain, multisig); got != 35 {
+		t.Fatalf("multisig address has %d, want the change of 35", got)
+	}
+}
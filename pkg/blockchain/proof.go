@@ -31,6 +31,8 @@ func (p *ProofOfWork) PrepareData(nonce int) []byte {
 		[][]byte{
 			p.Block.PrevHash,
 			p.Block.HashTransactions(),
+			utils.ToHex(p.Block.Timestamp),
+			utils.ToHex(int64(p.Block.Height)),
 			utils.ToHex(int64(nonce)),
 			utils.ToHex(int64(Difficulty)),
 		},
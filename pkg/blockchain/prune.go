@@ -0,0 +1,190 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PruneDepth is how many blocks below the tip are always kept whole, so that
+// a pruned node can still serve and reorganize recent blocks.
+const PruneDepth = 288
+
+var (
+	// ErrPruned is returned for operations that need block bodies a pruned
+	// node has deleted.
+	ErrPruned = errors.New("block data has been pruned")
+
+	pruneTargetKey  = []byte("prune-target")
+	prunedHeightKey = []byte("pruned-height")
+)
+
+// ServiceFlags tell peers what a node can serve, like Bitcoin's service
+// bits.
+type ServiceFlags uint64
+
+const (
+	// ServiceNetwork nodes hold every block.
+	ServiceNetwork ServiceFlags = 1 << 0
+	// ServiceNetworkLimited nodes hold at least the last PruneDepth blocks.
+	ServiceNetworkLimited ServiceFlags = 1 << 10
+)
+
+func (f ServiceFlags) String() string {
+	names := []string{}
+	if f&ServiceNetwork != 0 {
+		names = append(names, "network")
+	}
+	if f&ServiceNetworkLimited != 0 {
+		names = append(names, "network-limited")
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+
+	return strings.Join(names, ",")
+}
+
+// SetPruneTarget turns on pruning, keeping the stored block bodies under
+// targetMB megabytes where PruneDepth allows it, and prunes right away. A
+// target of 0 stops pruning; blocks pruned before stay pruned.
+func (c *BlockChain) SetPruneTarget(targetMB int) (int, error) {
+	err := SetPruneTarget(c.Store, targetMB)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.Prune()
+}
+
+// SetPruneTarget stores the prune target in store without pruning, so that
+// a chain created or imported into it is pruned while its blocks arrive.
+func SetPruneTarget(store ChainStore, targetMB int) error {
+	if targetMB < 0 {
+		return fmt.Errorf("prune target must not be negative")
+	}
+
+	return store.Update(func(batch StoreBatch) error {
+		if targetMB == 0 {
+			return batch.DeleteIndex(pruneTargetKey)
+		}
+
+		return batch.PutIndex(pruneTargetKey, binary.BigEndian.AppendUint64(nil, uint64(targetMB)))
+	})
+}
+
+// PruneTarget returns the prune target in megabytes, or 0 when pruning is
+// off.
+func (c *BlockChain) PruneTarget() int {
+	data, err := c.Store.GetIndex(pruneTargetKey)
+	if err != nil || len(data) != 8 {
+		return 0
+	}
+
+	return int(binary.BigEndian.Uint64(data))
+}
+
+// PrunedHeight returns the height of the newest pruned block, or -1 when no
+// block has been pruned.
+func (c *BlockChain) PrunedHeight() int {
+	data, err := c.Store.GetIndex(prunedHeightKey)
+	if err != nil || len(data) != 8 {
+		return -1
+	}
+
+	return int(binary.BigEndian.Uint64(data))
+}
+
+func (c *BlockChain) IsPruned() bool {
+	return c.PrunedHeight() >= 0
+}
+
+// Services returns the service flags the node advertises: a pruned node can
+// only serve recent blocks.
+func (c *BlockChain) Services() ServiceFlags {
+	if c.IsPruned() {
+		return ServiceNetworkLimited
+	}
+
+	return ServiceNetwork | ServiceNetworkLimited
+}
+
+// Prune deletes the bodies of the oldest blocks, keeping their headers,
+// until the remaining bodies fit the prune target. Blocks within PruneDepth
+// of the tip are never pruned. It returns the number of blocks pruned.
+func (c *BlockChain) Prune() (int, error) {
+	target := int64(c.PruneTarget()) << 20
+	if target == 0 {
+		return 0, nil
+	}
+
+	total, err := bodySize(c.Store)
+	if err != nil || total <= target {
+		return 0, err
+	}
+
+	from := c.PrunedHeight() + 1
+	keepFrom := c.GetBestHeight() - PruneDepth
+	pruned := 0
+
+	// Oldest first, from the first block that still has its body.
+	err = c.Store.Update(func(batch StoreBatch) error {
+		for height := from; height <= keepFrom && total > target; height++ {
+			hash, err := batch.GetIndex(heightKey(height))
+			if err != nil {
+				return fmt.Errorf("block at height %d: %w", height, err)
+			}
+
+			header, err := getHeader(batch, hash)
+			if err != nil {
+				return err
+			}
+
+			err = batch.DeleteBlock(hash)
+			if err != nil {
+				return err
+			}
+
+			err = batch.DeleteIndex(spentKey(hash))
+			if err != nil {
+				return err
+			}
+
+			err = batch.PutIndex(prunedHeightKey, binary.BigEndian.AppendUint64(nil, uint64(height)))
+			if err != nil {
+				return err
+			}
+
+			total -= int64(header.Size)
+			pruned++
+		}
+
+		return putBodySize(batch, total)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if collector, ok := c.Store.(interface{ CollectGarbage() error }); ok && pruned > 0 {
+		err = collector.CollectGarbage()
+	}
+
+	return pruned, err
+}
+
+// getBlock reads a block from store, telling a pruned block from one that
+// was never stored.
+func getBlock(store ChainStore, hash []byte) (*Block, error) {
+	block, err := store.GetBlock(hash)
+	if err != ErrNotFound {
+		return block, err
+	}
+
+	// Disconnected blocks keep their header too.
+	if header, headerErr := getHeader(store, hash); headerErr == nil && onChain(store, header) {
+		return nil, fmt.Errorf("block %x: %w", hash, ErrPruned)
+	}
+
+	return nil, err
+}
@@ -0,0 +1,158 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// addLargeBlocks adds count blocks of about 4 KB each to chain.
+func addLargeBlocks(t *testing.T, chain *BlockChain, address string, count int) {
+	t.Helper()
+
+	padding := strings.Repeat("x", 4096)
+	for range count {
+		height := chain.GetBestHeight() + 1
+		chain.AddBlock([]*Transaction{CoinbaseTx(address, fmt.Sprintf("%d %s", height, padding))})
+	}
+}
+
+// storedBodySize adds up the sizes of the block bodies still in chain.
+func storedBodySize(t *testing.T, chain *BlockChain) int64 {
+	t.Helper()
+
+	size := int64(0)
+	for height := chain.PrunedHeight() + 1; height <= chain.GetBestHeight(); height++ {
+		header, err := chain.GetHeaderByHeight(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		block, err := chain.GetBlock(header.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += int64(len(block.Serialize()))
+	}
+
+	return size
+}
+
+func checkBodySize(t *testing.T, chain *BlockChain) {
+	t.Helper()
+
+	size, err := bodySize(chain.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := storedBodySize(t, chain); size != want {
+		t.Fatalf("body size index = %d, stored bodies = %d", size, want)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	chain, w := newTestChain(t)
+	address := string(w.Address())
+
+	addLargeBlocks(t, chain, address, PruneDepth+31)
+	checkBodySize(t, chain)
+
+	pruned, err := chain.SetPruneTarget(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the blocks below PruneDepth may go, and all of them must to
+	// approach the target.
+	if pruned != 32 || chain.PrunedHeight() != 31 {
+		t.Fatalf("pruned %d blocks up to height %d, want 32 up to 31", pruned, chain.PrunedHeight())
+	}
+	checkBodySize(t, chain)
+
+	header, err := chain.GetHeaderByHeight(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.GetBlock(header.Hash); !errors.Is(err, ErrPruned) {
+		t.Fatalf("GetBlock of a pruned block = %v, want ErrPruned", err)
+	}
+
+	headers := 0
+	it := chain.HeaderIterator(0, -1)
+	for range it.Headers() {
+		headers++
+	}
+	if it.Err() != nil || headers != chain.GetBestHeight()+1 {
+		t.Fatalf("header iterator walked %d headers with error %v", headers, it.Err())
+	}
+
+	it = chain.ForwardIterator()
+	for range it.All() {
+	}
+	if !errors.Is(it.Err(), ErrPruned) {
+		t.Fatalf("block iterator over pruned blocks ended with %v, want ErrPruned", it.Err())
+	}
+
+	addLargeBlocks(t, chain, address, 1)
+	if chain.PrunedHeight() != 32 {
+		t.Fatalf("pruned height after a new block = %d, want 32", chain.PrunedHeight())
+	}
+	checkBodySize(t, chain)
+}
+
+func TestReindexHeaders(t *testing.T) {
+	chain, w := newTestChain(t)
+	addLargeBlocks(t, chain, string(w.Address()), 5)
+
+	want, err := bodySize(chain.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the index, as in a store written before it existed.
+	stale := [][]byte{indexTipKey, bodySizeKey}
+	for _, prefix := range []string{headerPrefix, heightPrefix} {
+		err := chain.Store.ScanIndex([]byte(prefix), func(key, value []byte) error {
+			stale = append(stale, key)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = chain.Store.Update(func(batch StoreBatch) error {
+		for _, key := range stale {
+			if err := batch.DeleteIndex(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err = OpenBlockChain(chain.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := bodySize(chain.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != want {
+		t.Fatalf("reindexed body size = %d, want %d", size, want)
+	}
+
+	for height := 0; height <= chain.GetBestHeight(); height++ {
+		header, err := chain.GetHeaderByHeight(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Height != height {
+			t.Fatalf("header at height %d has height %d", height, header.Height)
+		}
+	}
+}
@@ -0,0 +1,703 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+const (
+	OpFalse         byte = 0x00
+	OpPushData1     byte = 0x4c
+	OpPushData2     byte = 0x4d
+	OpTrue          byte = 0x51
+	Op16            byte = 0x60
+	OpIf            byte = 0x63
+	OpNotIf         byte = 0x64
+	OpElse          byte = 0x67
+	OpEndIf         byte = 0x68
+	OpVerify        byte = 0x69
+	OpReturn        byte = 0x6a
+	OpDrop          byte = 0x75
+	OpDup           byte = 0x76
+	OpSwap          byte = 0x7c
+	OpSize          byte = 0x82
+	OpEqual         byte = 0x87
+	OpEqualVerify   byte = 0x88
+	OpSha256        byte = 0xa8
+	OpHash160       byte = 0xa9
+	OpCheckSig      byte = 0xac
+	OpCheckMultiSig byte = 0xae
+	OpCheckLockTime byte = 0xb1
+	OpCheckSequence byte = 0xb2
+)
+
+const (
+	maxScriptSize = 10000
+	MaxDataSize   = 80
+)
+
+var opNames = map[byte]string{
+	OpFalse:         "OP_FALSE",
+	OpPushData1:     "OP_PUSHDATA1",
+	OpPushData2:     "OP_PUSHDATA2",
+	OpTrue:          "OP_TRUE",
+	OpIf:            "OP_IF",
+	OpNotIf:         "OP_NOTIF",
+	OpElse:          "OP_ELSE",
+	OpEndIf:         "OP_ENDIF",
+	OpVerify:        "OP_VERIFY",
+	OpReturn:        "OP_RETURN",
+	OpDrop:          "OP_DROP",
+	OpDup:           "OP_DUP",
+	OpSwap:          "OP_SWAP",
+	OpSize:          "OP_SIZE",
+	OpEqual:         "OP_EQUAL",
+	OpEqualVerify:   "OP_EQUALVERIFY",
+	OpSha256:        "OP_SHA256",
+	OpHash160:       "OP_HASH160",
+	OpCheckSig:      "OP_CHECKSIG",
+	OpCheckMultiSig: "OP_CHECKMULTISIG",
+	OpCheckLockTime: "OP_CHECKLOCKTIMEVERIFY",
+	OpCheckSequence: "OP_CHECKSEQUENCEVERIFY",
+}
+
+type Script []byte
+
+// SigChecker gives scripts access to the transaction spending them.
+type SigChecker interface {
+	CheckSig(sig, pubKey []byte) bool
+	CheckLockTime(lockTime int64) bool
+	CheckSequence(sequence int64) bool
+}
+
+type instruction struct {
+	Op   byte
+	Data []byte
+	End  int
+}
+
+func NewScript() Script {
+	return Script{}
+}
+
+func (s Script) AddOp(op byte) Script {
+	return append(s, op)
+}
+
+func (s Script) AddData(data []byte) Script {
+	size := len(data)
+
+	switch {
+	case size == 0:
+		return append(s, OpFalse)
+	case size < int(OpPushData1):
+		s = append(s, byte(size))
+	case size <= 0xff:
+		s = append(s, OpPushData1, byte(size))
+	default:
+		s = append(s, OpPushData2)
+		s = binary.LittleEndian.AppendUint16(s, uint16(size))
+	}
+
+	return append(s, data...)
+}
+
+func PayToPubKeyHashScript(pubKeyHash []byte) Script {
+	return NewScript().
+		AddOp(OpDup).
+		AddOp(OpHash160).
+		AddData(pubKeyHash).
+		AddOp(OpEqualVerify).
+		AddOp(OpCheckSig)
+}
+
+func PayToPubKeyHashUnlockScript(sig, pubKey []byte) Script {
+	return NewScript().AddData(sig).AddData(pubKey)
+}
+
+func PayToScriptHashScript(scriptHash []byte) Script {
+	return NewScript().
+		AddOp(OpHash160).
+		AddData(scriptHash).
+		AddOp(OpEqual)
+}
+
+// MultiSigScript returns a redeem script that is satisfied by signatures from
+// m of the given public keys, supplied in the same order as the keys after an
+// empty dummy element.
+func MultiSigScript(m int, pubKeys [][]byte) (Script, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > 16 {
+		return nil, fmt.Errorf("multisig needs between 1 and 16 public keys")
+	}
+	if m < 1 || m > len(pubKeys) {
+		return nil, fmt.Errorf("multisig threshold %d out of range 1..%d", m, len(pubKeys))
+	}
+
+	script := NewScript().AddOp(smallIntOp(m))
+	for idx, pubKey := range pubKeys {
+		if _, _, err := wallet.DecodePublicKey(pubKey); err != nil {
+			return nil, fmt.Errorf("multisig public key %d: %w", idx+1, err)
+		}
+		script = script.AddData(pubKey)
+	}
+
+	return script.AddOp(smallIntOp(len(pubKeys))).AddOp(OpCheckMultiSig), nil
+}
+
+// PubKeyHash returns the key hash locked by a pay-to-pubkey-hash script, or
+// nil for any other script.
+func (s Script) PubKeyHash() []byte {
+	ins, err := s.parse()
+	if err != nil || len(ins) != 5 {
+		return nil
+	}
+
+	if ins[0].Op != OpDup || ins[1].Op != OpHash160 || ins[3].Op != OpEqualVerify || ins[4].Op != OpCheckSig {
+		return nil
+	}
+
+	return ins[2].Data
+}
+
+// DataScript returns a provably unspendable script carrying data.
+func DataScript(data []byte) (Script, error) {
+	if len(data) > MaxDataSize {
+		return nil, fmt.Errorf("data output carries %d bytes, limit is %d", len(data), MaxDataSize)
+	}
+
+	return NewScript().AddOp(OpReturn).AddData(data), nil
+}
+
+// Data returns the data carried by a DataScript.
+func (s Script) Data() ([]byte, bool) {
+	ins, err := s.parse()
+	if err != nil || len(ins) != 2 || ins[0].Op != OpReturn || ins[1].Data == nil {
+		return nil, false
+	}
+
+	return ins[1].Data, true
+}
+
+// AbsoluteTimeLockScript wraps script so that it can only be spent by a
+// transaction whose LockTime is at least lockTime.
+func AbsoluteTimeLockScript(lockTime int64, script Script) Script {
+	return append(NewScript().
+		AddData(encodeNum(int(lockTime))).
+		AddOp(OpCheckLockTime).
+		AddOp(OpDrop), script...)
+}
+
+// RelativeTimeLockScript wraps script so that it can only be spent once the
+// output is buried under the given number of blocks.
+func RelativeTimeLockScript(blocks int, script Script) Script {
+	return append(NewScript().
+		AddData(encodeNum(blocks)).
+		AddOp(OpCheckSequence).
+		AddOp(OpDrop), script...)
+}
+
+// SplitTimeLock strips time lock wrappers from the front of the script and
+// returns the inner script with the absolute and relative locks found.
+func (s Script) SplitTimeLock() (Script, int64, int) {
+	lockTime := int64(0)
+	sequence := 0
+
+	for {
+		ins, err := s.parse()
+		if err != nil || len(ins) < 3 || ins[0].Data == nil || ins[2].Op != OpDrop {
+			return s, lockTime, sequence
+		}
+
+		switch ins[1].Op {
+		case OpCheckLockTime:
+			lockTime = decodeNum(ins[0].Data)
+		case OpCheckSequence:
+			sequence = int(decodeNum(ins[0].Data))
+		default:
+			return s, lockTime, sequence
+		}
+
+		s = s[ins[2].End:]
+	}
+}
+
+// ScriptHash returns the redeem script hash of a pay-to-script-hash script, or
+// nil for any other script.
+func (s Script) ScriptHash() []byte {
+	ins, err := s.parse()
+	if err != nil || len(ins) != 3 {
+		return nil
+	}
+
+	if ins[0].Op != OpHash160 || ins[1].Data == nil || ins[2].Op != OpEqual {
+		return nil
+	}
+
+	return ins[1].Data
+}
+
+// MultiSig returns the threshold and public keys of a multisig redeem script.
+func (s Script) MultiSig() (int, [][]byte, bool) {
+	ins, err := s.parse()
+	if err != nil || len(ins) < 4 || ins[len(ins)-1].Op != OpCheckMultiSig {
+		return 0, nil, false
+	}
+
+	m, okM := smallInt(ins[0].Op)
+	n, okN := smallInt(ins[len(ins)-2].Op)
+	if !okM || !okN || n != len(ins)-3 || m > n {
+		return 0, nil, false
+	}
+
+	pubKeys := [][]byte{}
+	for _, in := range ins[1 : len(ins)-2] {
+		if in.Data == nil {
+			return 0, nil, false
+		}
+		pubKeys = append(pubKeys, in.Data)
+	}
+
+	return m, pubKeys, true
+}
+
+// PushedData returns the data pushes of a script consisting only of pushes.
+func (s Script) PushedData() ([][]byte, bool) {
+	ins, err := s.parse()
+	if err != nil {
+		return nil, false
+	}
+
+	data := [][]byte{}
+	for _, in := range ins {
+		if in.Data == nil {
+			return nil, false
+		}
+		data = append(data, in.Data)
+	}
+
+	return data, true
+}
+
+func (s Script) String() string {
+	ins, err := s.parse()
+	if err != nil {
+		return fmt.Sprintf("[invalid script: %v]", err)
+	}
+
+	parts := []string{}
+	for _, in := range ins {
+		if in.Data != nil {
+			parts = append(parts, fmt.Sprintf("%x", in.Data))
+			continue
+		}
+
+		name, ok := opNames[in.Op]
+		if num, isNum := smallInt(in.Op); isNum && in.Op != OpTrue {
+			name, ok = fmt.Sprintf("OP_%d", num), true
+		}
+		if !ok {
+			name = fmt.Sprintf("OP_UNKNOWN_%02x", in.Op)
+		}
+		parts = append(parts, name)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (s Script) parse() ([]instruction, error) {
+	ins := []instruction{}
+
+	for pc := 0; pc < len(s); {
+		op := s[pc]
+		pc++
+
+		size := 0
+		switch {
+		case op == OpFalse:
+			ins = append(ins, instruction{Op: op, Data: []byte{}, End: pc})
+			continue
+		case op < OpPushData1:
+			size = int(op)
+		case op == OpPushData1:
+			if pc+1 > len(s) {
+				return nil, fmt.Errorf("truncated OP_PUSHDATA1")
+			}
+			size = int(s[pc])
+			pc++
+		case op == OpPushData2:
+			if pc+2 > len(s) {
+				return nil, fmt.Errorf("truncated OP_PUSHDATA2")
+			}
+			size = int(binary.LittleEndian.Uint16(s[pc:]))
+			pc += 2
+		default:
+			ins = append(ins, instruction{Op: op, End: pc})
+			continue
+		}
+
+		if pc+size > len(s) {
+			return nil, fmt.Errorf("push of %d bytes exceeds script length", size)
+		}
+		pc += size
+		ins = append(ins, instruction{Op: op, Data: s[pc-size : pc], End: pc})
+	}
+
+	return ins, nil
+}
+
+// VerifyScript runs the unlocking script followed by the locking script on a
+// shared stack and succeeds if the top of the stack is true afterwards. For
+// pay-to-script-hash locks the last item pushed by the unlocking script is
+// then run as the redeem script on the remaining items.
+func VerifyScript(unlock, lock Script, checker SigChecker) error {
+	if _, ok := unlock.PushedData(); !ok {
+		return fmt.Errorf("unlocking script must only push data")
+	}
+
+	stack := [][]byte{}
+
+	stack, err := evalScript(unlock, stack, checker)
+	if err != nil {
+		return err
+	}
+	unlockStack := append([][]byte{}, stack...)
+
+	stack, err = evalScript(lock, stack, checker)
+	if err != nil {
+		return err
+	}
+
+	if len(stack) == 0 || !asBool(stack[len(stack)-1]) {
+		return fmt.Errorf("script evaluated to false")
+	}
+
+	if lock.ScriptHash() == nil {
+		return nil
+	}
+
+	redeem := Script(unlockStack[len(unlockStack)-1])
+	stack, err = evalScript(redeem, unlockStack[:len(unlockStack)-1], checker)
+	if err != nil {
+		return err
+	}
+
+	if len(stack) == 0 || !asBool(stack[len(stack)-1]) {
+		return fmt.Errorf("redeem script evaluated to false")
+	}
+
+	return nil
+}
+
+func evalScript(script Script, stack [][]byte, checker SigChecker) ([][]byte, error) {
+	if len(script) > maxScriptSize {
+		return nil, fmt.Errorf("script is larger than %d bytes", maxScriptSize)
+	}
+
+	ins, err := script.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	pop := func() ([]byte, error) {
+		if len(stack) == 0 {
+			return nil, fmt.Errorf("stack underflow")
+		}
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return top, nil
+	}
+
+	// conds holds the state of nested OP_IF branches; instructions only run
+	// when every enclosing branch is being executed.
+	conds := []bool{}
+	executing := func() bool {
+		for _, cond := range conds {
+			if !cond {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, in := range ins {
+		switch in.Op {
+		case OpIf, OpNotIf:
+			cond := false
+			if executing() {
+				top, err := pop()
+				if err != nil {
+					return nil, err
+				}
+				cond = asBool(top) == (in.Op == OpIf)
+			}
+			conds = append(conds, cond)
+			continue
+		case OpElse:
+			if len(conds) == 0 {
+				return nil, fmt.Errorf("OP_ELSE without OP_IF")
+			}
+			conds[len(conds)-1] = !conds[len(conds)-1]
+			continue
+		case OpEndIf:
+			if len(conds) == 0 {
+				return nil, fmt.Errorf("OP_ENDIF without OP_IF")
+			}
+			conds = conds[:len(conds)-1]
+			continue
+		}
+
+		if !executing() {
+			continue
+		}
+
+		if in.Data != nil {
+			stack = append(stack, in.Data)
+			continue
+		}
+
+		if num, ok := smallInt(in.Op); ok {
+			stack = append(stack, encodeNum(num))
+			continue
+		}
+
+		switch in.Op {
+		case OpVerify:
+			top, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			if !asBool(top) {
+				return nil, fmt.Errorf("OP_VERIFY failed")
+			}
+		case OpReturn:
+			return nil, fmt.Errorf("OP_RETURN encountered")
+		case OpDrop:
+			if _, err := pop(); err != nil {
+				return nil, err
+			}
+		case OpDup:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("stack underflow")
+			}
+			stack = append(stack, stack[len(stack)-1])
+		case OpSwap:
+			if len(stack) < 2 {
+				return nil, fmt.Errorf("stack underflow")
+			}
+			stack[len(stack)-1], stack[len(stack)-2] = stack[len(stack)-2], stack[len(stack)-1]
+		case OpSize:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("stack underflow")
+			}
+			stack = append(stack, encodeNum(len(stack[len(stack)-1])))
+		case OpEqual, OpEqualVerify:
+			a, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			b, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			equal := bytes.Equal(a, b)
+			if in.Op == OpEqualVerify {
+				if !equal {
+					return nil, fmt.Errorf("OP_EQUALVERIFY failed")
+				}
+				continue
+			}
+			stack = append(stack, boolBytes(equal))
+		case OpSha256:
+			top, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			hash := sha256.Sum256(top)
+			stack = append(stack, hash[:])
+		case OpHash160:
+			top, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, wallet.PublicKeyHash(top))
+		case OpCheckSig:
+			pubKey, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			sig, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, boolBytes(checker.CheckSig(sig, pubKey)))
+		case OpCheckMultiSig:
+			n, err := popNum(pop)
+			if err != nil {
+				return nil, err
+			}
+			pubKeys := make([][]byte, n)
+			for i := n - 1; i >= 0; i-- {
+				if pubKeys[i], err = pop(); err != nil {
+					return nil, err
+				}
+			}
+
+			m, err := popNum(pop)
+			if err != nil {
+				return nil, err
+			}
+			if m > n {
+				return nil, fmt.Errorf("OP_CHECKMULTISIG needs %d of only %d keys", m, n)
+			}
+			sigs := make([][]byte, m)
+			for i := m - 1; i >= 0; i-- {
+				if sigs[i], err = pop(); err != nil {
+					return nil, err
+				}
+			}
+
+			// As in Bitcoin, one more item is popped below the signatures.
+			// It must be empty: anyone relaying the transaction could
+			// otherwise put anything there without invalidating it.
+			dummy, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			if len(dummy) != 0 {
+				return nil, fmt.Errorf("OP_CHECKMULTISIG dummy element must be empty")
+			}
+
+			// Signatures must appear in the same order as their keys, so
+			// each key is tried at most once.
+			matched := 0
+			for _, pubKey := range pubKeys {
+				if matched == len(sigs) {
+					break
+				}
+				if checker.CheckSig(sigs[matched], pubKey) {
+					matched++
+				}
+			}
+			stack = append(stack, boolBytes(matched == len(sigs)))
+		case OpCheckLockTime, OpCheckSequence:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("stack underflow")
+			}
+			top := stack[len(stack)-1]
+			if len(top) > 5 {
+				return nil, fmt.Errorf("time lock operand too large")
+			}
+			lock := decodeNum(top)
+			if lock < 0 {
+				return nil, fmt.Errorf("negative time lock")
+			}
+
+			if in.Op == OpCheckLockTime && !checker.CheckLockTime(lock) {
+				return nil, fmt.Errorf("OP_CHECKLOCKTIMEVERIFY failed")
+			}
+			if in.Op == OpCheckSequence && !checker.CheckSequence(lock) {
+				return nil, fmt.Errorf("OP_CHECKSEQUENCEVERIFY failed")
+			}
+		default:
+			return nil, fmt.Errorf("unknown opcode 0x%02x", in.Op)
+		}
+	}
+
+	if len(conds) != 0 {
+		return nil, fmt.Errorf("unbalanced OP_IF")
+	}
+
+	return stack, nil
+}
+
+func asBool(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func boolBytes(value bool) []byte {
+	if value {
+		return []byte{1}
+	}
+
+	return []byte{}
+}
+
+func smallIntOp(num int) byte {
+	return OpTrue + byte(num-1)
+}
+
+func smallInt(op byte) (int, bool) {
+	if op < OpTrue || op > Op16 {
+		return 0, false
+	}
+
+	return int(op-OpTrue) + 1, true
+}
+
+func popNum(pop func() ([]byte, error)) (int, error) {
+	data, err := pop()
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > 1 || (len(data) == 1 && data[0] > 16) {
+		return 0, fmt.Errorf("expected a small number on the stack")
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	return int(data[0]), nil
+}
+
+func decodeNum(data []byte) int64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	num := int64(0)
+	for i, b := range data {
+		num |= int64(b) << (8 * i)
+	}
+
+	// The most significant bit of the last byte is the sign.
+	last := data[len(data)-1]
+	if last&0x80 != 0 {
+		num &^= int64(0x80) << (8 * (len(data) - 1))
+		return -num
+	}
+
+	return num
+}
+
+// encodeNum returns the minimal little-endian encoding of num that decodeNum
+// reads back, with the sign in the most significant bit of the last byte.
+func encodeNum(num int) []byte {
+	negative := num < 0
+	if negative {
+		num = -num
+	}
+
+	buf := []byte{}
+	for ; num > 0; num >>= 8 {
+		buf = append(buf, byte(num))
+	}
+	if len(buf) > 0 && buf[len(buf)-1]&0x80 != 0 {
+		buf = append(buf, 0)
+	}
+	if negative {
+		buf[len(buf)-1] |= 0x80
+	}
+
+	return buf
+}
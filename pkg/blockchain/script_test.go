@@ -0,0 +1,236 @@
+package blockchain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// testChecker accepts "sig-" followed by the public key as its signature, and
+// time locks up to lockTime and sequence.
+type testChecker struct {
+	lockTime int64
+	sequence int64
+}
+
+func testSig(pubKey []byte) []byte {
+	return append([]byte("sig-"), pubKey...)
+}
+
+func (c testChecker) CheckSig(sig, pubKey []byte) bool {
+	return bytes.Equal(sig, testSig(pubKey))
+}
+
+func (c testChecker) CheckLockTime(lockTime int64) bool {
+	return lockTime <= c.lockTime
+}
+
+func (c testChecker) CheckSequence(sequence int64) bool {
+	return sequence <= c.sequence
+}
+
+func testPubKeys(n int) [][]byte {
+	pubKeys := [][]byte{}
+	for range n {
+		pubKeys = append(pubKeys, wallet.NewWallet(wallet.KeyP256).PublicKey)
+	}
+
+	return pubKeys
+}
+
+// pushes returns a script pushing every item, in order.
+func pushes(items ...[]byte) Script {
+	script := NewScript()
+	for _, item := range items {
+		script = script.AddData(item)
+	}
+
+	return script
+}
+
+func TestScriptNum(t *testing.T) {
+	tests := []struct {
+		num  int
+		want []byte
+	}{
+		{0, []byte{}},
+		{1, []byte{0x01}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x00}},
+		{255, []byte{0xff, 0x00}},
+		{256, []byte{0x00, 0x01}},
+		{-1, []byte{0x81}},
+		{-127, []byte{0xff}},
+		{-128, []byte{0x80, 0x80}},
+		{-256, []byte{0x00, 0x81}},
+		{500000000, []byte{0x00, 0x65, 0xcd, 0x1d}},
+		{-500000000, []byte{0x00, 0x65, 0xcd, 0x9d}},
+	}
+
+	for _, test := range tests {
+		got := encodeNum(test.num)
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("encodeNum(%d) = %x, want %x", test.num, got, test.want)
+		}
+		if back := decodeNum(got); back != int64(test.num) {
+			t.Errorf("decodeNum(encodeNum(%d)) = %d", test.num, back)
+		}
+	}
+}
+
+func TestMultiSig(t *testing.T) {
+	pubKeys := testPubKeys(3)
+	redeem, err := MultiSigScript(2, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := func(idx int) []byte { return testSig(pubKeys[idx]) }
+	dummy := []byte{}
+
+	tests := []struct {
+		name  string
+		items [][]byte
+		valid bool
+	}{
+		{"first and second", [][]byte{dummy, sig(0), sig(1)}, true},
+		{"first and third", [][]byte{dummy, sig(0), sig(2)}, true},
+		{"second and third", [][]byte{dummy, sig(1), sig(2)}, true},
+		{"out of order", [][]byte{dummy, sig(1), sig(0)}, false},
+		{"same signature twice", [][]byte{dummy, sig(0), sig(0)}, false},
+		{"one signature", [][]byte{dummy, sig(0)}, false},
+		{"bad signature", [][]byte{dummy, sig(0), []byte("forged")}, false},
+		{"no dummy", [][]byte{sig(0), sig(1)}, false},
+		{"non-empty dummy", [][]byte{{1}, sig(0), sig(1)}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyScript(pushes(test.items...), redeem, testChecker{})
+			if test.valid && err != nil {
+				t.Fatalf("rejected: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("accepted")
+			}
+		})
+	}
+
+	m, keys, ok := redeem.MultiSig()
+	if !ok || m != 2 || len(keys) != 3 || !bytes.Equal(keys[2], pubKeys[2]) {
+		t.Fatalf("MultiSig() = %d, %d keys, %v", m, len(keys), ok)
+	}
+	if _, err := MultiSigScript(4, pubKeys); err == nil {
+		t.Fatal("built a 4 of 3 multisig")
+	}
+}
+
+func TestPayToScriptHash(t *testing.T) {
+	pubKeys := testPubKeys(2)
+	redeem, err := MultiSigScript(2, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := PayToScriptHashScript(wallet.PublicKeyHash(redeem))
+
+	if !bytes.Equal(lock.ScriptHash(), wallet.PublicKeyHash(redeem)) {
+		t.Fatal("ScriptHash does not return the redeem script hash")
+	}
+
+	other, err := MultiSigScript(1, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		unlock Script
+		valid  bool
+	}{
+		{"signed", pushes([]byte{}, testSig(pubKeys[0]), testSig(pubKeys[1]), redeem), true},
+		{"redeem script fails", pushes([]byte{}, testSig(pubKeys[0]), []byte("forged"), redeem), false},
+		{"other redeem script", pushes([]byte{}, testSig(pubKeys[0]), other), false},
+		{"no redeem script", pushes([]byte{}, testSig(pubKeys[0]), testSig(pubKeys[1])), false},
+		{"not only pushes", pushes([]byte{}, testSig(pubKeys[0]), testSig(pubKeys[1])).AddOp(OpDup).AddData(redeem), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyScript(test.unlock, lock, testChecker{})
+			if test.valid && err != nil {
+				t.Fatalf("rejected: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("accepted")
+			}
+		})
+	}
+}
+
+func TestTimeLocks(t *testing.T) {
+	inner := NewScript().AddOp(OpTrue)
+
+	tests := []struct {
+		name    string
+		lock    Script
+		checker testChecker
+		valid   bool
+	}{
+		{"lock time reached", AbsoluteTimeLockScript(100, inner), testChecker{lockTime: 100}, true},
+		{"lock time not reached", AbsoluteTimeLockScript(100, inner), testChecker{lockTime: 99}, false},
+		{"lock time as a timestamp", AbsoluteTimeLockScript(1700000000, inner), testChecker{lockTime: 1700000000}, true},
+		{"negative lock time", AbsoluteTimeLockScript(-1, inner), testChecker{lockTime: 100}, false},
+		{"sequence reached", RelativeTimeLockScript(5, inner), testChecker{sequence: 5}, true},
+		{"sequence not reached", RelativeTimeLockScript(5, inner), testChecker{sequence: 4}, false},
+		{"negative sequence", RelativeTimeLockScript(-5, inner), testChecker{sequence: 5}, false},
+		{"both reached", AbsoluteTimeLockScript(100, RelativeTimeLockScript(5, inner)), testChecker{lockTime: 100, sequence: 5}, true},
+		{"only lock time reached", AbsoluteTimeLockScript(100, RelativeTimeLockScript(5, inner)), testChecker{lockTime: 100, sequence: 4}, false},
+		{"missing operand", NewScript().AddOp(OpCheckLockTime), testChecker{lockTime: 100}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyScript(NewScript(), test.lock, test.checker)
+			if test.valid && err != nil {
+				t.Fatalf("rejected: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("accepted")
+			}
+		})
+	}
+
+	p2pkh := PayToPubKeyHashScript(wallet.PublicKeyHash(testPubKeys(1)[0]))
+	script, lockTime, sequence := AbsoluteTimeLockScript(500000, RelativeTimeLockScript(3, p2pkh)).SplitTimeLock()
+	if !bytes.Equal(script, p2pkh) || lockTime != 500000 || sequence != 3 {
+		t.Fatalf("SplitTimeLock = %s, %d, %d", script, lockTime, sequence)
+	}
+}
+
+func TestDataScript(t *testing.T) {
+	data := []byte("notarized document hash")
+
+	lock, err := DataScript(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := lock.Data(); !ok || !bytes.Equal(got, data) {
+		t.Fatalf("Data() = %q, %v, want %q", got, ok, data)
+	}
+
+	// Nothing unlocks an OP_RETURN output.
+	for _, unlock := range []Script{NewScript(), NewScript().AddOp(OpTrue), pushes(data)} {
+		if err := VerifyScript(unlock, lock, testChecker{}); err == nil {
+			t.Fatalf("unlocked a data output with %s", unlock)
+		}
+	}
+
+	if _, err := DataScript([]byte(strings.Repeat("x", MaxDataSize+1))); err == nil {
+		t.Fatal("built a data output above MaxDataSize")
+	}
+	if _, ok := PayToPubKeyHashScript(make([]byte, 20)).Data(); ok {
+		t.Fatal("found data in a pay-to-pubkey-hash script")
+	}
+}
@@ -0,0 +1,255 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// A chain snapshot is the magic "GBSNAP" and a version byte, then one record
+// per block in height order: a big-endian uint32 length and the serialized
+// block. A zero length ends the records and is followed by the SHA-256 of
+// everything before it.
+const (
+	snapshotMagic        = "GBSNAP"
+	snapshotVersion      = byte(1)
+	maxSnapshotBlockSize = 32 << 20
+)
+
+// ExportChain writes the blocks from height from to height to, inclusive,
+// as a snapshot. A negative to exports up to the tip. It returns the number
+// of blocks written.
+func (c *BlockChain) ExportChain(w io.Writer, from, to int) (int, error) {
+	best := c.GetBestHeight()
+	if to < 0 || to > best {
+		to = best
+	}
+	if from < 0 || from > to {
+		return 0, fmt.Errorf("invalid height range %d..%d, the tip is at height %d", from, to, best)
+	}
+
+	if pruned := c.PrunedHeight(); from <= pruned {
+		return 0, fmt.Errorf("blocks up to height %d are pruned, export from height %d or later: %w", pruned, pruned+1, ErrPruned)
+	}
+
+	hasher := sha256.New()
+	out := io.MultiWriter(w, hasher)
+
+	_, err := out.Write(append([]byte(snapshotMagic), snapshotVersion))
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	it := c.RangeIterator(from, to)
+	for block := range it.All() {
+		err = writeSnapshotRecord(out, block.Serialize())
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := it.Err(); err != nil {
+		return count, err
+	}
+
+	err = writeSnapshotRecord(out, nil)
+	if err != nil {
+		return count, err
+	}
+
+	_, err = w.Write(hasher.Sum(nil))
+
+	return count, err
+}
+
+// ImportChain reads a snapshot into store. Every block goes through
+// AcceptBlock, so it is validated like a mined one; blocks the store
+// already holds are skipped. An empty store takes its genesis block from the
+// snapshot. The checksum can only be checked at the end, so a corrupted
+// snapshot may leave the blocks before the damage imported. It returns the
+// number of blocks added.
+//
+// A store bootstrapped with LoadUTXOSet has no blocks up to its snapshot.
+// Those blocks are validated in memory instead, and the UTXO set they lead
+// to must match the snapshot commitment.
+func ImportChain(store ChainStore, r io.Reader) (int, error) {
+	hasher := sha256.New()
+	reader := bufio.NewReader(r)
+	in := io.TeeReader(reader, hasher)
+
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return 0, fmt.Errorf("reading snapshot header: %w", err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return 0, fmt.Errorf("not a chain snapshot")
+	}
+	if header[len(snapshotMagic)] != snapshotVersion {
+		return 0, fmt.Errorf("unsupported snapshot version %d", header[len(snapshotMagic)])
+	}
+
+	var chain *BlockChain
+	if _, err := store.Tip(); err == nil {
+		chain, err = OpenBlockChain(store)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var verifier *snapshotVerifier
+	if info, err := getUTXOSnapshotInfo(store); err == nil && !info.Verified {
+		verifier = &snapshotVerifier{info: info}
+	}
+
+	imported := 0
+	for {
+		data, err := readSnapshotRecord(in)
+		if err != nil {
+			return imported, err
+		}
+		if data == nil {
+			break
+		}
+
+		block, err := decodeSnapshotBlock(data)
+		if err != nil {
+			return imported, err
+		}
+
+		if chain == nil {
+			chain, err = importGenesis(store, block)
+			if err != nil {
+				return imported, err
+			}
+			imported++
+			continue
+		}
+
+		if verifier != nil && block.Height <= verifier.info.Height {
+			if verifier.chain == nil && block.Height != 0 {
+				// History that does not start at genesis cannot be
+				// checked against the snapshot.
+				continue
+			}
+
+			verified, err := verifier.add(block)
+			if err != nil {
+				return imported, err
+			}
+			if verified {
+				err = markUTXOSnapshotVerified(store, verifier.info)
+				if err != nil {
+					return imported, err
+				}
+				verifier = nil
+			}
+			continue
+		}
+
+		if block.Height <= chain.PrunedHeight() {
+			continue
+		}
+
+		if block.Height <= chain.GetBestHeight() {
+			if header, err := chain.GetHeader(block.Hash); err != nil || !chain.OnChain(header) {
+				return imported, fmt.Errorf("block %x at height %d is not in the local chain", block.Hash, block.Height)
+			}
+			continue
+		}
+
+		err = chain.AcceptBlock(block)
+		if err != nil {
+			return imported, err
+		}
+		imported++
+	}
+
+	return imported, checkSnapshotChecksum(reader, hasher)
+}
+
+// importGenesis stores block as the first block of an empty store. It has no
+// parent to validate against, so only its shape and proof of work are
+// checked.
+func importGenesis(store ChainStore, block *Block) (*BlockChain, error) {
+	if block.Height != 0 || len(block.PrevHash) != 0 {
+		return nil, fmt.Errorf("snapshot starts at height %d, but the chain is empty", block.Height)
+	}
+	if len(block.Transactions) != 1 || !block.Transactions[0].IsCoinbase() {
+		return nil, fmt.Errorf("genesis block %x must hold exactly one coinbase transaction", block.Hash)
+	}
+
+	err := checkProofOfWork(block)
+	if err != nil {
+		return nil, err
+	}
+
+	err = storeGenesis(store, block)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenBlockChain(store)
+}
+
+func writeSnapshotRecord(w io.Writer, data []byte) error {
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(data)))
+
+	_, err := w.Write(append(length, data...))
+
+	return err
+}
+
+// readSnapshotRecord returns the next serialized block, or nil at the end
+// of the records.
+func readSnapshotRecord(r io.Reader) ([]byte, error) {
+	length := make([]byte, 4)
+	if _, err := io.ReadFull(r, length); err != nil {
+		return nil, fmt.Errorf("snapshot is truncated: %w", err)
+	}
+
+	size := binary.BigEndian.Uint32(length)
+	if size == 0 {
+		return nil, nil
+	}
+	if size > maxSnapshotBlockSize {
+		return nil, fmt.Errorf("snapshot record of %d bytes is too large", size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("snapshot is truncated: %w", err)
+	}
+
+	return data, nil
+}
+
+// decodeSnapshotBlock deserializes a block, returning an error instead of
+// panicking on damaged data.
+func decodeSnapshotBlock(data []byte) (block *Block, err error) {
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("snapshot holds a block that cannot be decoded")
+		}
+	}()
+
+	return Deserialize(data), nil
+}
+
+func checkSnapshotChecksum(r io.Reader, hasher hash.Hash) error {
+	checksum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(r, checksum); err != nil {
+		return fmt.Errorf("snapshot is missing its checksum: %w", err)
+	}
+
+	if !bytes.Equal(checksum, hasher.Sum(nil)) {
+		return fmt.Errorf("snapshot checksum does not match")
+	}
+
+	return nil
+}
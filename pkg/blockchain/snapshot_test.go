@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// testHistory returns a chain of three blocks: the genesis paying alice, a
+// block with a payment of 30 from alice to bob, and one more paying alice.
+func testHistory(t *testing.T) (*BlockChain, string, string) {
+	t.Helper()
+
+	wallets := newTestWallets()
+	alice, bob := wallets.AddWallet(wallet.KeyP256), wallets.AddWallet(wallet.KeyP256)
+
+	chain, err := NewBlockChain(NewMemoryStore(), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chain.Close() })
+
+	tx := NewTransaction(alice, bob, 30, chain, wallets, LargestFirst{}, TxOptions{})
+	if err := chain.AddToMempool(tx); err != nil {
+		t.Fatal(err)
+	}
+	chain.MineBlock(bob)
+	chain.MineBlock(alice)
+
+	return chain, alice, bob
+}
+
+func exportChain(t *testing.T, chain *BlockChain) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	count, err := chain.ExportChain(&buf, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != chain.GetBestHeight()+1 {
+		t.Fatalf("exported %d blocks, want %d", count, chain.GetBestHeight()+1)
+	}
+
+	return buf.Bytes()
+}
+
+func TestChainSnapshotRoundTrip(t *testing.T) {
+	chain, alice, bob := testHistory(t)
+	snapshot := exportChain(t, chain)
+
+	store := NewMemoryStore()
+	imported, err := ImportChain(store, bytes.NewReader(snapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 3 {
+		t.Fatalf("imported %d blocks, want 3", imported)
+	}
+
+	copied, err := OpenBlockChain(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+
+	if !bytes.Equal(copied.LastHash, chain.LastHash) {
+		t.Fatalf("imported tip %x, want %x", copied.LastHash, chain.LastHash)
+	}
+	for _, address := range []string{alice, bob} {
+		if got, want := balance(copied, address), balance(chain, address); got != want {
+			t.Errorf("balance of %s is %d after the import, want %d", address, got, want)
+		}
+	}
+
+	// Blocks the store already holds are skipped.
+	imported, err = ImportChain(store, bytes.NewReader(snapshot))
+	if err != nil || imported != 0 {
+		t.Fatalf("importing again added %d blocks and returned %v, want 0 and nil", imported, err)
+	}
+}
+
+func TestChainSnapshotCorrupted(t *testing.T) {
+	chain, _, _ := testHistory(t)
+	snapshot := exportChain(t, chain)
+
+	corrupt := func(change func(data []byte) []byte) []byte {
+		return change(bytes.Clone(snapshot))
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"wrong checksum", corrupt(func(data []byte) []byte {
+			data[len(data)-1] ^= 1
+			return data
+		}), "checksum does not match"},
+		{"missing checksum", corrupt(func(data []byte) []byte {
+			return data[:len(data)-4]
+		}), "missing its checksum"},
+		{"truncated block", corrupt(func(data []byte) []byte {
+			return data[:len(snapshotMagic)+1+100]
+		}), "truncated"},
+		{"not a snapshot", corrupt(func(data []byte) []byte {
+			data[0] = 'X'
+			return data
+		}), "not a chain snapshot"},
+		{"unknown version", corrupt(func(data []byte) []byte {
+			data[len(snapshotMagic)]++
+			return data
+		}), "unsupported snapshot version"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ImportChain(NewMemoryStore(), bytes.NewReader(test.data))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("ImportChain = %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+
+	// A changed byte inside the last block.
+	last := len(snapshot) - sha256.Size - 4 - 20
+	damaged := corrupt(func(data []byte) []byte {
+		data[last] ^= 0xff
+		return data
+	})
+	if _, err := ImportChain(NewMemoryStore(), bytes.NewReader(damaged)); err == nil {
+		t.Fatal("imported a snapshot with a damaged block")
+	}
+}
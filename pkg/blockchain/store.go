@@ -0,0 +1,34 @@
+package blockchain
+
+import "errors"
+
+// ErrNotFound is returned by a ChainStore for a block or index key it does
+// not hold, and by Tip before a genesis block is stored.
+var ErrNotFound = errors.New("not found")
+
+// ChainStore holds the blocks of a chain, its tip and the indexes kept next
+// to them, such as the mempool and notarizations. Index keys carry their own
+// prefix, like "mempool-", and must not collide with block hashes.
+type ChainStore interface {
+	GetBlock(hash []byte) (*Block, error)
+	Tip() ([]byte, error)
+	GetIndex(key []byte) ([]byte, error)
+	// ScanIndex calls fn for every index key starting with prefix, in key
+	// order, and stops at the first error fn returns.
+	ScanIndex(prefix []byte, fn func(key, value []byte) error) error
+	// Update runs fn and applies all of its writes atomically, or none of
+	// them when fn returns an error.
+	Update(fn func(batch StoreBatch) error) error
+	Close() error
+}
+
+// StoreBatch collects the writes of one ChainStore.Update. Reads through it
+// see the writes made earlier in the same batch.
+type StoreBatch interface {
+	PutBlock(block *Block) error
+	DeleteBlock(hash []byte) error
+	SetTip(hash []byte) error
+	GetIndex(key []byte) ([]byte, error)
+	PutIndex(key, value []byte) error
+	DeleteIndex(key []byte) error
+}
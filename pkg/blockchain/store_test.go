@@ -0,0 +1,241 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// testStores returns a constructor for every ChainStore implementation.
+func testStores() map[string]func(t *testing.T) ChainStore {
+	return map[string]func(t *testing.T) ChainStore{
+		"memory": func(t *testing.T) ChainStore {
+			return NewMemoryStore()
+		},
+		"badger": func(t *testing.T) ChainStore {
+			store, err := NewBadgerStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+
+			return store
+		},
+	}
+}
+
+func testBlock() *Block {
+	address := string(wallet.NewWallet(wallet.KeyP256).Address())
+	return Genesis(CoinbaseTx(address, ""))
+}
+
+func TestStoreContract(t *testing.T) {
+	errStop := errors.New("stop")
+
+	for name, newStore := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("empty", func(t *testing.T) {
+				store := newStore(t)
+
+				if _, err := store.Tip(); err != ErrNotFound {
+					t.Errorf("Tip = %v, want ErrNotFound", err)
+				}
+				if _, err := store.GetBlock([]byte("missing")); err != ErrNotFound {
+					t.Errorf("GetBlock = %v, want ErrNotFound", err)
+				}
+				if _, err := store.GetIndex([]byte("missing")); err != ErrNotFound {
+					t.Errorf("GetIndex = %v, want ErrNotFound", err)
+				}
+			})
+
+			t.Run("blocks and tip", func(t *testing.T) {
+				store := newStore(t)
+				block := testBlock()
+
+				err := store.Update(func(batch StoreBatch) error {
+					if err := batch.PutBlock(block); err != nil {
+						return err
+					}
+					return batch.SetTip(block.Hash)
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				tip, err := store.Tip()
+				if err != nil || !bytes.Equal(tip, block.Hash) {
+					t.Fatalf("Tip = %x, %v, want %x", tip, err, block.Hash)
+				}
+
+				stored, err := store.GetBlock(block.Hash)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(stored.Serialize(), block.Serialize()) {
+					t.Fatal("stored block differs")
+				}
+
+				// The store must not share the block with its caller.
+				stored.Height = 7
+				if again, _ := store.GetBlock(block.Hash); again.Height != block.Height {
+					t.Fatal("changing a read block changed the store")
+				}
+
+				err = store.Update(func(batch StoreBatch) error {
+					return batch.DeleteBlock(block.Hash)
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := store.GetBlock(block.Hash); err != ErrNotFound {
+					t.Fatalf("GetBlock after DeleteBlock = %v, want ErrNotFound", err)
+				}
+			})
+
+			t.Run("index", func(t *testing.T) {
+				store := newStore(t)
+
+				err := store.Update(func(batch StoreBatch) error {
+					for _, key := range []string{"b-2", "a-1", "b-1", "c-1"} {
+						if err := batch.PutIndex([]byte(key), []byte("v"+key)); err != nil {
+							return err
+						}
+					}
+
+					value, err := batch.GetIndex([]byte("b-2"))
+					if err != nil || string(value) != "vb-2" {
+						t.Errorf("batch GetIndex of its own write = %q, %v", value, err)
+					}
+
+					return batch.DeleteIndex([]byte("c-1"))
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if value, err := store.GetIndex([]byte("a-1")); err != nil || string(value) != "va-1" {
+					t.Errorf("GetIndex = %q, %v, want va-1", value, err)
+				}
+				if _, err := store.GetIndex([]byte("c-1")); err != ErrNotFound {
+					t.Errorf("GetIndex after DeleteIndex = %v, want ErrNotFound", err)
+				}
+
+				keys := []string{}
+				err = store.ScanIndex([]byte("b-"), func(key, value []byte) error {
+					if string(value) != "v"+string(key) {
+						t.Errorf("ScanIndex value of %s = %q", key, value)
+					}
+					keys = append(keys, string(key))
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(keys) != 2 || keys[0] != "b-1" || keys[1] != "b-2" {
+					t.Errorf("ScanIndex keys = %v, want [b-1 b-2]", keys)
+				}
+
+				calls := 0
+				err = store.ScanIndex([]byte(""), func(key, value []byte) error {
+					calls++
+					return errStop
+				})
+				if err != errStop || calls != 1 {
+					t.Errorf("ScanIndex returned %v after %d calls, want the callback error after 1", err, calls)
+				}
+			})
+
+			t.Run("failed update", func(t *testing.T) {
+				store := newStore(t)
+				block := testBlock()
+
+				err := store.Update(func(batch StoreBatch) error {
+					if err := batch.PutBlock(block); err != nil {
+						return err
+					}
+					if err := batch.SetTip(block.Hash); err != nil {
+						return err
+					}
+					if err := batch.PutIndex([]byte("key"), []byte("value")); err != nil {
+						return err
+					}
+					return errStop
+				})
+				if err != errStop {
+					t.Fatalf("Update = %v, want the callback error", err)
+				}
+
+				if _, err := store.Tip(); err != ErrNotFound {
+					t.Errorf("Tip after a failed update = %v, want ErrNotFound", err)
+				}
+				if _, err := store.GetBlock(block.Hash); err != ErrNotFound {
+					t.Errorf("GetBlock after a failed update = %v, want ErrNotFound", err)
+				}
+				if _, err := store.GetIndex([]byte("key")); err != ErrNotFound {
+					t.Errorf("GetIndex after a failed update = %v, want ErrNotFound", err)
+				}
+			})
+
+			t.Run("chain", func(t *testing.T) {
+				store := newStore(t)
+				w := wallet.NewWallet(wallet.KeyP256)
+
+				chain, err := NewBlockChain(store, string(w.Address()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				block := chain.MineBlock(string(w.Address()))
+
+				reopened, err := OpenBlockChain(store)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(reopened.LastHash, block.Hash) || reopened.GetBestHeight() != 1 {
+					t.Fatalf("reopened chain is at %x, want %x", reopened.LastHash, block.Hash)
+				}
+				if balance(reopened, string(w.Address())) != 2*BlockSubsidy {
+					t.Fatalf("reopened chain balance = %d, want %d", balance(reopened, string(w.Address())), 2*BlockSubsidy)
+				}
+			})
+		})
+	}
+}
+
+func TestBadgerStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain")
+
+	store, err := NewBadgerStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := wallet.NewWallet(wallet.KeyP256)
+	chain, err := NewBlockChain(store, string(w.Address()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := chain.MineBlock(string(w.Address()))
+	if err := chain.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = NewBadgerStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	chain, err = OpenBlockChain(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(chain.LastHash, block.Hash) {
+		t.Fatalf("reopened chain is at %x, want %x", chain.LastHash, block.Hash)
+	}
+	if got := balance(chain, string(w.Address())); got != 2*BlockSubsidy {
+		t.Fatalf("reopened chain balance = %d, want %d", got, 2*BlockSubsidy)
+	}
+}
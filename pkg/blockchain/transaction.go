@@ -2,70 +2,110 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
 	"fmt"
-	"log"
-	"math/big"
+	"io"
 	"strings"
 
 	"github.com/zivlakmilos/go-blockchain/pkg/utils"
 	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
 )
 
+// LockTimeThreshold separates block heights from Unix timestamps in
+// Transaction.LockTime, as in Bitcoin.
+const LockTimeThreshold = 500000000
+
+// BlockSubsidy is the number of new coins a block's coinbase may create on
+// top of the fees of its transactions.
+const BlockSubsidy = 100
+
+var ErrNotFinal = fmt.Errorf("transaction is not final")
+
 type Transaction struct {
-	ID      []byte
-	Inputs  []TxInput
-	Outputs []TxOutput
+	ID       []byte
+	Inputs   []TxInput
+	Outputs  []TxOutput
+	LockTime int64
 }
 
-func NewTransaction(from, to string, amount int, chain *BlockChain) *Transaction {
-	inputs := []TxInput{}
-	outputs := []TxOutput{}
+// Transaction IDs hash a gob encoding, and gob numbers types in the order a
+// process first encodes them. Encode a Transaction before anything else, so
+// that IDs come out the same after a block was encoded first, as when a
+// snapshot is imported.
+func init() {
+	err := gob.NewEncoder(io.Discard).Encode(Transaction{})
+	utils.HandleError(err)
+}
 
-	wallets, err := wallet.NewWallets()
+// TxOptions holds optional time locks for a new transaction.
+type TxOptions struct {
+	LockTime     int64
+	RelativeLock int
+}
+
+func NewTransaction(from, to string, amount int, chain *BlockChain, wallets *wallet.Wallets, selector CoinSelector, opts TxOptions) *Transaction {
+	w, err := wallets.GetWallet(from)
 	utils.HandleError(err)
-	w := wallets.GetWallet(from)
 	pubKeyHash := wallet.PublicKeyHash(w.PublicKey)
 
-	acc, validOutputs := chain.FindSpendableOutputs(pubKeyHash, amount)
-
-	if acc < amount {
-		log.Panic("error: not enough fund")
+	payment := NewTXOutput(amount, to)
+	if opts.RelativeLock > 0 {
+		payment.Script = RelativeTimeLockScript(opts.RelativeLock, payment.Script)
 	}
 
-	for key, value := range validOutputs {
-		txID, err := hex.DecodeString(key)
-		utils.HandleError(err)
+	tx, err := newUnsignedTransaction(PayToPubKeyHashScript(pubKeyHash), from, *payment, chain, selector, opts.LockTime)
+	utils.HandleError(err)
+	err = chain.SignTransaction(tx, w.PrivateKey)
+	utils.HandleError(err)
+
+	return tx
+}
+
+// newUnsignedTransaction spends outputs locked with lock to fund payment and
+// returns any change to the from address. Change below DustThreshold is left
+// as fee, which is burned if the transaction is mined by SubmitTransaction.
+// Time locks on the spent outputs are carried over to the inputs and
+// LockTime.
+func newUnsignedTransaction(lock Script, from string, payment TxOutput, chain *BlockChain, selector CoinSelector, lockTime int64) (*Transaction, error) {
+	inputs := []TxInput{}
+	outputs := []TxOutput{}
+	amount := payment.Value
+
+	acc, validOutputs, err := chain.FindSpendableOutputs(lock, amount, selector)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, out := range value {
-			inputs = append(inputs, TxInput{
-				ID:        txID,
-				Out:       out,
-				Signature: nil,
-				PubKey:    pubKeyHash,
-			})
+	for _, utxo := range validOutputs {
+		_, outLockTime, sequence := utxo.Output.Script.SplitTimeLock()
+		if outLockTime > lockTime {
+			lockTime = outLockTime
 		}
+
+		inputs = append(inputs, TxInput{
+			ID:       utxo.TxID,
+			Out:      utxo.Index,
+			Script:   nil,
+			Sequence: sequence,
+		})
 	}
 
-	outputs = append(outputs, *NewTXOutput(amount, to))
+	outputs = append(outputs, payment)
 
-	if acc > amount {
+	if acc-amount >= DustThreshold {
 		outputs = append(outputs, *NewTXOutput(acc-amount, from))
 	}
 
 	tx := &Transaction{
-		Inputs:  inputs,
-		Outputs: outputs,
+		Inputs:   inputs,
+		Outputs:  outputs,
+		LockTime: lockTime,
 	}
 	tx.ID = tx.Hash()
-	chain.SignTransaction(tx, w.PrivateKey)
 
-	return tx
+	return tx, nil
 }
 
 func CoinbaseTx(to, data string) *Transaction {
@@ -74,13 +114,12 @@ func CoinbaseTx(to, data string) *Transaction {
 	}
 
 	txin := TxInput{
-		ID:        []byte{},
-		Out:       -1,
-		Signature: nil,
-		PubKey:    []byte(data),
+		ID:     []byte{},
+		Out:    -1,
+		Script: NewScript().AddData([]byte(data)),
 	}
 
-	txout := NewTXOutput(100, to)
+	txout := NewTXOutput(BlockSubsidy, to)
 
 	tx := &Transaction{
 		ID:      []byte{},
@@ -118,6 +157,16 @@ func (t *Transaction) Serialize() []byte {
 	return encoded.Bytes()
 }
 
+func DeserializeTransaction(data []byte) *Transaction {
+	var tx Transaction
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&tx)
+	utils.HandleError(err)
+
+	return &tx
+}
+
 func (t *Transaction) Hash() []byte {
 	txCopy := *t
 	txCopy.ID = []byte{}
@@ -127,32 +176,48 @@ func (t *Transaction) Hash() []byte {
 	return hash[:]
 }
 
-func (t *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
+// Sign signs every input of t with privKey. prevTXs holds the transactions
+// whose outputs t spends, and each of those outputs must be locked with
+// privKey.
+func (t *Transaction) Sign(privKey wallet.Signer, prevTXs map[string]Transaction) error {
 	if t.IsCoinbase() {
-		return
+		return nil
 	}
 
-	for _, txIn := range t.Inputs {
-		if prevTXs[hex.EncodeToString(txIn.ID)].ID == nil {
-			log.Panic("error: previous transaction does not exists")
+	pubKey := privKey.PublicKey()
+	pubKeyHash := wallet.PublicKeyHash(pubKey)
+
+	for idx, txIn := range t.Inputs {
+		prevOut, err := spentOutput(txIn, prevTXs)
+		if err != nil {
+			return err
 		}
+		if !prevOut.IsLockedWithKey(pubKeyHash) {
+			return fmt.Errorf("input %d is not locked with the signing key", idx)
+		}
+
+		signature, err := privKey.Sign(t.SigHash(idx, prevOut))
+		if err != nil {
+			return err
+		}
+
+		t.Inputs[idx].Script = PayToPubKeyHashUnlockScript(signature, pubKey)
 	}
 
-	txCopy := t.TrimmedCopy()
+	return nil
+}
 
-	for idx, txIn := range txCopy.Inputs {
-		prevTX := prevTXs[hex.EncodeToString(txIn.ID)]
-		txCopy.Inputs[idx].Signature = nil
-		txCopy.Inputs[idx].PubKey = prevTX.Outputs[txIn.Out].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Inputs[idx].PubKey = nil
+// SigHash returns the digest signed for input idx: the transaction with all
+// unlocking scripts removed and the spent output's locking script in place of
+// the input's own, followed by the spent value so that offline signers cannot
+// be misled about the amounts.
+func (t *Transaction) SigHash(idx int, prevOut TxOutput) []byte {
+	txCopy := t.TrimmedCopy()
+	txCopy.Inputs[idx].Script = prevOut.Script
 
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.ID)
-		utils.HandleError(err)
-		signature := append(r.Bytes(), s.Bytes()...)
+	hash := sha256.Sum256(append(txCopy.Hash(), utils.ToHex(int64(prevOut.Value))...))
 
-		t.Inputs[idx].Signature = signature
-	}
+	return hash[:]
 }
 
 func (t *Transaction) TrimmedCopy() Transaction {
@@ -161,92 +226,135 @@ func (t *Transaction) TrimmedCopy() Transaction {
 
 	for _, txIn := range t.Inputs {
 		inputs = append(inputs, TxInput{
-			ID:        txIn.ID,
-			Out:       txIn.Out,
-			Signature: nil,
-			PubKey:    nil,
+			ID:       txIn.ID,
+			Out:      txIn.Out,
+			Script:   nil,
+			Sequence: txIn.Sequence,
 		})
 	}
 
 	for _, txOut := range t.Outputs {
 		outputs = append(outputs, TxOutput{
-			Value:      txOut.Value,
-			PubKeyHash: txOut.PubKeyHash,
+			Value:  txOut.Value,
+			Script: txOut.Script,
 		})
 	}
 
 	txCopy := Transaction{
-		ID:      t.ID,
-		Inputs:  inputs,
-		Outputs: outputs,
+		ID:       t.ID,
+		Inputs:   inputs,
+		Outputs:  outputs,
+		LockTime: t.LockTime,
 	}
 
 	return txCopy
 }
 
-func (t *Transaction) Verify(prevTXs map[string]Transaction) bool {
+// Verify runs the scripts of every input of t against the outputs they
+// spend, which prevTXs holds.
+func (t *Transaction) Verify(prevTXs map[string]Transaction) error {
 	if t.IsCoinbase() {
-		return true
+		return nil
 	}
 
-	for _, txIn := range t.Inputs {
-		if prevTXs[hex.EncodeToString(txIn.ID)].ID == nil {
-			log.Panic("error: previous transaction does not exists")
+	for idx, txIn := range t.Inputs {
+		prevOut, err := spentOutput(txIn, prevTXs)
+		if err != nil {
+			return err
 		}
-	}
 
-	txCopy := t.TrimmedCopy()
-	curve := elliptic.P256()
-
-	for idx, txIn := range t.Inputs {
-		prevTX := prevTXs[hex.EncodeToString(txIn.ID)]
-		txCopy.Inputs[idx].Signature = nil
-		txCopy.Inputs[idx].PubKey = prevTX.Outputs[txIn.Out].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Inputs[idx].PubKey = nil
-
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(txIn.Signature)
-		r.SetBytes(txIn.Signature[:(sigLen / 2)])
-		s.SetBytes(txIn.Signature[(sigLen / 2):])
-
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(txIn.PubKey)
-		x.SetBytes(txIn.Signature[:(keyLen / 2)])
-		y.SetBytes(txIn.Signature[(keyLen / 2):])
-
-		rawPubKey := ecdsa.PublicKey{
-			Curve: curve,
-			X:     &x,
-			Y:     &y,
+		checker := &txSigChecker{
+			tx:      t,
+			input:   idx,
+			sigHash: t.SigHash(idx, prevOut),
 		}
-		if !ecdsa.Verify(&rawPubKey, txCopy.ID, &r, &s) {
-			return false
+		if err := VerifyScript(txIn.Script, prevOut.Script, checker); err != nil {
+			return fmt.Errorf("input %d: %w", idx, err)
 		}
 	}
 
-	return true
+	return nil
+}
+
+// spentOutput returns the output txIn spends from prevTXs.
+func spentOutput(txIn TxInput, prevTXs map[string]Transaction) (TxOutput, error) {
+	prevTX, ok := prevTXs[hex.EncodeToString(txIn.ID)]
+	if !ok || prevTX.ID == nil {
+		return TxOutput{}, fmt.Errorf("previous transaction %x does not exist", txIn.ID)
+	}
+	if txIn.Out < 0 || txIn.Out >= len(prevTX.Outputs) {
+		return TxOutput{}, fmt.Errorf("previous transaction %x has no output %d", txIn.ID, txIn.Out)
+	}
+
+	return prevTX.Outputs[txIn.Out], nil
+}
+
+func signHash(privKey wallet.Signer, hash []byte) []byte {
+	signature, err := privKey.Sign(hash)
+	utils.HandleError(err)
+
+	return signature
+}
+
+// IsFinal reports whether the transaction may be included in a block at the
+// given height and time.
+func (t *Transaction) IsFinal(height int, blockTime int64) bool {
+	if t.LockTime == 0 {
+		return true
+	}
+
+	if t.LockTime < LockTimeThreshold {
+		return t.LockTime <= int64(height)
+	}
+
+	return t.LockTime <= blockTime
+}
+
+type txSigChecker struct {
+	tx      *Transaction
+	input   int
+	sigHash []byte
+}
+
+func (c *txSigChecker) CheckLockTime(lockTime int64) bool {
+	// Height and time locks are not comparable with each other.
+	if (lockTime < LockTimeThreshold) != (c.tx.LockTime < LockTimeThreshold) {
+		return false
+	}
+
+	return lockTime <= c.tx.LockTime
+}
+
+func (c *txSigChecker) CheckSequence(sequence int64) bool {
+	return sequence <= int64(c.tx.Inputs[c.input].Sequence)
+}
+
+func (c *txSigChecker) CheckSig(sig, pubKey []byte) bool {
+	return wallet.VerifySignature(pubKey, c.sigHash, sig)
 }
 
 func (t *Transaction) String() string {
 	var builder strings.Builder
 
 	builder.WriteString(fmt.Sprintf("-- Transaction %x:\n", t.ID))
+	if t.LockTime != 0 {
+		builder.WriteString(fmt.Sprintf("  LockTime: %d\n", t.LockTime))
+	}
 
 	for idx, txIn := range t.Inputs {
 		builder.WriteString(fmt.Sprintf("  Input %d:\n", idx))
 		builder.WriteString(fmt.Sprintf("    TXID:      %x\n", txIn.ID))
 		builder.WriteString(fmt.Sprintf("    Out:       %d\n", txIn.Out))
-		builder.WriteString(fmt.Sprintf("    Signature: %x\n", txIn.Signature))
-		builder.WriteString(fmt.Sprintf("    PubKey:    %x\n", txIn.PubKey))
+		builder.WriteString(fmt.Sprintf("    Script:    %s\n", txIn.Script))
+		if txIn.Sequence != 0 {
+			builder.WriteString(fmt.Sprintf("    Sequence:  %d\n", txIn.Sequence))
+		}
 	}
 
 	for idx, txOut := range t.Outputs {
 		builder.WriteString(fmt.Sprintf("  Output %d:\n", idx))
-		builder.WriteString(fmt.Sprintf("    Value:      %d\n", txOut.Value))
-		builder.WriteString(fmt.Sprintf("    PubKeyHash: %x\n", txOut.PubKeyHash))
+		builder.WriteString(fmt.Sprintf("    Value:  %d\n", txOut.Value))
+		builder.WriteString(fmt.Sprintf("    Script: %s\n", txOut.Script))
 	}
 
 	return builder.String()
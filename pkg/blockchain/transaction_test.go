@@ -0,0 +1,325 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+func newTestKey(t *testing.T, keyType wallet.KeyType) wallet.Signer {
+	t.Helper()
+
+	key, err := wallet.GenerateKey(keyType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func keyLock(key wallet.Signer) Script {
+	return PayToPubKeyHashScript(wallet.PublicKeyHash(key.PublicKey()))
+}
+
+// signedSpend returns a transaction spending both outputs of a previous
+// transaction locked with key, signed with it, and the previous transactions.
+func signedSpend(t *testing.T, key wallet.Signer) (*Transaction, map[string]Transaction) {
+	t.Helper()
+
+	prev := Transaction{
+		Outputs: []TxOutput{
+			{Value: 30, Script: keyLock(key)},
+			{Value: 20, Script: keyLock(key)},
+		},
+	}
+	prev.ID = prev.Hash()
+
+	tx := &Transaction{
+		Inputs: []TxInput{
+			{ID: prev.ID, Out: 0},
+			{ID: prev.ID, Out: 1},
+		},
+		Outputs: []TxOutput{
+			{Value: 40, Script: keyLock(newTestKey(t, wallet.KeyP256))},
+			{Value: 10, Script: keyLock(key)},
+		},
+	}
+	tx.ID = tx.Hash()
+
+	prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): prev}
+	if err := tx.Sign(key, prevTXs); err != nil {
+		t.Fatal(err)
+	}
+
+	return tx, prevTXs
+}
+
+// copyTransaction returns a deep copy of tx that can be altered freely.
+func copyTransaction(tx *Transaction) *Transaction {
+	return DeserializeTransaction(tx.Serialize())
+}
+
+func copyPrevTXs(prevTXs map[string]Transaction) map[string]Transaction {
+	copied := map[string]Transaction{}
+	for id, tx := range prevTXs {
+		copied[id] = *copyTransaction(&tx)
+	}
+
+	return copied
+}
+
+// unlockData returns the signature and public key of a P2PKH unlock script.
+func unlockData(t *testing.T, script Script) ([]byte, []byte) {
+	t.Helper()
+
+	data, ok := script.PushedData()
+	if !ok || len(data) != 2 {
+		t.Fatalf("not a P2PKH unlock script: %s", script)
+	}
+
+	return data[0], data[1]
+}
+
+// highS returns the other valid encoding of an ECDSA signature, with s
+// replaced by n - s.
+func highS(t *testing.T, curve elliptic.Curve, sig []byte) []byte {
+	t.Helper()
+
+	r, s, err := wallet.ParseDER(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = new(big.Int).Sub(curve.Params().N, s)
+
+	derInt := func(num *big.Int) []byte {
+		data := num.Bytes()
+		if data[0]&0x80 != 0 {
+			data = append([]byte{0}, data...)
+		}
+		return append([]byte{0x02, byte(len(data))}, data...)
+	}
+
+	body := append(derInt(r), derInt(s)...)
+
+	return append([]byte{0x30, byte(len(body))}, body...)
+}
+
+func TestTransactionVerify(t *testing.T) {
+	for _, keyType := range []wallet.KeyType{wallet.KeyP256, wallet.KeySecp256k1, wallet.KeyEd25519, wallet.KeySchnorr} {
+		t.Run(keyType.String(), func(t *testing.T) {
+			key := newTestKey(t, keyType)
+			tx, prevTXs := signedSpend(t, key)
+
+			if err := tx.Verify(prevTXs); err != nil {
+				t.Fatalf("signed transaction does not verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestTransactionVerifyRejects(t *testing.T) {
+	key := newTestKey(t, wallet.KeyP256)
+	other := newTestKey(t, wallet.KeyP256)
+
+	tests := []struct {
+		name  string
+		alter func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction)
+	}{
+		{
+			name: "flipped signature bit",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				sig, pubKey := unlockData(t, tx.Inputs[0].Script)
+				sig = bytes.Clone(sig)
+				sig[len(sig)-1] ^= 0x01
+				tx.Inputs[0].Script = PayToPubKeyHashUnlockScript(sig, pubKey)
+			},
+		},
+		{
+			name: "signature of another input",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				sig, _ := unlockData(t, tx.Inputs[1].Script)
+				_, pubKey := unlockData(t, tx.Inputs[0].Script)
+				tx.Inputs[0].Script = PayToPubKeyHashUnlockScript(sig, pubKey)
+			},
+		},
+		{
+			name: "flipped public key bit",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				sig, pubKey := unlockData(t, tx.Inputs[0].Script)
+				pubKey = bytes.Clone(pubKey)
+				pubKey[len(pubKey)-1] ^= 0x01
+				tx.Inputs[0].Script = PayToPubKeyHashUnlockScript(sig, pubKey)
+			},
+		},
+		{
+			name: "other public key",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				sig, _ := unlockData(t, tx.Inputs[0].Script)
+				tx.Inputs[0].Script = PayToPubKeyHashUnlockScript(sig, other.PublicKey())
+			},
+		},
+		{
+			name: "signed with the wrong key",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				prevOut := prevTXs[hex.EncodeToString(tx.Inputs[0].ID)].Outputs[0]
+				sig, err := other.Sign(tx.SigHash(0, prevOut))
+				if err != nil {
+					t.Fatal(err)
+				}
+				tx.Inputs[0].Script = PayToPubKeyHashUnlockScript(sig, other.PublicKey())
+			},
+		},
+		{
+			name: "wrong key with the right public key",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				prevOut := prevTXs[hex.EncodeToString(tx.Inputs[0].ID)].Outputs[0]
+				sig, err := other.Sign(tx.SigHash(0, prevOut))
+				if err != nil {
+					t.Fatal(err)
+				}
+				tx.Inputs[0].Script = PayToPubKeyHashUnlockScript(sig, key.PublicKey())
+			},
+		},
+		{
+			name: "high-S signature",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				sig, pubKey := unlockData(t, tx.Inputs[0].Script)
+				tx.Inputs[0].Script = PayToPubKeyHashUnlockScript(highS(t, elliptic.P256(), sig), pubKey)
+			},
+		},
+		{
+			name: "prevout value",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				id := hex.EncodeToString(tx.Inputs[0].ID)
+				prev := prevTXs[id]
+				prev.Outputs[0].Value++
+				prevTXs[id] = prev
+			},
+		},
+		{
+			name: "prevout script",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				id := hex.EncodeToString(tx.Inputs[0].ID)
+				prev := prevTXs[id]
+				prev.Outputs[0].Script = keyLock(other)
+				prevTXs[id] = prev
+			},
+		},
+		{
+			name: "prevout time lock",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				id := hex.EncodeToString(tx.Inputs[0].ID)
+				prev := prevTXs[id]
+				prev.Outputs[0].Script = RelativeTimeLockScript(1, prev.Outputs[0].Script)
+				prevTXs[id] = prev
+			},
+		},
+		{
+			name: "input outpoint",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				tx.Inputs[0].Out, tx.Inputs[1].Out = tx.Inputs[1].Out, tx.Inputs[0].Out
+			},
+		},
+		{
+			name: "input sequence",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				tx.Inputs[1].Sequence++
+			},
+		},
+		{
+			name: "lock time",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				tx.LockTime = 1
+			},
+		},
+		{
+			name: "output 0 value",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				tx.Outputs[0].Value--
+			},
+		},
+		{
+			name: "output 0 script",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				tx.Outputs[0].Script = keyLock(other)
+			},
+		},
+		{
+			name: "output 1 value",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				tx.Outputs[1].Value++
+			},
+		},
+		{
+			name: "output 1 script",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				tx.Outputs[1].Script = keyLock(other)
+			},
+		},
+		{
+			name: "added output",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				tx.Outputs = append(tx.Outputs, TxOutput{Value: 0, Script: keyLock(other)})
+			},
+		},
+		{
+			name: "removed output",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				tx.Outputs = tx.Outputs[:1]
+			},
+		},
+		{
+			name: "missing previous transaction",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				delete(prevTXs, hex.EncodeToString(tx.Inputs[0].ID))
+			},
+		},
+		{
+			name: "missing previous output",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				tx.Inputs[1].Out = 2
+			},
+		},
+		{
+			name: "empty unlock script",
+			alter: func(t *testing.T, tx *Transaction, prevTXs map[string]Transaction) {
+				tx.Inputs[0].Script = nil
+			},
+		},
+	}
+
+	signed, signedPrevTXs := signedSpend(t, key)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tx := copyTransaction(signed)
+			prevTXs := copyPrevTXs(signedPrevTXs)
+			if err := tx.Verify(prevTXs); err != nil {
+				t.Fatalf("unaltered transaction does not verify: %v", err)
+			}
+
+			test.alter(t, tx, prevTXs)
+
+			if err := tx.Verify(prevTXs); err == nil {
+				t.Fatal("altered transaction verifies")
+			}
+		})
+	}
+}
+
+func TestTransactionSignRejects(t *testing.T) {
+	key := newTestKey(t, wallet.KeyP256)
+	tx, prevTXs := signedSpend(t, key)
+
+	if err := tx.Sign(newTestKey(t, wallet.KeyP256), prevTXs); err == nil {
+		t.Error("signing inputs locked with another key succeeded")
+	}
+
+	if err := tx.Sign(key, map[string]Transaction{}); err == nil {
+		t.Error("signing without the previous transactions succeeded")
+	}
+}
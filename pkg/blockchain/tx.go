@@ -8,38 +8,76 @@ import (
 )
 
 type TxOutput struct {
-	Value      int
-	PubKeyHash []byte
+	Value  int
+	Script Script
 }
 
 type TxInput struct {
-	ID        []byte
-	Out       int
-	Signature []byte
-	PubKey    []byte
+	ID       []byte
+	Out      int
+	Script   Script
+	Sequence int
 }
 
 func NewTXOutput(value int, address string) *TxOutput {
 	o := &TxOutput{
-		Value:      value,
-		PubKeyHash: nil,
+		Value:  value,
+		Script: nil,
 	}
-	o.Lock([]byte(address))
+	parsed, err := wallet.ParseAddress(address)
+	utils.HandleError(err)
+	o.Lock(parsed)
 
 	return o
 }
 
 func (i *TxInput) UsesKey(pubKeyHash []byte) bool {
-	lockingHash := wallet.PublicKeyHash(i.PubKey)
+	data, ok := i.Script.PushedData()
+	if !ok || len(data) != 2 {
+		return false
+	}
+
+	lockingHash := wallet.PublicKeyHash(data[1])
 	return bytes.Equal(pubKeyHash, lockingHash)
 }
 
-func (o *TxOutput) Lock(address []byte) {
-	pubKeyHash := utils.Base58Decode(address)
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	o.PubKeyHash = pubKeyHash
+func (o *TxOutput) Lock(address wallet.Address) {
+	o.Script = LockingScript(address)
+}
+
+// LockingScript returns the script paying to address.
+func LockingScript(address wallet.Address) Script {
+	if address.IsScriptHash() {
+		return PayToScriptHashScript(address.Hash)
+	}
+
+	return PayToPubKeyHashScript(address.Hash)
+}
+
+// ScriptAddress returns the address a pay-to-pubkey-hash or
+// pay-to-script-hash script pays to, ignoring any time lock wrapping it.
+func ScriptAddress(script Script) (wallet.Address, bool) {
+	inner, _, _ := script.SplitTimeLock()
+
+	if hash := inner.PubKeyHash(); hash != nil {
+		return wallet.Address{Version: wallet.PubKeyHashVersion, Hash: hash}, true
+	}
+	if hash := inner.ScriptHash(); hash != nil {
+		return wallet.Address{Version: wallet.ScriptHashVersion, Hash: hash}, true
+	}
+
+	return wallet.Address{}, false
+}
+
+// IsLockedWith reports whether the output is locked with script, ignoring
+// any time lock wrapping it.
+func (o *TxOutput) IsLockedWith(script Script) bool {
+	inner, _, _ := o.Script.SplitTimeLock()
+	return bytes.Equal(inner, script)
 }
 
 func (o *TxOutput) IsLockedWithKey(pubKeyHash []byte) bool {
-	return bytes.Equal(o.PubKeyHash, pubKeyHash)
+	inner, _, _ := o.Script.SplitTimeLock()
+	lockingHash := inner.PubKeyHash()
+	return lockingHash != nil && bytes.Equal(lockingHash, pubKeyHash)
 }
@@ -0,0 +1,268 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/utils"
+)
+
+// The UTXO set keeps every unspent output under "utxo-" followed by the
+// transaction ID and the big-endian output index, so that spending and
+// balances do not need old block bodies. "utxo" holds the hash of the block
+// the set is up to date with. "spent-" followed by a block hash holds the
+// outputs the block spent, so they can be described after they left the set
+// and put back when the block is disconnected.
+const (
+	utxoPrefix  = "utxo-"
+	spentPrefix = "spent-"
+)
+
+var utxoTipKey = []byte("utxo")
+
+// FindUnspentOutput returns output out of transaction txID if it has not
+// been spent.
+func (c *BlockChain) FindUnspentOutput(txID []byte, out int) (UnspentOutput, error) {
+	data, err := c.Store.GetIndex(utxoKey(txID, out))
+	if err == ErrNotFound {
+		return UnspentOutput{}, fmt.Errorf("output %x:%d does not exist or is spent", txID, out)
+	}
+	if err != nil {
+		return UnspentOutput{}, err
+	}
+
+	return decodeUnspentOutput(data)
+}
+
+// SpentOutputs returns the outputs spent by the transactions of a block, one
+// list per transaction in block order, each in input order. The list of a
+// coinbase transaction is empty.
+func (c *BlockChain) SpentOutputs(hash []byte) ([][]TxOutput, error) {
+	spent, err := spentUTXOs(c.Store, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([][]TxOutput, len(spent))
+	for txIdx, utxos := range spent {
+		outputs[txIdx] = []TxOutput{}
+		for _, utxo := range utxos {
+			outputs[txIdx] = append(outputs[txIdx], utxo.Output)
+		}
+	}
+
+	return outputs, nil
+}
+
+// spentUTXOs reads the outputs a block spent, as they were in the UTXO set.
+func spentUTXOs(store indexReader, hash []byte) ([][]UnspentOutput, error) {
+	data, err := store.GetIndex(spentKey(hash))
+	if err != nil {
+		return nil, fmt.Errorf("spent outputs of block %x: %w", hash, err)
+	}
+
+	var spent [][]UnspentOutput
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&spent)
+
+	return spent, err
+}
+
+func (c *BlockChain) FindUnspentOutputs(lock Script) []UnspentOutput {
+	unspentOuts := []UnspentOutput{}
+
+	err := c.Store.ScanIndex([]byte(utxoPrefix), func(key, value []byte) error {
+		utxo, err := decodeUnspentOutput(value)
+		if err != nil {
+			return err
+		}
+
+		if utxo.Output.IsLockedWith(lock) {
+			unspentOuts = append(unspentOuts, utxo)
+		}
+
+		return nil
+	})
+	utils.HandleError(err)
+
+	return unspentOuts
+}
+
+// UTXOSetStats returns the number of unspent outputs and their total value.
+func (c *BlockChain) UTXOSetStats() (int, int, error) {
+	count := 0
+	total := 0
+
+	err := c.Store.ScanIndex([]byte(utxoPrefix), func(key, value []byte) error {
+		utxo, err := decodeUnspentOutput(value)
+		if err != nil {
+			return err
+		}
+
+		count++
+		total += utxo.Output.Value
+
+		return nil
+	})
+
+	return count, total, err
+}
+
+// updateUTXOSet removes the outputs spent by block, keeping them as the
+// block's spent outputs, and adds the ones it creates. Data outputs can never
+// be spent and are left out.
+func updateUTXOSet(batch StoreBatch, block *Block) error {
+	spent := make([][]UnspentOutput, len(block.Transactions))
+
+	for txIdx, tx := range block.Transactions {
+		spent[txIdx] = []UnspentOutput{}
+
+		if !tx.IsCoinbase() {
+			for _, txIn := range tx.Inputs {
+				data, err := batch.GetIndex(utxoKey(txIn.ID, txIn.Out))
+				if err != nil {
+					return fmt.Errorf("block %x spends output %x:%d: %w", block.Hash, txIn.ID, txIn.Out, err)
+				}
+				utxo, err := decodeUnspentOutput(data)
+				if err != nil {
+					return err
+				}
+				spent[txIdx] = append(spent[txIdx], utxo)
+
+				err = batch.DeleteIndex(utxoKey(txIn.ID, txIn.Out))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for idx, txOut := range tx.Outputs {
+			if len(txOut.Script) > 0 && txOut.Script[0] == OpReturn {
+				continue
+			}
+
+			encoded, err := encodeUnspentOutput(UnspentOutput{
+				TxID:   tx.ID,
+				Index:  idx,
+				Output: txOut,
+				Height: block.Height,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = batch.PutIndex(utxoKey(tx.ID, idx), encoded)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var encoded bytes.Buffer
+	err := gob.NewEncoder(&encoded).Encode(spent)
+	if err != nil {
+		return err
+	}
+
+	err = batch.PutIndex(spentKey(block.Hash), encoded.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return batch.PutIndex(utxoTipKey, block.Hash)
+}
+
+// reindexUTXO builds the UTXO set again from every block, for chains stored
+// before the set existed. It needs all block bodies.
+func (c *BlockChain) reindexUTXO() error {
+	blocks := [][]byte{}
+
+	err := c.forEachBlock(func(block *Block) bool {
+		blocks = append(blocks, block.Hash)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("cannot rebuild the UTXO set: %w", err)
+	}
+
+	err = clearUTXOSet(c.Store)
+	if err != nil {
+		return err
+	}
+
+	// Apply one block per batch, oldest first, to stay within the
+	// transaction size limits of the store.
+	for idx := len(blocks) - 1; idx >= 0; idx-- {
+		block, err := c.GetBlock(blocks[idx])
+		if err != nil {
+			return err
+		}
+
+		err = c.Store.Update(func(batch StoreBatch) error {
+			err := updateUTXOSet(batch, block)
+			if err != nil {
+				return err
+			}
+
+			return indexHTLCSpends(batch, block)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func clearUTXOSet(store ChainStore) error {
+	stale := [][]byte{}
+
+	err := store.ScanIndex([]byte(utxoPrefix), func(key, value []byte) error {
+		stale = append(stale, key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return store.Update(func(batch StoreBatch) error {
+		for _, key := range stale {
+			err := batch.DeleteIndex(key)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func utxoKey(txID []byte, out int) []byte {
+	key := append([]byte(utxoPrefix), txID...)
+
+	return binary.BigEndian.AppendUint32(key, uint32(out))
+}
+
+func spentKey(hash []byte) []byte {
+	return append([]byte(spentPrefix), hash...)
+}
+
+func encodeUnspentOutput(utxo UnspentOutput) ([]byte, error) {
+	var encoded bytes.Buffer
+
+	err := gob.NewEncoder(&encoded).Encode(utxo)
+	if err != nil {
+		return nil, err
+	}
+
+	return encoded.Bytes(), nil
+}
+
+func decodeUnspentOutput(data []byte) (UnspentOutput, error) {
+	var utxo UnspentOutput
+
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&utxo)
+
+	return utxo, err
+}
@@ -0,0 +1,406 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// A UTXO snapshot is the magic "GBUTXO" and a version byte, the header of
+// the tip it was taken at, then every unspent output in key order. Each
+// output is its transaction ID, index, value, locking script and height;
+// byte strings are prefixed with a big-endian uint32 length and numbers are
+// big-endian int64s. An empty transaction ID ends the outputs and is
+// followed by the commitment: the SHA-256 of everything before it. The same
+// UTXO set at the same tip always gives the same bytes.
+const (
+	utxoSnapshotMagic   = "GBUTXO"
+	utxoSnapshotVersion = byte(1)
+	maxSnapshotField    = 1 << 20
+)
+
+// assumedUTXOKey holds the UTXOSnapshotInfo of a node bootstrapped with
+// LoadUTXOSet.
+var assumedUTXOKey = []byte("assumeutxo")
+
+// UTXOSnapshotInfo describes a UTXO snapshot. Verified is set once the
+// chain up to Height has been validated and gave the same commitment.
+type UTXOSnapshotInfo struct {
+	Height     int
+	TipHash    []byte
+	Commitment []byte
+	Verified   bool
+}
+
+// DumpUTXOSet writes a snapshot of the UTXO set at the current tip and
+// returns its description and the number of outputs written.
+func (c *BlockChain) DumpUTXOSet(w io.Writer) (*UTXOSnapshotInfo, int, error) {
+	header, err := c.GetHeader(c.LastHash)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	writer := bufio.NewWriter(w)
+	commitment, count, err := writeUTXOSnapshot(writer, c.Store, header)
+	if err != nil {
+		return nil, count, err
+	}
+
+	info := &UTXOSnapshotInfo{
+		Height:     header.Height,
+		TipHash:    header.Hash,
+		Commitment: commitment,
+	}
+
+	return info, count, writer.Flush()
+}
+
+// LoadUTXOSet bootstraps an empty store from a UTXO snapshot. The store
+// gets the snapshot tip and its UTXO set, and acts like a node that pruned
+// every block up to it; new blocks can be accepted on top right away. The
+// snapshot is trusted until ImportChain replays the chain up to its height
+// and checks the commitment.
+func LoadUTXOSet(store ChainStore, r io.Reader) (*UTXOSnapshotInfo, int, error) {
+	if _, err := store.Tip(); err == nil {
+		return nil, 0, fmt.Errorf("a UTXO snapshot can only be loaded into an empty chain")
+	}
+
+	err := clearUTXOSet(store)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hasher := sha256.New()
+	reader := bufio.NewReader(r)
+	in := io.TeeReader(reader, hasher)
+
+	header, err := readUTXOSnapshotHeader(in)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count := 0
+	for done := false; !done; {
+		// Write the outputs in batches, to stay within the transaction
+		// size limits of the store.
+		err = store.Update(func(batch StoreBatch) error {
+			for idx := 0; idx < 1000; idx++ {
+				utxo, err := readUTXOSnapshotEntry(in)
+				if err != nil {
+					return err
+				}
+				if utxo == nil {
+					done = true
+					return nil
+				}
+
+				encoded, err := encodeUnspentOutput(*utxo)
+				if err != nil {
+					return err
+				}
+
+				err = batch.PutIndex(utxoKey(utxo.TxID, utxo.Index), encoded)
+				if err != nil {
+					return err
+				}
+				count++
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, count, err
+		}
+	}
+
+	commitment := hasher.Sum(nil)
+	stored := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(reader, stored); err != nil {
+		return nil, count, fmt.Errorf("UTXO snapshot is missing its commitment: %w", err)
+	}
+	if !bytes.Equal(stored, commitment) {
+		return nil, count, fmt.Errorf("UTXO snapshot does not match its commitment")
+	}
+
+	info := &UTXOSnapshotInfo{
+		Height:     header.Height,
+		TipHash:    header.Hash,
+		Commitment: commitment,
+	}
+
+	err = store.Update(func(batch StoreBatch) error {
+		err := putHeader(batch, header)
+		if err != nil {
+			return err
+		}
+
+		err = batch.PutIndex(heightKey(header.Height), header.Hash)
+		if err != nil {
+			return err
+		}
+
+		err = batch.PutIndex(indexTipKey, header.Hash)
+		if err != nil {
+			return err
+		}
+
+		err = batch.PutIndex(prunedHeightKey, binary.BigEndian.AppendUint64(nil, uint64(header.Height)))
+		if err != nil {
+			return err
+		}
+
+		err = putUTXOSnapshotInfo(batch, info)
+		if err != nil {
+			return err
+		}
+
+		err = batch.PutIndex(utxoTipKey, header.Hash)
+		if err != nil {
+			return err
+		}
+
+		return batch.SetTip(header.Hash)
+	})
+	if err != nil {
+		return nil, count, err
+	}
+
+	return info, count, nil
+}
+
+// AssumedUTXOSet returns the snapshot the chain was bootstrapped from, if
+// any.
+func (c *BlockChain) AssumedUTXOSet() (*UTXOSnapshotInfo, bool) {
+	info, err := getUTXOSnapshotInfo(c.Store)
+	if err != nil {
+		return nil, false
+	}
+
+	return info, true
+}
+
+// snapshotVerifier validates the history below an assumed UTXO set in an
+// in-memory chain, and compares its UTXO set with the snapshot at the
+// snapshot height.
+type snapshotVerifier struct {
+	info  *UTXOSnapshotInfo
+	chain *BlockChain
+}
+
+// add validates block as the next block of the history. It reports whether
+// the snapshot height has been reached and the commitment matched.
+func (v *snapshotVerifier) add(block *Block) (bool, error) {
+	var err error
+
+	if v.chain == nil {
+		v.chain, err = importGenesis(NewMemoryStore(), block)
+	} else {
+		err = v.chain.AcceptBlock(block)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if block.Height < v.info.Height {
+		return false, nil
+	}
+
+	if !bytes.Equal(block.Hash, v.info.TipHash) {
+		return false, fmt.Errorf("block %x at height %d is not the UTXO snapshot tip %x", block.Hash, block.Height, v.info.TipHash)
+	}
+
+	header := block.Header()
+	commitment, _, err := writeUTXOSnapshot(io.Discard, v.chain.Store, &header)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(commitment, v.info.Commitment) {
+		return false, fmt.Errorf("UTXO snapshot commitment %x does not match %x computed from the chain", v.info.Commitment, commitment)
+	}
+
+	return true, nil
+}
+
+func writeUTXOSnapshot(w io.Writer, store ChainStore, header *BlockHeader) ([]byte, int, error) {
+	hasher := sha256.New()
+	out := io.MultiWriter(w, hasher)
+
+	_, err := out.Write(append([]byte(utxoSnapshotMagic), utxoSnapshotVersion))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = writeSnapshotFields(out, header.Hash, header.PrevHash, header.TxHash,
+		header.Timestamp, int64(header.Nonce), int64(header.Height))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count := 0
+	err = store.ScanIndex([]byte(utxoPrefix), func(key, value []byte) error {
+		utxo, err := decodeUnspentOutput(value)
+		if err != nil {
+			return err
+		}
+		count++
+
+		return writeSnapshotFields(out, utxo.TxID, int64(utxo.Index), int64(utxo.Output.Value),
+			[]byte(utxo.Output.Script), int64(utxo.Height))
+	})
+	if err != nil {
+		return nil, count, err
+	}
+
+	err = writeSnapshotFields(out, []byte{})
+	if err != nil {
+		return nil, count, err
+	}
+
+	commitment := hasher.Sum(nil)
+	_, err = w.Write(commitment)
+
+	return commitment, count, err
+}
+
+func readUTXOSnapshotHeader(r io.Reader) (*BlockHeader, error) {
+	magic := make([]byte, len(utxoSnapshotMagic)+1)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, fmt.Errorf("reading UTXO snapshot header: %w", err)
+	}
+	if string(magic[:len(utxoSnapshotMagic)]) != utxoSnapshotMagic {
+		return nil, fmt.Errorf("not a UTXO snapshot")
+	}
+	if magic[len(utxoSnapshotMagic)] != utxoSnapshotVersion {
+		return nil, fmt.Errorf("unsupported UTXO snapshot version %d", magic[len(utxoSnapshotMagic)])
+	}
+
+	header := &BlockHeader{}
+	var nonce, height int64
+
+	err := readSnapshotFields(r, &header.Hash, &header.PrevHash, &header.TxHash,
+		&header.Timestamp, &nonce, &height)
+	if err != nil {
+		return nil, err
+	}
+	header.Nonce = int(nonce)
+	header.Height = int(height)
+
+	return header, nil
+}
+
+// readUTXOSnapshotEntry returns the next output, or nil after the last one.
+func readUTXOSnapshotEntry(r io.Reader) (*UnspentOutput, error) {
+	var txID, script []byte
+	var index, value, height int64
+
+	err := readSnapshotFields(r, &txID)
+	if err != nil {
+		return nil, err
+	}
+	if len(txID) == 0 {
+		return nil, nil
+	}
+
+	err = readSnapshotFields(r, &index, &value, &script, &height)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UnspentOutput{
+		TxID:   txID,
+		Index:  int(index),
+		Output: TxOutput{Value: int(value), Script: script},
+		Height: int(height),
+	}, nil
+}
+
+func writeSnapshotFields(w io.Writer, fields ...any) error {
+	buf := []byte{}
+
+	for _, field := range fields {
+		switch value := field.(type) {
+		case []byte:
+			buf = binary.BigEndian.AppendUint32(buf, uint32(len(value)))
+			buf = append(buf, value...)
+		case int64:
+			buf = binary.BigEndian.AppendUint64(buf, uint64(value))
+		default:
+			return fmt.Errorf("unsupported snapshot field %T", field)
+		}
+	}
+
+	_, err := w.Write(buf)
+
+	return err
+}
+
+func readSnapshotFields(r io.Reader, fields ...any) error {
+	for _, field := range fields {
+		switch value := field.(type) {
+		case *[]byte:
+			length := make([]byte, 4)
+			if _, err := io.ReadFull(r, length); err != nil {
+				return fmt.Errorf("UTXO snapshot is truncated: %w", err)
+			}
+
+			size := binary.BigEndian.Uint32(length)
+			if size > maxSnapshotField {
+				return fmt.Errorf("UTXO snapshot field of %d bytes is too large", size)
+			}
+
+			*value = make([]byte, size)
+			if _, err := io.ReadFull(r, *value); err != nil {
+				return fmt.Errorf("UTXO snapshot is truncated: %w", err)
+			}
+		case *int64:
+			number := make([]byte, 8)
+			if _, err := io.ReadFull(r, number); err != nil {
+				return fmt.Errorf("UTXO snapshot is truncated: %w", err)
+			}
+
+			*value = int64(binary.BigEndian.Uint64(number))
+		default:
+			return fmt.Errorf("unsupported snapshot field %T", field)
+		}
+	}
+
+	return nil
+}
+
+func markUTXOSnapshotVerified(store ChainStore, info *UTXOSnapshotInfo) error {
+	info.Verified = true
+
+	return store.Update(func(batch StoreBatch) error {
+		return putUTXOSnapshotInfo(batch, info)
+	})
+}
+
+func getUTXOSnapshotInfo(store ChainStore) (*UTXOSnapshotInfo, error) {
+	data, err := store.GetIndex(assumedUTXOKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var info UTXOSnapshotInfo
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func putUTXOSnapshotInfo(batch StoreBatch, info *UTXOSnapshotInfo) error {
+	var encoded bytes.Buffer
+
+	err := gob.NewEncoder(&encoded).Encode(info)
+	if err != nil {
+		return err
+	}
+
+	return batch.PutIndex(assumedUTXOKey, encoded.Bytes())
+}
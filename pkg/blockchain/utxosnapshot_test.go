@@ -0,0 +1,135 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func dumpUTXOSet(t *testing.T, chain *BlockChain) (*UTXOSnapshotInfo, []byte) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	info, count, err := chain.DumpUTXOSet(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _, _ := chain.UTXOSetStats(); count != want {
+		t.Fatalf("dumped %d outputs, want %d", count, want)
+	}
+
+	return info, buf.Bytes()
+}
+
+func TestUTXOSnapshotCommitment(t *testing.T) {
+	chain, alice, _ := testHistory(t)
+
+	info, snapshot := dumpUTXOSet(t, chain)
+	if info.Height != 2 || !bytes.Equal(info.TipHash, chain.LastHash) {
+		t.Fatalf("snapshot taken at %d %x, want the tip %x", info.Height, info.TipHash, chain.LastHash)
+	}
+
+	commitment := sha256.Sum256(snapshot[:len(snapshot)-sha256.Size])
+	if !bytes.Equal(info.Commitment, commitment[:]) || !bytes.Equal(snapshot[len(snapshot)-sha256.Size:], commitment[:]) {
+		t.Fatal("commitment is not the SHA-256 of the snapshot before it")
+	}
+
+	if _, again := dumpUTXOSet(t, chain); !bytes.Equal(again, snapshot) {
+		t.Fatal("dumping the same UTXO set twice gave different snapshots")
+	}
+
+	chain.MineBlock(alice)
+	if next, _ := dumpUTXOSet(t, chain); bytes.Equal(next.Commitment, info.Commitment) {
+		t.Fatal("commitment did not change with the UTXO set")
+	}
+}
+
+func TestLoadUTXOSet(t *testing.T) {
+	chain, alice, bob := testHistory(t)
+	info, snapshot := dumpUTXOSet(t, chain)
+
+	store := NewMemoryStore()
+	loaded, count, err := LoadUTXOSet(store, bytes.NewReader(snapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _, _ := chain.UTXOSetStats(); count != want || loaded.Height != info.Height || !bytes.Equal(loaded.Commitment, info.Commitment) {
+		t.Fatalf("loaded %d outputs of %+v, want %d of %+v", count, loaded, want, info)
+	}
+
+	bootstrapped, err := OpenBlockChain(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bootstrapped.Close()
+
+	for _, address := range []string{alice, bob} {
+		if got, want := balance(bootstrapped, address), balance(chain, address); got != want {
+			t.Errorf("balance of %s is %d after loading, want %d", address, got, want)
+		}
+	}
+	if assumed, ok := bootstrapped.AssumedUTXOSet(); !ok || assumed.Verified {
+		t.Fatalf("AssumedUTXOSet() = %+v, %v, want an unverified snapshot", assumed, ok)
+	}
+
+	// New blocks are accepted before the history is.
+	bootstrapped.MineBlock(bob)
+
+	if _, err := ImportChain(store, bytes.NewReader(exportChain(t, chain))); err != nil {
+		t.Fatal(err)
+	}
+	if assumed, ok := bootstrapped.AssumedUTXOSet(); !ok || !assumed.Verified {
+		t.Fatalf("AssumedUTXOSet() = %+v, %v after importing the history, want it verified", assumed, ok)
+	}
+
+	if _, _, err := LoadUTXOSet(store, bytes.NewReader(snapshot)); err == nil {
+		t.Fatal("loaded a UTXO snapshot into a chain that is not empty")
+	}
+}
+
+func TestLoadUTXOSetTampered(t *testing.T) {
+	chain, _, _ := testHistory(t)
+	_, snapshot := dumpUTXOSet(t, chain)
+
+	// The payment to bob becomes worth 31.
+	value := binary.BigEndian.AppendUint64(nil, 30)
+	at := bytes.Index(snapshot, value)
+	if at < 0 {
+		t.Fatal("payment not found in the snapshot")
+	}
+	tampered := bytes.Clone(snapshot)
+	tampered[at+len(value)-1] = 31
+
+	_, _, err := LoadUTXOSet(NewMemoryStore(), bytes.NewReader(tampered))
+	if err == nil || !strings.Contains(err.Error(), "does not match its commitment") {
+		t.Fatalf("LoadUTXOSet = %v, want a commitment mismatch", err)
+	}
+
+	// With the commitment computed again the snapshot loads, but the
+	// history does not lead to it.
+	body := tampered[:len(tampered)-sha256.Size]
+	commitment := sha256.Sum256(body)
+	tampered = append(body, commitment[:]...)
+
+	store := NewMemoryStore()
+	if _, _, err := LoadUTXOSet(store, bytes.NewReader(tampered)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ImportChain(store, bytes.NewReader(exportChain(t, chain)))
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("ImportChain = %v, want the commitment rejected", err)
+	}
+
+	bootstrapped, err := OpenBlockChain(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bootstrapped.Close()
+
+	if assumed, ok := bootstrapped.AssumedUTXOSet(); !ok || assumed.Verified {
+		t.Fatalf("AssumedUTXOSet() = %+v, %v, want the snapshot left unverified", assumed, ok)
+	}
+}
@@ -0,0 +1,274 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// MaxFutureBlockTime is how many seconds ahead of the local clock a block
+// timestamp may be.
+const MaxFutureBlockTime = 2 * 60 * 60
+
+// AcceptBlock validates block against the current tip and stores it as the
+// new tip. Transactions it confirms are dropped from the mempool, and old
+// blocks are pruned when a prune target is set. Subscribers to Events are
+// told once the block is stored. The block stays accepted when pruning fails,
+// so that failure is only logged.
+func (c *BlockChain) AcceptBlock(block *Block) error {
+	if !bytes.Equal(block.PrevHash, c.LastHash) {
+		return fmt.Errorf("block %x does not extend the tip", block.Hash)
+	}
+
+	if block.Height != c.GetBestHeight()+1 {
+		return fmt.Errorf("block %x has height %d, expected %d", block.Hash, block.Height, c.GetBestHeight()+1)
+	}
+
+	err := c.checkBlockTime(block)
+	if err != nil {
+		return err
+	}
+
+	err = c.checkBlockContents(block)
+	if err != nil {
+		return err
+	}
+
+	events := []Event{}
+	notify := c.events.hasSubscribers()
+	if notify {
+		events, err = c.minedEvents(block)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Store.Update(func(batch StoreBatch) error {
+		err := batch.PutBlock(block)
+		if err != nil {
+			return err
+		}
+
+		err = indexBlock(batch, block)
+		if err != nil {
+			return err
+		}
+
+		for _, tx := range block.Transactions {
+			err = batch.DeleteIndex(mempoolKey(tx.ID))
+			if err != nil {
+				return err
+			}
+		}
+
+		err = indexNotarizations(batch, block)
+		if err != nil {
+			return err
+		}
+
+		err = updateUTXOSet(batch, block)
+		if err != nil {
+			return err
+		}
+
+		if notify {
+			spent, err := spentUTXOs(batch, block.Hash)
+			if err != nil {
+				return err
+			}
+			events = append(events, balanceEvents(block, spent, false)...)
+		}
+
+		err = indexHTLCSpends(batch, block)
+		if err != nil {
+			return err
+		}
+
+		return batch.SetTip(block.Hash)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.LastHash = block.Hash
+	c.events.publish(append([]Event{BlockConnected{Block: block}}, events...)...)
+
+	_, err = c.Prune()
+	if err != nil {
+		log.Printf("Block %x was accepted, but pruning failed: %v", block.Hash, err)
+	}
+
+	return nil
+}
+
+// checkBlockTime requires block to be newer than its parent and not too far
+// ahead of the local clock.
+func (c *BlockChain) checkBlockTime(block *Block) error {
+	if block.Timestamp > time.Now().Unix()+MaxFutureBlockTime {
+		return fmt.Errorf("block %x has a timestamp too far in the future", block.Hash)
+	}
+
+	parent, err := c.GetHeader(block.PrevHash)
+	if err != nil {
+		return fmt.Errorf("block %x: parent header: %w", block.Hash, err)
+	}
+
+	if block.Timestamp <= parent.Timestamp {
+		return fmt.Errorf("block %x has timestamp %d, not after its parent's %d", block.Hash, block.Timestamp, parent.Timestamp)
+	}
+
+	return nil
+}
+
+func (c *BlockChain) checkBlockContents(block *Block) error {
+	err := checkProofOfWork(block)
+	if err != nil {
+		return err
+	}
+
+	spent := map[string]bool{}
+	fees := 0
+
+	for idx, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			if idx != 0 {
+				return fmt.Errorf("coinbase transaction %x is not first in block", tx.ID)
+			}
+			continue
+		}
+
+		fee, err := c.checkTransaction(tx, block.Height, block.Timestamp, spent)
+		if err != nil {
+			return err
+		}
+		fees += fee
+
+		for _, txIn := range tx.Inputs {
+			spent[outpointKey(txIn.ID, txIn.Out)] = true
+		}
+	}
+
+	if len(block.Transactions) > 0 && block.Transactions[0].IsCoinbase() {
+		coinbase := block.Transactions[0]
+
+		// Its outputs would overwrite the unspent ones of an earlier
+		// coinbase with the same ID.
+		for idx := range coinbase.Outputs {
+			_, err := c.Store.GetIndex(utxoKey(coinbase.ID, idx))
+			if err == nil {
+				return fmt.Errorf("coinbase transaction %x duplicates one with unspent outputs", coinbase.ID)
+			}
+			if err != ErrNotFound {
+				return err
+			}
+		}
+
+		reward := 0
+		for _, txOut := range coinbase.Outputs {
+			if txOut.Value < 0 {
+				return fmt.Errorf("coinbase transaction %x has a negative output", coinbase.ID)
+			}
+			reward += txOut.Value
+		}
+
+		if reward > BlockSubsidy+fees {
+			return fmt.Errorf("coinbase transaction %x pays %d, more than the subsidy and fees of %d", coinbase.ID, reward, BlockSubsidy+fees)
+		}
+	}
+
+	return nil
+}
+
+func checkProofOfWork(block *Block) error {
+	p := NewProofOfWork(block)
+	hash := sha256.Sum256(p.PrepareData(block.Nonce))
+	if !bytes.Equal(hash[:], block.Hash) || !p.Validate() {
+		return fmt.Errorf("block %x has invalid proof of work", block.Hash)
+	}
+
+	return nil
+}
+
+// CheckTransaction validates a non-coinbase transaction for inclusion in a
+// block at the given height and time. Inputs must be in the UTXO set, and
+// spent holds the outputs already spent by earlier transactions of the block.
+// An error wrapping ErrNotFinal means the transaction is otherwise valid but
+// time locked at that height and time.
+func (c *BlockChain) CheckTransaction(tx *Transaction, height int, blockTime int64, spent map[string]bool) error {
+	_, err := c.checkTransaction(tx, height, blockTime, spent)
+	return err
+}
+
+// checkTransaction is CheckTransaction, also returning the fee of tx.
+func (c *BlockChain) checkTransaction(tx *Transaction, height int, blockTime int64, spent map[string]bool) (int, error) {
+	if len(tx.Inputs) == 0 {
+		return 0, fmt.Errorf("transaction %x has no inputs", tx.ID)
+	}
+
+	txCopy := tx.TrimmedCopy()
+	if !bytes.Equal(tx.ID, txCopy.Hash()) {
+		return 0, fmt.Errorf("transaction %x has wrong ID", tx.ID)
+	}
+
+	var notFinal error
+	if !tx.IsFinal(height, blockTime) {
+		notFinal = fmt.Errorf("transaction %x: %w", tx.ID, ErrNotFinal)
+	}
+
+	inputValue := 0
+	for _, txIn := range tx.Inputs {
+		if spent[outpointKey(txIn.ID, txIn.Out)] {
+			return 0, fmt.Errorf("transaction %x spends output %x:%d twice", tx.ID, txIn.ID, txIn.Out)
+		}
+
+		utxo, err := c.FindUnspentOutput(txIn.ID, txIn.Out)
+		if err != nil {
+			return 0, fmt.Errorf("transaction %x spends missing or spent output %x:%d", tx.ID, txIn.ID, txIn.Out)
+		}
+
+		if utxo.Height+txIn.Sequence > height && notFinal == nil {
+			notFinal = fmt.Errorf("transaction %x: input %x:%d is relatively time locked: %w", tx.ID, txIn.ID, txIn.Out, ErrNotFinal)
+		}
+
+		inputValue += utxo.Output.Value
+	}
+
+	outputValue := 0
+	for _, txOut := range tx.Outputs {
+		if txOut.Value < 0 {
+			return 0, fmt.Errorf("transaction %x has a negative output", tx.ID)
+		}
+		if len(txOut.Script) > 0 && txOut.Script[0] == OpReturn {
+			if _, ok := txOut.Script.Data(); !ok || txOut.Value != 0 || len(txOut.Script) > MaxDataSize+3 {
+				return 0, fmt.Errorf("transaction %x has an invalid data output", tx.ID)
+			}
+		}
+		outputValue += txOut.Value
+	}
+
+	if outputValue > inputValue {
+		return 0, fmt.Errorf("transaction %x spends %d but only has %d", tx.ID, outputValue, inputValue)
+	}
+
+	if err := c.VerifyTransaction(tx); err != nil {
+		return 0, fmt.Errorf("transaction %x has invalid scripts: %w", tx.ID, err)
+	}
+
+	if notFinal != nil {
+		return 0, notFinal
+	}
+
+	return inputValue - outputValue, nil
+}
+
+func outpointKey(txID []byte, out int) string {
+	return fmt.Sprintf("%s:%d", hex.EncodeToString(txID), out)
+}
+
+func isNotFinal(err error) bool {
+	return errors.Is(err, ErrNotFinal)
+}
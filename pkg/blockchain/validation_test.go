@@ -0,0 +1,231 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// newTestChain returns a chain whose genesis pays the subsidy to a new key.
+func newTestChain(t *testing.T) (*BlockChain, *wallet.Wallet) {
+	t.Helper()
+
+	w := wallet.NewWallet(wallet.KeyP256)
+
+	chain, err := NewBlockChain(NewMemoryStore(), string(w.Address()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chain.Close() })
+
+	return chain, w
+}
+
+// spendGenesis returns a transaction paying value of the genesis output back
+// to w, leaving the rest as fee.
+func spendGenesis(t *testing.T, chain *BlockChain, w *wallet.Wallet, value int) *Transaction {
+	t.Helper()
+
+	utxos := chain.FindUnspentOutputs(keyLock(w.PrivateKey))
+	if len(utxos) != 1 {
+		t.Fatalf("found %d genesis outputs, want 1", len(utxos))
+	}
+
+	tx := &Transaction{
+		Inputs:  []TxInput{{ID: utxos[0].TxID, Out: utxos[0].Index}},
+		Outputs: []TxOutput{*NewTXOutput(value, string(w.Address()))},
+	}
+	tx.ID = tx.Hash()
+
+	if err := chain.SignTransaction(tx, w.PrivateKey); err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+func coinbasePaying(w *wallet.Wallet, value int) *Transaction {
+	tx := CoinbaseTx(string(w.Address()), "")
+	tx.Outputs[0].Value = value
+	tx.GenerateID()
+
+	return tx
+}
+
+func TestAcceptBlockCoinbaseReward(t *testing.T) {
+	tests := []struct {
+		name   string
+		reward int
+		fee    int
+		valid  bool
+	}{
+		{"subsidy", BlockSubsidy, 0, true},
+		{"above subsidy", BlockSubsidy + 1, 0, false},
+		{"subsidy and fees", BlockSubsidy + 10, 10, true},
+		{"above subsidy and fees", BlockSubsidy + 11, 10, false},
+		{"negative output", -1, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chain, w := newTestChain(t)
+
+			txs := []*Transaction{coinbasePaying(w, test.reward)}
+			if test.fee > 0 {
+				txs = append(txs, spendGenesis(t, chain, w, BlockSubsidy-test.fee))
+			}
+
+			block, err := chain.nextBlock(txs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = chain.AcceptBlock(block)
+			if test.valid && err != nil {
+				t.Fatalf("block rejected: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("block accepted")
+			}
+		})
+	}
+}
+
+func TestAcceptBlockTimestamp(t *testing.T) {
+	chain, w := newTestChain(t)
+
+	tip, err := chain.GetHeader(chain.LastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		timestamp int64
+		valid     bool
+	}{
+		{"before parent", tip.Timestamp - 1, false},
+		{"same as parent", tip.Timestamp, false},
+		{"too far in the future", time.Now().Unix() + MaxFutureBlockTime + 60, false},
+		{"after parent", tip.Timestamp + 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			block := newBlockAt([]*Transaction{coinbasePaying(w, BlockSubsidy)}, chain.LastHash, chain.GetBestHeight()+1, test.timestamp)
+
+			err := chain.AcceptBlock(block)
+			if test.valid && err != nil {
+				t.Fatalf("block rejected: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("block accepted")
+			}
+		})
+	}
+}
+
+func TestAcceptBlockDuplicateCoinbase(t *testing.T) {
+	chain, w := newTestChain(t)
+
+	coinbase := coinbasePaying(w, BlockSubsidy)
+	block, err := chain.nextBlock([]*Transaction{coinbase})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AcceptBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same coinbase again would overwrite its unspent output.
+	block, err = chain.nextBlock([]*Transaction{coinbase})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AcceptBlock(block); err == nil {
+		t.Fatal("block with a duplicate coinbase accepted")
+	}
+}
+
+func TestAddToMempoolChecksTransaction(t *testing.T) {
+	chain, w := newTestChain(t)
+
+	if err := chain.AddToMempool(spendGenesis(t, chain, w, BlockSubsidy+1)); err == nil {
+		t.Error("transaction creating coins was added to the mempool")
+	}
+
+	missing := spendGenesis(t, chain, w, BlockSubsidy)
+	missing.Inputs[0].Out = 1
+	missing.Inputs[0].Script = nil
+	missing.ID = missing.Hash()
+	if err := chain.AddToMempool(missing); err == nil {
+		t.Error("transaction spending a missing output was added to the mempool")
+	}
+
+	locked := spendGenesis(t, chain, w, BlockSubsidy)
+	locked.LockTime = 10
+	locked.Inputs[0].Script = nil
+	locked.ID = locked.Hash()
+	if err := chain.SignTransaction(locked, w.PrivateKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddToMempool(locked); err != nil {
+		t.Errorf("time locked transaction was not added to the mempool: %v", err)
+	}
+}
+
+func TestTimeLockedTransactions(t *testing.T) {
+	wallets := newTestWallets()
+	alice, bob, carol := wallets.AddWallet(wallet.KeyP256), wallets.AddWallet(wallet.KeyP256), wallets.AddWallet(wallet.KeyP256)
+
+	chain, err := NewBlockChain(NewMemoryStore(), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	// A payment locked until height 2 waits in the mempool for a block.
+	locked := NewTransaction(alice, bob, 30, chain, wallets, LargestFirst{}, TxOptions{LockTime: 2})
+	if err := chain.AddToMempool(locked); err != nil {
+		t.Fatal(err)
+	}
+	if block := chain.MineBlock(alice); len(block.Transactions) != 1 {
+		t.Fatalf("block at height %d mined the payment locked until height 2", block.Height)
+	}
+	if block := chain.MineBlock(alice); len(block.Transactions) != 2 {
+		t.Fatalf("block at height %d did not mine the payment locked until then", block.Height)
+	}
+
+	// An output that can only be spent two blocks after the one it is in.
+	submitMined(t, chain, NewTransaction(bob, carol, 20, chain, wallets, LargestFirst{}, TxOptions{RelativeLock: 2}))
+	paid := chain.GetBestHeight()
+
+	lock := PayToPubKeyHashScript(wallet.PublicKeyHash(wallets.Wallets[carol].PublicKey))
+	if _, _, err := chain.FindSpendableOutputs(lock, 20, LargestFirst{}); err == nil {
+		t.Fatal("the locked output was offered for spending")
+	}
+
+	// Spending it early anyway waits in the mempool as well.
+	utxos := chain.FindUnspentOutputs(lock)
+	if len(utxos) != 1 {
+		t.Fatalf("carol has %d unspent outputs, want 1", len(utxos))
+	}
+	spend := &Transaction{
+		Inputs:  []TxInput{{ID: utxos[0].TxID, Out: utxos[0].Index, Sequence: 2}},
+		Outputs: []TxOutput{*NewTXOutput(20, bob)},
+	}
+	spend.ID = spend.Hash()
+	if err := chain.SignTransaction(spend, wallets.Wallets[carol].PrivateKey); err != nil {
+		t.Fatal(err)
+	}
+	if mined, err := chain.SubmitTransaction(spend); err != nil || mined {
+		t.Fatalf("SubmitTransaction of the locked output = %v, %v, want it kept in the mempool", mined, err)
+	}
+	for chain.GetBestHeight() < paid+2 {
+		block := chain.MineBlock(alice)
+		if got := balance(chain, carol); (got == 0) != (block.Height == paid+2) {
+			t.Fatalf("carol has %d at height %d, locked until height %d", got, block.Height, paid+2)
+		}
+	}
+}
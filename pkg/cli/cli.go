@@ -1,15 +1,29 @@
 package cli
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/zivlakmilos/go-blockchain/pkg/blockchain"
+	"github.com/zivlakmilos/go-blockchain/pkg/explorer"
 	"github.com/zivlakmilos/go-blockchain/pkg/utils"
 	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+	"github.com/zivlakmilos/go-blockchain/pkg/webhook"
 )
 
 type CommandLine struct{}
@@ -21,11 +35,45 @@ func NewCommandLine() *CommandLine {
 func (c *CommandLine) printUsage() {
 	fmt.Printf("Usage:\n")
 	fmt.Printf("  balance -address ADDRESS - get balance for an address\n")
-	fmt.Printf("  create -address ADDRESS - creates a blockchain and sends genesis transaction to address\n")
-	fmt.Printf("  print - Prints the blocks in the chain\n")
-	fmt.Printf("  send -from FROM -to TO -amount AMOUNT - Send amount of coins\n")
-	fmt.Printf("  createwallet - Create a new wallet\n")
-	fmt.Printf("  listwallets - List all wallet addresses\n")
+	fmt.Printf("  create -address ADDRESS [-prune TARGET_MB] - creates a blockchain and sends genesis transaction to address\n")
+	fmt.Printf("  print [-forward] [-from HEIGHT] [-to HEIGHT] [-headers] - Prints the blocks in the chain, newest first unless -forward or a range is given\n")
+	fmt.Printf("  send -from FROM -to TO|LABEL -amount AMOUNT [-strategy largest|smallest|bnb|random] [-locktime HEIGHT|TIME] [-relativelock BLOCKS] - Send amount of coins\n")
+	fmt.Printf("  mine -address ADDRESS - Mine a block with the ready mempool transactions\n")
+	fmt.Printf("  mempool - Prints the transactions waiting in the mempool\n")
+	fmt.Printf("  createwallet [-type p256|secp256k1|ed25519|schnorr] [-bech32] - Create a new wallet\n")
+	fmt.Printf("  listwallets [-json] - List wallet addresses with their labels, balances and creation times\n")
+	fmt.Printf("  setlabel -address ADDRESS -label LABEL - Label a wallet address, an empty label removes it\n")
+	fmt.Printf("  addcontact -label LABEL -address ADDRESS - Add a recipient to the address book\n")
+	fmt.Printf("  removecontact -label LABEL - Remove a recipient from the address book\n")
+	fmt.Printf("  listcontacts - List the address book\n")
+	fmt.Printf("  vanityaddress -prefix PREFIX [-workers N] [-case-insensitive] [-type TYPE] - Create a wallet whose address starts with PREFIX\n")
+	fmt.Printf("  pubkey -address ADDRESS - Print the public key of a wallet\n")
+	fmt.Printf("  createmultisig -m M -pubkeys KEY,KEY,... - Create an M-of-N multisig address\n")
+	fmt.Printf("  createaggregate -pubkeys KEY,KEY,... - Create an N-of-N address from aggregated schnorr keys\n")
+	fmt.Printf("  createrawtx -from FROM -to TO -amount AMOUNT -out FILE - Create an unsigned transaction for offline signing, also the only way to spend from an aggregated key address\n")
+	fmt.Printf("  decoderawtx -in FILE - Prints a raw transaction and the outputs it spends\n")
+	fmt.Printf("  signrawtx -in FILE -address ADDRESS - Add a wallet signature to a raw transaction, without the chain\n")
+	fmt.Printf("  musignonce -in FILE -address ADDRESS - First aggregated signing round: add the wallet's MuSig nonces to a raw transaction\n")
+	fmt.Printf("  musigsign -in FILE -address ADDRESS - Second aggregated signing round: add the wallet's partial signatures once all nonces are in\n")
+	fmt.Printf("  sendrawtx -in FILE - Send a fully signed raw transaction\n")
+	fmt.Printf("  createhtlc -from FROM -to TO -amount AMOUNT -timeout HEIGHT|TIME [-hash HASH] - Lock coins in a hash time-locked contract\n")
+	fmt.Printf("  claimhtlc -txid TXID -out OUT -preimage PREIMAGE -address ADDRESS - Claim an HTLC with its preimage\n")
+	fmt.Printf("  refundhtlc -txid TXID -out OUT -address ADDRESS - Refund an HTLC after its timeout\n")
+	fmt.Printf("  htlcpreimage -txid TXID -out OUT - Print the preimage revealed by an HTLC claim\n")
+	fmt.Printf("  notarize -from FROM -data HEX|-file PATH - Anchor data or the SHA-256 digest of a file on chain\n")
+	fmt.Printf("  findnotarization -hash HEX - Find where data was notarized\n")
+	fmt.Printf("  exportchain -out FILE [-from HEIGHT] [-to HEIGHT] - Write the blocks to a portable snapshot file\n")
+	fmt.Printf("  importchain -in FILE [-prune TARGET_MB] - Validate and add the blocks of a snapshot file\n")
+	fmt.Printf("  disconnectblock [-count N] - Take the last N blocks off the chain, returning their transactions to the mempool\n")
+	fmt.Printf("  prune -target TARGET_MB - Delete old block bodies to keep the chain under TARGET_MB, 0 turns pruning off\n")
+	fmt.Printf("  chaininfo - Print the tip, pruning state and advertised services\n")
+	fmt.Printf("  dumputxoset -out FILE - Write the unspent outputs at the tip with a commitment hash\n")
+	fmt.Printf("  loadutxoset -in FILE - Bootstrap an empty node from a UTXO snapshot, verified later by importchain\n")
+	fmt.Printf("  explorer [-listen ADDR] [-submit] - Serve a block explorer, as a JSON API, as web pages under /ui/ and as a WebSocket stream at /ws; -submit accepts POST /tx, POST /mine and POST /disconnect\n")
+	fmt.Printf("  addwebhook -address ADDRESS -url URL [-confirmations N] - POST signed notifications when ADDRESS receives or spends coins, and again at N confirmations\n")
+	fmt.Printf("  removewebhook -id ID - Remove a webhook\n")
+	fmt.Printf("  webhooks - List the webhooks and the delivery log\n")
+	fmt.Printf("  notify [-timeout DURATION] - Deliver the webhook notifications for new activity, retrying failures with backoff for up to DURATION and leaving the rest queued\n")
 }
 
 func (c *CommandLine) validateArgs() {
@@ -42,15 +90,158 @@ func (c *CommandLine) Run() {
 	createCmd := flag.NewFlagSet("create", flag.ExitOnError)
 	printCmd := flag.NewFlagSet("print", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	mineCmd := flag.NewFlagSet("mine", flag.ExitOnError)
+	mempoolCmd := flag.NewFlagSet("mempool", flag.ExitOnError)
 	createWallet := flag.NewFlagSet("createwallet", flag.ExitOnError)
-	listWallets := flag.NewFlagSet("listwallet", flag.ExitOnError)
+	listWallets := flag.NewFlagSet("listwallets", flag.ExitOnError)
+	setLabelCmd := flag.NewFlagSet("setlabel", flag.ExitOnError)
+	addContactCmd := flag.NewFlagSet("addcontact", flag.ExitOnError)
+	removeContactCmd := flag.NewFlagSet("removecontact", flag.ExitOnError)
+	listContactsCmd := flag.NewFlagSet("listcontacts", flag.ExitOnError)
+	vanityAddressCmd := flag.NewFlagSet("vanityaddress", flag.ExitOnError)
+	pubKeyCmd := flag.NewFlagSet("pubkey", flag.ExitOnError)
+	createMultiSigCmd := flag.NewFlagSet("createmultisig", flag.ExitOnError)
+	createAggregateCmd := flag.NewFlagSet("createaggregate", flag.ExitOnError)
+	createRawTxCmd := flag.NewFlagSet("createrawtx", flag.ExitOnError)
+	decodeRawTxCmd := flag.NewFlagSet("decoderawtx", flag.ExitOnError)
+	signRawTxCmd := flag.NewFlagSet("signrawtx", flag.ExitOnError)
+	musigNonceCmd := flag.NewFlagSet("musignonce", flag.ExitOnError)
+	musigSignCmd := flag.NewFlagSet("musigsign", flag.ExitOnError)
+	sendRawTxCmd := flag.NewFlagSet("sendrawtx", flag.ExitOnError)
+	createHTLCCmd := flag.NewFlagSet("createhtlc", flag.ExitOnError)
+	claimHTLCCmd := flag.NewFlagSet("claimhtlc", flag.ExitOnError)
+	refundHTLCCmd := flag.NewFlagSet("refundhtlc", flag.ExitOnError)
+	htlcPreimageCmd := flag.NewFlagSet("htlcpreimage", flag.ExitOnError)
+	notarizeCmd := flag.NewFlagSet("notarize", flag.ExitOnError)
+	findNotarizationCmd := flag.NewFlagSet("findnotarization", flag.ExitOnError)
+	exportChainCmd := flag.NewFlagSet("exportchain", flag.ExitOnError)
+	importChainCmd := flag.NewFlagSet("importchain", flag.ExitOnError)
+	disconnectBlockCmd := flag.NewFlagSet("disconnectblock", flag.ExitOnError)
+	pruneCmd := flag.NewFlagSet("prune", flag.ExitOnError)
+	chainInfoCmd := flag.NewFlagSet("chaininfo", flag.ExitOnError)
+	dumpUTXOSetCmd := flag.NewFlagSet("dumputxoset", flag.ExitOnError)
+	loadUTXOSetCmd := flag.NewFlagSet("loadutxoset", flag.ExitOnError)
+	explorerCmd := flag.NewFlagSet("explorer", flag.ExitOnError)
+	addWebhookCmd := flag.NewFlagSet("addwebhook", flag.ExitOnError)
+	removeWebhookCmd := flag.NewFlagSet("removewebhook", flag.ExitOnError)
+	webhooksCmd := flag.NewFlagSet("webhooks", flag.ExitOnError)
+	notifyCmd := flag.NewFlagSet("notify", flag.ExitOnError)
 
 	balanceAddress := balanceCmd.String("address", "", "Address")
 	createAddress := createCmd.String("address", "", "Address")
+	createPrune := createCmd.Int("prune", 0, "Prune old blocks to keep the chain under this many MB")
+
+	printForward := printCmd.Bool("forward", false, "Print from genesis up to the tip")
+	printFrom := printCmd.Int("from", 0, "First block height")
+	printTo := printCmd.Int("to", -1, "Last block height, the tip when negative")
+	printHeaders := printCmd.Bool("headers", false, "Print only the block headers")
 
 	sendFrom := sendCmd.String("from", "", "From")
-	sendTo := sendCmd.String("to", "", "To")
+	sendTo := sendCmd.String("to", "", "To address or label")
 	sendAmount := sendCmd.Int("amount", 0, "Amount")
+	sendStrategy := sendCmd.String("strategy", "largest", "Coin selection strategy")
+	sendLockTime := sendCmd.Int64("locktime", 0, "Block height or Unix time before which the transaction cannot be mined")
+	sendRelativeLock := sendCmd.Int("relativelock", 0, "Blocks the payment stays locked after it is mined")
+
+	mineAddress := mineCmd.String("address", "", "Address")
+
+	createWalletType := createWallet.String("type", "p256", "Key type: p256, secp256k1, ed25519 or schnorr")
+	createWalletBech32 := createWallet.Bool("bech32", false, "Print the address in Bech32 format")
+
+	listWalletsJSON := listWallets.Bool("json", false, "Print the wallets as JSON")
+
+	setLabelAddress := setLabelCmd.String("address", "", "Address")
+	setLabelLabel := setLabelCmd.String("label", "", "Label")
+
+	addContactLabel := addContactCmd.String("label", "", "Label")
+	addContactAddress := addContactCmd.String("address", "", "Address")
+
+	removeContactLabel := removeContactCmd.String("label", "", "Label")
+
+	vanityAddressPrefix := vanityAddressCmd.String("prefix", "", "Address prefix, including the leading version character")
+	vanityAddressWorkers := vanityAddressCmd.Int("workers", runtime.NumCPU(), "Number of parallel workers")
+	vanityAddressCaseInsensitive := vanityAddressCmd.Bool("case-insensitive", false, "Match the prefix ignoring case")
+	vanityAddressType := vanityAddressCmd.String("type", "p256", "Key type: p256, secp256k1, ed25519 or schnorr")
+
+	pubKeyAddress := pubKeyCmd.String("address", "", "Address")
+
+	createMultiSigM := createMultiSigCmd.Int("m", 0, "Required signatures")
+	createMultiSigPubKeys := createMultiSigCmd.String("pubkeys", "", "Comma separated public keys")
+
+	createAggregatePubKeys := createAggregateCmd.String("pubkeys", "", "Comma separated schnorr public keys")
+
+	createRawTxFrom := createRawTxCmd.String("from", "", "From")
+	createRawTxTo := createRawTxCmd.String("to", "", "To")
+	createRawTxAmount := createRawTxCmd.Int("amount", 0, "Amount")
+	createRawTxStrategy := createRawTxCmd.String("strategy", "largest", "Coin selection strategy")
+	createRawTxLockTime := createRawTxCmd.Int64("locktime", 0, "Block height or Unix time before which the transaction cannot be mined")
+	createRawTxRelativeLock := createRawTxCmd.Int("relativelock", 0, "Blocks the payment stays locked after it is mined")
+	createRawTxOut := createRawTxCmd.String("out", "", "Output file")
+
+	decodeRawTxIn := decodeRawTxCmd.String("in", "", "Transaction file")
+
+	signRawTxIn := signRawTxCmd.String("in", "", "Transaction file")
+	signRawTxAddress := signRawTxCmd.String("address", "", "Address")
+
+	musigNonceIn := musigNonceCmd.String("in", "", "Transaction file")
+	musigNonceAddress := musigNonceCmd.String("address", "", "Address")
+
+	musigSignIn := musigSignCmd.String("in", "", "Transaction file")
+	musigSignAddress := musigSignCmd.String("address", "", "Address")
+
+	sendRawTxIn := sendRawTxCmd.String("in", "", "Transaction file")
+
+	createHTLCFrom := createHTLCCmd.String("from", "", "From")
+	createHTLCTo := createHTLCCmd.String("to", "", "To")
+	createHTLCAmount := createHTLCCmd.Int("amount", 0, "Amount")
+	createHTLCTimeout := createHTLCCmd.Int64("timeout", 0, "Block height or Unix time after which the sender can refund")
+	createHTLCHash := createHTLCCmd.String("hash", "", "SHA-256 hash of the secret, generated when empty")
+	createHTLCStrategy := createHTLCCmd.String("strategy", "largest", "Coin selection strategy")
+
+	claimHTLCTxID := claimHTLCCmd.String("txid", "", "HTLC transaction ID")
+	claimHTLCOut := claimHTLCCmd.Int("out", 0, "HTLC output index")
+	claimHTLCPreimage := claimHTLCCmd.String("preimage", "", "Secret preimage")
+	claimHTLCAddress := claimHTLCCmd.String("address", "", "Recipient address")
+
+	refundHTLCTxID := refundHTLCCmd.String("txid", "", "HTLC transaction ID")
+	refundHTLCOut := refundHTLCCmd.Int("out", 0, "HTLC output index")
+	refundHTLCAddress := refundHTLCCmd.String("address", "", "Sender address")
+
+	htlcPreimageTxID := htlcPreimageCmd.String("txid", "", "HTLC transaction ID")
+	htlcPreimageOut := htlcPreimageCmd.Int("out", 0, "HTLC output index")
+
+	notarizeFrom := notarizeCmd.String("from", "", "From")
+	notarizeData := notarizeCmd.String("data", "", "Hex data to anchor")
+	notarizeFile := notarizeCmd.String("file", "", "File whose SHA-256 digest is anchored")
+	notarizeStrategy := notarizeCmd.String("strategy", "largest", "Coin selection strategy")
+
+	findNotarizationHash := findNotarizationCmd.String("hash", "", "Hex data or file digest")
+
+	exportChainOut := exportChainCmd.String("out", "", "Snapshot file")
+	exportChainFrom := exportChainCmd.Int("from", 0, "First block height")
+	exportChainTo := exportChainCmd.Int("to", -1, "Last block height, the tip when negative")
+
+	importChainIn := importChainCmd.String("in", "", "Snapshot file")
+	importChainPrune := importChainCmd.Int("prune", 0, "Prune old blocks to keep the chain under this many MB")
+
+	disconnectBlockCount := disconnectBlockCmd.Int("count", 1, "Number of blocks")
+
+	pruneTarget := pruneCmd.Int("target", -1, "Target size in MB, 0 turns pruning off")
+
+	dumpUTXOSetOut := dumpUTXOSetCmd.String("out", "", "Snapshot file")
+
+	loadUTXOSetIn := loadUTXOSetCmd.String("in", "", "Snapshot file")
+
+	explorerListen := explorerCmd.String("listen", "localhost:8080", "Address to listen on")
+	explorerSubmit := explorerCmd.Bool("submit", false, "Accept transactions, mining and disconnect requests")
+
+	addWebhookAddress := addWebhookCmd.String("address", "", "Address to watch")
+	addWebhookURL := addWebhookCmd.String("url", "", "URL to POST notifications to")
+	addWebhookConfirmations := addWebhookCmd.Int("confirmations", 1, "Confirmations to notify again at, 0 for none")
+
+	removeWebhookID := removeWebhookCmd.String("id", "", "Webhook ID")
+
+	notifyTimeout := notifyCmd.Duration("timeout", 30*time.Second, "How long to retry failed deliveries before leaving them queued")
 
 	switch os.Args[1] {
 	case "balance":
@@ -65,12 +256,114 @@ func (c *CommandLine) Run() {
 	case "send":
 		err := sendCmd.Parse(os.Args[2:])
 		utils.HandleError(err)
+	case "mine":
+		err := mineCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "mempool":
+		err := mempoolCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
 	case "createwallet":
 		err := createWallet.Parse(os.Args[2:])
 		utils.HandleError(err)
 	case "listwallets":
 		err := listWallets.Parse(os.Args[2:])
 		utils.HandleError(err)
+	case "setlabel":
+		err := setLabelCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "addcontact":
+		err := addContactCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "removecontact":
+		err := removeContactCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "listcontacts":
+		err := listContactsCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "vanityaddress":
+		err := vanityAddressCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "pubkey":
+		err := pubKeyCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "createmultisig":
+		err := createMultiSigCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "createaggregate":
+		err := createAggregateCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "createrawtx":
+		err := createRawTxCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "decoderawtx":
+		err := decodeRawTxCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "signrawtx":
+		err := signRawTxCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "musignonce":
+		err := musigNonceCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "musigsign":
+		err := musigSignCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "sendrawtx":
+		err := sendRawTxCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "createhtlc":
+		err := createHTLCCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "claimhtlc":
+		err := claimHTLCCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "refundhtlc":
+		err := refundHTLCCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "htlcpreimage":
+		err := htlcPreimageCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "notarize":
+		err := notarizeCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "findnotarization":
+		err := findNotarizationCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "exportchain":
+		err := exportChainCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "importchain":
+		err := importChainCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "disconnectblock":
+		err := disconnectBlockCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "prune":
+		err := pruneCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "chaininfo":
+		err := chainInfoCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "dumputxoset":
+		err := dumpUTXOSetCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "loadutxoset":
+		err := loadUTXOSetCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "explorer":
+		err := explorerCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "addwebhook":
+		err := addWebhookCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "removewebhook":
+		err := removeWebhookCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "webhooks":
+		err := webhooksCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
+	case "notify":
+		err := notifyCmd.Parse(os.Args[2:])
+		utils.HandleError(err)
 	default:
 		c.printUsage()
 		runtime.Goexit()
@@ -89,11 +382,15 @@ func (c *CommandLine) Run() {
 			createCmd.Usage()
 			runtime.Goexit()
 		}
-		c.handleCreate(*createAddress)
+		c.handleCreate(*createAddress, *createPrune)
 	}
 
 	if printCmd.Parsed() {
-		c.handlePrint()
+		if !*printForward && !*printHeaders && *printFrom == 0 && *printTo < 0 {
+			c.handlePrint()
+		} else {
+			c.handlePrintRange(*printFrom, *printTo, *printHeaders)
+		}
 	}
 
 	if sendCmd.Parsed() {
@@ -101,32 +398,287 @@ func (c *CommandLine) Run() {
 			sendCmd.Usage()
 			runtime.Goexit()
 		}
-		c.handleSend(*sendFrom, *sendTo, *sendAmount)
+		opts := blockchain.TxOptions{
+			LockTime:     *sendLockTime,
+			RelativeLock: *sendRelativeLock,
+		}
+		c.handleSend(*sendFrom, *sendTo, *sendAmount, *sendStrategy, opts)
+	}
+
+	if mineCmd.Parsed() {
+		if *mineAddress == "" {
+			mineCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleMine(*mineAddress)
+	}
+
+	if mempoolCmd.Parsed() {
+		c.handleMempool()
 	}
 
 	if createWallet.Parsed() {
-		c.handleCreateWallet()
+		c.handleCreateWallet(*createWalletType, *createWalletBech32)
 	}
 
 	if listWallets.Parsed() {
-		c.handleListWallts()
+		c.handleListWallets(*listWalletsJSON)
+	}
+
+	if setLabelCmd.Parsed() {
+		if *setLabelAddress == "" {
+			setLabelCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleSetLabel(*setLabelAddress, *setLabelLabel)
+	}
+
+	if addContactCmd.Parsed() {
+		if *addContactLabel == "" || *addContactAddress == "" {
+			addContactCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleAddContact(*addContactLabel, *addContactAddress)
+	}
+
+	if removeContactCmd.Parsed() {
+		if *removeContactLabel == "" {
+			removeContactCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleRemoveContact(*removeContactLabel)
+	}
+
+	if listContactsCmd.Parsed() {
+		c.handleListContacts()
+	}
+
+	if vanityAddressCmd.Parsed() {
+		if *vanityAddressPrefix == "" {
+			vanityAddressCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleVanityAddress(*vanityAddressPrefix, *vanityAddressWorkers, *vanityAddressCaseInsensitive, *vanityAddressType)
+	}
+
+	if pubKeyCmd.Parsed() {
+		if *pubKeyAddress == "" {
+			pubKeyCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handlePubKey(*pubKeyAddress)
+	}
+
+	if createMultiSigCmd.Parsed() {
+		if *createMultiSigM == 0 || *createMultiSigPubKeys == "" {
+			createMultiSigCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleCreateMultiSig(*createMultiSigM, strings.Split(*createMultiSigPubKeys, ","))
+	}
+
+	if createAggregateCmd.Parsed() {
+		if *createAggregatePubKeys == "" {
+			createAggregateCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleCreateAggregate(strings.Split(*createAggregatePubKeys, ","))
+	}
+
+	if createRawTxCmd.Parsed() {
+		if *createRawTxFrom == "" || *createRawTxTo == "" || *createRawTxAmount == 0 || *createRawTxOut == "" {
+			createRawTxCmd.Usage()
+			runtime.Goexit()
+		}
+		opts := blockchain.TxOptions{
+			LockTime:     *createRawTxLockTime,
+			RelativeLock: *createRawTxRelativeLock,
+		}
+		c.handleCreateRawTx(*createRawTxFrom, *createRawTxTo, *createRawTxAmount, *createRawTxStrategy, opts, *createRawTxOut)
+	}
+
+	if decodeRawTxCmd.Parsed() {
+		if *decodeRawTxIn == "" {
+			decodeRawTxCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleDecodeRawTx(*decodeRawTxIn)
+	}
+
+	if signRawTxCmd.Parsed() {
+		if *signRawTxIn == "" || *signRawTxAddress == "" {
+			signRawTxCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleSignRawTx(*signRawTxIn, *signRawTxAddress)
+	}
+
+	if musigNonceCmd.Parsed() {
+		if *musigNonceIn == "" || *musigNonceAddress == "" {
+			musigNonceCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleMuSigNonce(*musigNonceIn, *musigNonceAddress)
+	}
+
+	if musigSignCmd.Parsed() {
+		if *musigSignIn == "" || *musigSignAddress == "" {
+			musigSignCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleMuSigSign(*musigSignIn, *musigSignAddress)
+	}
+
+	if sendRawTxCmd.Parsed() {
+		if *sendRawTxIn == "" {
+			sendRawTxCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleSendRawTx(*sendRawTxIn)
+	}
+
+	if createHTLCCmd.Parsed() {
+		if *createHTLCFrom == "" || *createHTLCTo == "" || *createHTLCAmount == 0 || *createHTLCTimeout == 0 {
+			createHTLCCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleCreateHTLC(*createHTLCFrom, *createHTLCTo, *createHTLCAmount, *createHTLCTimeout, *createHTLCHash, *createHTLCStrategy)
+	}
+
+	if claimHTLCCmd.Parsed() {
+		if *claimHTLCTxID == "" || *claimHTLCPreimage == "" || *claimHTLCAddress == "" {
+			claimHTLCCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleClaimHTLC(*claimHTLCTxID, *claimHTLCOut, *claimHTLCPreimage, *claimHTLCAddress)
+	}
+
+	if refundHTLCCmd.Parsed() {
+		if *refundHTLCTxID == "" || *refundHTLCAddress == "" {
+			refundHTLCCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleRefundHTLC(*refundHTLCTxID, *refundHTLCOut, *refundHTLCAddress)
+	}
+
+	if htlcPreimageCmd.Parsed() {
+		if *htlcPreimageTxID == "" {
+			htlcPreimageCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleHTLCPreimage(*htlcPreimageTxID, *htlcPreimageOut)
+	}
+
+	if notarizeCmd.Parsed() {
+		if *notarizeFrom == "" || (*notarizeData == "") == (*notarizeFile == "") {
+			notarizeCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleNotarize(*notarizeFrom, *notarizeData, *notarizeFile, *notarizeStrategy)
+	}
+
+	if findNotarizationCmd.Parsed() {
+		if *findNotarizationHash == "" {
+			findNotarizationCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleFindNotarization(*findNotarizationHash)
+	}
+
+	if exportChainCmd.Parsed() {
+		if *exportChainOut == "" {
+			exportChainCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleExportChain(*exportChainOut, *exportChainFrom, *exportChainTo)
+	}
+
+	if importChainCmd.Parsed() {
+		if *importChainIn == "" {
+			importChainCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleImportChain(*importChainIn, *importChainPrune)
+	}
+
+	if disconnectBlockCmd.Parsed() {
+		if *disconnectBlockCount <= 0 {
+			disconnectBlockCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleDisconnectBlock(*disconnectBlockCount)
+	}
+
+	if pruneCmd.Parsed() {
+		if *pruneTarget < 0 {
+			pruneCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handlePrune(*pruneTarget)
+	}
+
+	if chainInfoCmd.Parsed() {
+		c.handleChainInfo()
+	}
+
+	if dumpUTXOSetCmd.Parsed() {
+		if *dumpUTXOSetOut == "" {
+			dumpUTXOSetCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleDumpUTXOSet(*dumpUTXOSetOut)
+	}
+
+	if loadUTXOSetCmd.Parsed() {
+		if *loadUTXOSetIn == "" {
+			loadUTXOSetCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleLoadUTXOSet(*loadUTXOSetIn)
+	}
+
+	if explorerCmd.Parsed() {
+		c.handleExplorer(*explorerListen, *explorerSubmit)
+	}
+
+	if addWebhookCmd.Parsed() {
+		if *addWebhookAddress == "" || *addWebhookURL == "" {
+			addWebhookCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleAddWebhook(*addWebhookAddress, *addWebhookURL, *addWebhookConfirmations)
+	}
+
+	if removeWebhookCmd.Parsed() {
+		if *removeWebhookID == "" {
+			removeWebhookCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleRemoveWebhook(*removeWebhookID)
+	}
+
+	if webhooksCmd.Parsed() {
+		c.handleWebhooks()
+	}
+
+	if notifyCmd.Parsed() {
+		if *notifyTimeout <= 0 {
+			notifyCmd.Usage()
+			runtime.Goexit()
+		}
+		c.handleNotify(*notifyTimeout)
 	}
 }
 
 func (c *CommandLine) handleBalance(address string) {
-	if !wallet.ValidateAddress(address) {
-		log.Panic("address is not valid")
-	}
+	parsed := c.parseAddress(address)
 
-	chain := blockchain.OpenBlockChain("")
-	defer chain.Database.Close()
+	chain := c.openChain()
+	defer chain.Close()
 
 	amount := 0
 
-	pubKeyHash := utils.Base58Decode([]byte(address))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-
-	UTXOs := chain.FindUTXO(pubKeyHash)
+	UTXOs := chain.FindUTXO(blockchain.LockingScript(parsed))
 	for _, txo := range UTXOs {
 		amount += txo.Value
 	}
@@ -134,48 +686,880 @@ func (c *CommandLine) handleBalance(address string) {
 	fmt.Printf("Balance [%s]: %d\n", address, amount)
 }
 
-func (c *CommandLine) handleCreate(address string) {
-	chain := blockchain.NewBlockChain(address)
-	defer chain.Database.Close()
+func (c *CommandLine) handleCreate(address string, pruneMB int) {
+	c.parseAddress(address)
+
+	if blockchain.ChainExists() {
+		fmt.Printf("Blockchain already exists")
+		runtime.Goexit()
+	}
+
+	store, err := blockchain.OpenDefaultStore()
+	utils.HandleError(err)
+
+	err = blockchain.SetPruneTarget(store, pruneMB)
+	utils.HandleError(err)
+
+	chain, err := blockchain.NewBlockChain(store, address)
+	utils.HandleError(err)
+	defer chain.Close()
 }
 
 func (c *CommandLine) handlePrint() {
-	chain := blockchain.OpenBlockChain("")
-	defer chain.Database.Close()
+	chain := c.openChain()
+	defer chain.Close()
 
 	fmt.Printf("%v\n", chain)
 }
 
-func (c *CommandLine) handleSend(from, to string, amount int) {
-	if !wallet.ValidateAddress(from) {
-		log.Panic("address is not valid")
+func (c *CommandLine) handlePrintRange(from, to int, headers bool) {
+	chain := c.openChain()
+	defer chain.Close()
+
+	if headers {
+		it := chain.HeaderIterator(from, to)
+		for header := range it.Headers() {
+			fmt.Printf("=== Block %x (height %d, time %d)\n", header.Hash, header.Height, header.Timestamp)
+			fmt.Printf("    Prev:   %x\n", header.PrevHash)
+			fmt.Printf("    TxHash: %x\n", header.TxHash)
+		}
+		utils.HandleError(it.Err())
+		return
+	}
+
+	it := chain.RangeIterator(from, to)
+	for block := range it.All() {
+		fmt.Printf("%v\n", block)
 	}
-	if !wallet.ValidateAddress(to) {
-		log.Panic("address is not valid")
+	utils.HandleError(it.Err())
+}
+
+func (c *CommandLine) handleSend(from, to string, amount int, strategy string, opts blockchain.TxOptions) {
+	c.parseAddress(from)
+
+	wallets, _ := wallet.NewWallets()
+	recipient, err := wallets.ResolveAddress(to)
+	utils.HandleError(err)
+	to = recipient.String()
+
+	selector, err := blockchain.NewCoinSelector(strategy)
+	utils.HandleError(err)
+
+	chain := c.openChain()
+	defer chain.Close()
+
+	if _, ok := wallets.GetAggregate(from); ok {
+		log.Panicf("%s is an aggregated key address; spend from it with createrawtx, musignonce and musigsign by every cosigner, then sendrawtx", from)
 	}
 
-	chain := blockchain.OpenBlockChain("")
-	defer chain.Database.Close()
+	tx := blockchain.NewTransaction(from, to, amount, chain, wallets, selector, opts)
+	c.submitTransaction(chain, tx)
+}
+
+func (c *CommandLine) handleMine(address string) {
+	c.parseAddress(address)
+
+	chain := c.openChain()
+	defer chain.Close()
 
-	tx := blockchain.NewTransaction(from, to, amount, chain)
-	chain.AddBlock([]*blockchain.Transaction{tx})
+	printActivity := c.watchWallets(chain)
+	block := chain.MineBlock(address)
+
+	fmt.Printf("Mined block %x at height %d with %d transaction(s)\n", block.Hash, block.Height, len(block.Transactions))
+	printActivity()
+}
+
+func (c *CommandLine) handleMempool() {
+	chain := c.openChain()
+	defer chain.Close()
+
+	for _, tx := range chain.MempoolTransactions() {
+		fmt.Printf("%v\n", tx)
+	}
+}
+
+func (c *CommandLine) submitTransaction(chain *blockchain.BlockChain, tx *blockchain.Transaction) {
+	printActivity := c.watchWallets(chain)
+	mined, err := chain.SubmitTransaction(tx)
+	utils.HandleError(err)
+
+	if !mined {
+		fmt.Printf("Transaction %x is not final yet and was added to the mempool\n", tx.ID)
+		return
+	}
 
 	fmt.Printf("Success!\n")
+	printActivity()
+}
+
+// watchWallets subscribes to the events of chain. The returned function
+// prints the ones published since that matter to the user: disconnected
+// blocks, transactions dropped from the mempool and the balance changes of
+// the wallet's own addresses.
+func (c *CommandLine) watchWallets(chain *blockchain.BlockChain) func() {
+	wallets, _ := wallet.NewWallets()
+	events := chain.Events().Subscribe(1024, blockchain.CloseOnOverflow)
+
+	return func() {
+		defer events.Close()
+
+		for {
+			var event blockchain.Event
+			select {
+			case e, ok := <-events.Events():
+				if !ok {
+					if events.Err() != nil {
+						fmt.Printf("Too much activity to show, check the balances with listwallets\n")
+					}
+					return
+				}
+				event = e
+			default:
+				return
+			}
+
+			switch e := event.(type) {
+			case blockchain.BlockDisconnected:
+				fmt.Printf("Disconnected block %x at height %d\n", e.Block.Hash, e.Block.Height)
+			case blockchain.TxRemovedFromMempool:
+				if e.Reason == blockchain.RemovedInvalid {
+					fmt.Printf("Dropped transaction %x from the mempool, it can no longer be mined\n", e.Tx.ID)
+				}
+			case blockchain.WalletBalanceChanged:
+				address := e.Address.String()
+				if e.Address.Hash == nil || !wallets.IsOwnAddress(address) {
+					continue
+				}
+				if label := wallets.Label(address); label != "" {
+					address = fmt.Sprintf("%s (%s)", address, label)
+				}
+				fmt.Printf("Wallet %s: received %d, sent %d\n", address, e.Received, e.Sent)
+			}
+		}
+	}
+}
+
+type walletEntry struct {
+	Address string `json:"address"`
+	Label   string `json:"label,omitempty"`
+	Type    string `json:"type"`
+	Balance int    `json:"balance"`
+	Created string `json:"created,omitempty"`
 }
 
-func (c *CommandLine) handleListWallts() {
+func (c *CommandLine) handleListWallets(asJSON bool) {
 	wallets, _ := wallet.NewWallets()
 	addresses := wallets.GetAllAddresses()
 
+	sort.SliceStable(addresses, func(i, j int) bool {
+		return wallets.Wallets[addresses[i]].Created.Before(wallets.Wallets[addresses[j]].Created)
+	})
+
+	var chain *blockchain.BlockChain
+	if blockchain.ChainExists() {
+		chain = c.openChain()
+		defer chain.Close()
+	}
+
+	entries := []walletEntry{}
 	for _, address := range addresses {
-		fmt.Printf("%s\n", address)
+		w := wallets.Wallets[address]
+		entry := walletEntry{
+			Address: address,
+			Label:   wallets.Label(address),
+			Type:    w.PrivateKey.KeyType().String(),
+		}
+		if !w.Created.IsZero() {
+			entry.Created = w.Created.UTC().Format(time.RFC3339)
+		}
+		if chain != nil {
+			for _, txo := range chain.FindUTXO(blockchain.LockingScript(c.parseAddress(address))) {
+				entry.Balance += txo.Value
+			}
+		}
+
+		entries = append(entries, entry)
 	}
+
+	if asJSON {
+		content, err := json.MarshalIndent(entries, "", "  ")
+		utils.HandleError(err)
+		fmt.Printf("%s\n", content)
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "ADDRESS\tLABEL\tTYPE\tBALANCE\tCREATED\n")
+	for _, entry := range entries {
+		created := entry.Created
+		if created == "" {
+			created = "-"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%s\n", entry.Address, entry.Label, entry.Type, entry.Balance, created)
+	}
+	writer.Flush()
 }
 
-func (c *CommandLine) handleCreateWallet() {
+func (c *CommandLine) handleSetLabel(address, label string) {
+	wallets, err := wallet.NewWallets()
+	utils.HandleError(err)
+
+	err = wallets.SetLabel(address, label)
+	utils.HandleError(err)
+	wallets.SaveFile()
+
+	fmt.Printf("Success!\n")
+}
+
+func (c *CommandLine) handleAddContact(label, address string) {
+	wallets, _ := wallet.NewWallets()
+
+	err := wallets.AddContact(label, address)
+	utils.HandleError(err)
+	wallets.SaveFile()
+
+	fmt.Printf("Success!\n")
+}
+
+func (c *CommandLine) handleRemoveContact(label string) {
+	wallets, err := wallet.NewWallets()
+	utils.HandleError(err)
+
+	err = wallets.RemoveContact(label)
+	utils.HandleError(err)
+	wallets.SaveFile()
+
+	fmt.Printf("Success!\n")
+}
+
+func (c *CommandLine) handleListContacts() {
 	wallets, _ := wallet.NewWallets()
-	address := wallets.AddWallet()
+
+	for _, label := range wallets.ContactLabels() {
+		fmt.Printf("%s\t%s\n", label, wallets.Contacts[label])
+	}
+}
+
+func (c *CommandLine) handleCreateWallet(keyTypeName string, bech32 bool) {
+	keyType, err := wallet.ParseKeyType(keyTypeName)
+	utils.HandleError(err)
+
+	wallets, _ := wallet.NewWallets()
+	address := wallets.AddWallet(keyType)
 	wallets.SaveFile()
 
+	if bech32 {
+		address = c.parseAddress(address).Bech32()
+	}
+
 	fmt.Printf("New address is: %s\n", address)
 }
+
+func (c *CommandLine) handleVanityAddress(prefix string, workers int, caseInsensitive bool, keyTypeName string) {
+	keyType, err := wallet.ParseKeyType(keyTypeName)
+	utils.HandleError(err)
+
+	err = wallet.ValidateVanityPrefix(prefix, caseInsensitive)
+	utils.HandleError(err)
+
+	difficulty := wallet.VanityDifficulty(prefix, caseInsensitive)
+	fmt.Printf("Searching for a %s address starting with %s on %d workers, about %.0f keys to try\n", keyType, prefix, workers, difficulty)
+
+	w, err := wallet.FindVanityWallet(wallet.VanityOptions{
+		Prefix:          prefix,
+		KeyType:         keyType,
+		Workers:         workers,
+		CaseInsensitive: caseInsensitive,
+		Progress: func(p wallet.VanityProgress) {
+			fmt.Printf("  %d keys in %s (%.0f keys/s), ETA %s\n", p.Attempts, p.Elapsed.Round(time.Second), p.Rate, p.ETA.Round(time.Second))
+		},
+	})
+	utils.HandleError(err)
+
+	wallets, _ := wallet.NewWallets()
+	address := wallets.Add(w)
+	wallets.SaveFile()
+
+	fmt.Printf("New address is: %s\n", address)
+}
+
+func (c *CommandLine) handlePubKey(address string) {
+	c.parseAddress(address)
+
+	wallets, err := wallet.NewWallets()
+	utils.HandleError(err)
+	w, err := wallets.GetWallet(address)
+	utils.HandleError(err)
+
+	fmt.Printf("%x\n", w.PublicKey)
+}
+
+func (c *CommandLine) handleCreateMultiSig(m int, hexPubKeys []string) {
+	pubKeys := [][]byte{}
+	for _, hexPubKey := range hexPubKeys {
+		pubKey, err := hex.DecodeString(strings.TrimSpace(hexPubKey))
+		utils.HandleError(err)
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	redeem, err := blockchain.MultiSigScript(m, pubKeys)
+	utils.HandleError(err)
+
+	wallets, _ := wallet.NewWallets()
+	address := wallets.AddScript(redeem)
+	wallets.SaveFile()
+
+	fmt.Printf("New %d-of-%d multisig address is: %s\n", m, len(pubKeys), address)
+}
+
+func (c *CommandLine) handleCreateAggregate(hexPubKeys []string) {
+	pubKeys := [][]byte{}
+	for _, hexPubKey := range hexPubKeys {
+		pubKey, err := hex.DecodeString(strings.TrimSpace(hexPubKey))
+		utils.HandleError(err)
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	wallets, _ := wallet.NewWallets()
+	address, err := wallets.AddAggregate(pubKeys)
+	utils.HandleError(err)
+	wallets.SaveFile()
+
+	fmt.Printf("New %d-of-%d aggregated key address is: %s\n", len(pubKeys), len(pubKeys), address)
+}
+
+func (c *CommandLine) handleCreateRawTx(from, to string, amount int, strategy string, opts blockchain.TxOptions, out string) {
+	c.parseAddress(from)
+	c.parseAddress(to)
+
+	selector, err := blockchain.NewCoinSelector(strategy)
+	utils.HandleError(err)
+
+	wallets, _ := wallet.NewWallets()
+
+	chain := c.openChain()
+	defer chain.Close()
+
+	ptx, err := blockchain.NewPartialTransaction(from, to, amount, chain, wallets, selector, opts)
+	utils.HandleError(err)
+
+	c.writePartialTransaction(out, ptx)
+
+	fmt.Printf("Unsigned transaction written to %s\n", out)
+}
+
+func (c *CommandLine) handleDecodeRawTx(in string) {
+	ptx := c.readPartialTransaction(in)
+
+	fmt.Printf("%v", ptx)
+}
+
+func (c *CommandLine) handleSignRawTx(in, address string) {
+	c.parseAddress(address)
+
+	ptx := c.readPartialTransaction(in)
+
+	wallets, err := wallet.NewWallets()
+	utils.HandleError(err)
+	w, err := wallets.GetWallet(address)
+	utils.HandleError(err)
+
+	signed := ptx.Sign(w.PrivateKey)
+	if signed == 0 {
+		log.Panic("wallet cannot sign any input of this transaction")
+	}
+
+	c.writePartialTransaction(in, ptx)
+
+	fmt.Printf("Added %d signature(s) to %s\n", signed, in)
+}
+
+func (c *CommandLine) handleMuSigNonce(in, address string) {
+	c.parseAddress(address)
+
+	ptx := c.readPartialTransaction(in)
+
+	wallets, err := wallet.NewWallets()
+	utils.HandleError(err)
+	w, err := wallets.GetWallet(address)
+	utils.HandleError(err)
+
+	committed, err := ptx.CommitNonces(w.PrivateKey, wallets)
+	utils.HandleError(err)
+	if committed == 0 {
+		log.Panic("wallet has no nonce to add to this transaction")
+	}
+
+	// The secret nonces must be on disk before anyone can sign with the
+	// public ones.
+	wallets.SaveFile()
+	c.writePartialTransaction(in, ptx)
+
+	fmt.Printf("Added %d nonce(s) to %s\n", committed, in)
+}
+
+func (c *CommandLine) handleMuSigSign(in, address string) {
+	c.parseAddress(address)
+
+	ptx := c.readPartialTransaction(in)
+
+	wallets, err := wallet.NewWallets()
+	utils.HandleError(err)
+	w, err := wallets.GetWallet(address)
+	utils.HandleError(err)
+
+	signed, err := ptx.SignPartial(w.PrivateKey, wallets)
+	utils.HandleError(err)
+	if signed == 0 {
+		log.Panic("wallet has no partial signature to add to this transaction")
+	}
+
+	// Forget the used secret nonces before the signatures leave the host.
+	wallets.SaveFile()
+	c.writePartialTransaction(in, ptx)
+
+	fmt.Printf("Added %d partial signature(s) to %s\n", signed, in)
+}
+
+func (c *CommandLine) handleSendRawTx(in string) {
+	ptx := c.readPartialTransaction(in)
+
+	tx, err := ptx.Finalize()
+	utils.HandleError(err)
+
+	chain := c.openChain()
+	defer chain.Close()
+
+	c.submitTransaction(chain, tx)
+}
+
+func (c *CommandLine) handleExportChain(out string, from, to int) {
+	chain := c.openChain()
+	defer chain.Close()
+
+	file, err := os.Create(out)
+	utils.HandleError(err)
+	defer file.Close()
+
+	count, err := chain.ExportChain(file, from, to)
+	utils.HandleError(err)
+
+	fmt.Printf("Exported %d block(s) to %s\n", count, out)
+}
+
+func (c *CommandLine) handleImportChain(in string, pruneMB int) {
+	file, err := os.Open(in)
+	utils.HandleError(err)
+	defer file.Close()
+
+	store, err := blockchain.OpenDefaultStore()
+	utils.HandleError(err)
+	defer store.Close()
+
+	if pruneMB > 0 {
+		err = blockchain.SetPruneTarget(store, pruneMB)
+		utils.HandleError(err)
+	}
+
+	count, err := blockchain.ImportChain(store, file)
+	utils.HandleError(err)
+
+	fmt.Printf("Imported %d block(s)\n", count)
+
+	chain, err := blockchain.OpenBlockChain(store)
+	utils.HandleError(err)
+	if info, ok := chain.AssumedUTXOSet(); ok {
+		c.printUTXOSnapshot(info)
+	}
+}
+
+func (c *CommandLine) handleDisconnectBlock(count int) {
+	chain := c.openChain()
+	defer chain.Close()
+
+	printActivity := c.watchWallets(chain)
+	for range count {
+		_, err := chain.DisconnectBlock()
+		utils.HandleError(err)
+	}
+	printActivity()
+
+	fmt.Printf("New tip %x at height %d\n", chain.LastHash, chain.GetBestHeight())
+}
+
+func (c *CommandLine) handlePrune(targetMB int) {
+	chain := c.openChain()
+	defer chain.Close()
+
+	count, err := chain.SetPruneTarget(targetMB)
+	utils.HandleError(err)
+
+	if targetMB == 0 {
+		fmt.Printf("Pruning is off\n")
+		return
+	}
+
+	fmt.Printf("Pruned %d block(s)\n", count)
+	if pruned := chain.PrunedHeight(); pruned >= 0 {
+		fmt.Printf("Blocks up to height %d are pruned\n", pruned)
+	}
+}
+
+func (c *CommandLine) handleChainInfo() {
+	chain := c.openChain()
+	defer chain.Close()
+
+	fmt.Printf("Tip:      %x\n", chain.LastHash)
+	fmt.Printf("Height:   %d\n", chain.GetBestHeight())
+	if target := chain.PruneTarget(); target > 0 {
+		fmt.Printf("Pruning:  on, target %d MB\n", target)
+	} else {
+		fmt.Printf("Pruning:  off\n")
+	}
+	if pruned := chain.PrunedHeight(); pruned >= 0 {
+		fmt.Printf("Pruned:   up to height %d\n", pruned)
+	} else {
+		fmt.Printf("Pruned:   no\n")
+	}
+	fmt.Printf("Services: %s\n", chain.Services())
+	if info, ok := chain.AssumedUTXOSet(); ok {
+		c.printUTXOSnapshot(info)
+	}
+}
+
+func (c *CommandLine) handleDumpUTXOSet(out string) {
+	chain := c.openChain()
+	defer chain.Close()
+
+	file, err := os.Create(out)
+	utils.HandleError(err)
+	defer file.Close()
+
+	info, count, err := chain.DumpUTXOSet(file)
+	utils.HandleError(err)
+
+	fmt.Printf("Wrote %d unspent output(s) at height %d to %s\n", count, info.Height, out)
+	fmt.Printf("Tip:        %x\n", info.TipHash)
+	fmt.Printf("Commitment: %x\n", info.Commitment)
+}
+
+func (c *CommandLine) handleLoadUTXOSet(in string) {
+	if blockchain.ChainExists() {
+		fmt.Printf("Blockchain already exists")
+		runtime.Goexit()
+	}
+
+	file, err := os.Open(in)
+	utils.HandleError(err)
+	defer file.Close()
+
+	store, err := blockchain.OpenDefaultStore()
+	utils.HandleError(err)
+	defer store.Close()
+
+	info, count, err := blockchain.LoadUTXOSet(store, file)
+	utils.HandleError(err)
+
+	fmt.Printf("Loaded %d unspent output(s)\n", count)
+	c.printUTXOSnapshot(info)
+}
+
+func (c *CommandLine) printUTXOSnapshot(info *blockchain.UTXOSnapshotInfo) {
+	status := "not verified yet, import the chain up to this height to verify it"
+	if info.Verified {
+		status = "verified against the chain"
+	}
+
+	fmt.Printf("UTXO snapshot at height %d, tip %x\n", info.Height, info.TipHash)
+	fmt.Printf("Commitment %x, %s\n", info.Commitment, status)
+}
+
+// openChain opens the chain in the data directory, stopping when none has
+// been created yet.
+func (c *CommandLine) openChain() *blockchain.BlockChain {
+	if !blockchain.ChainExists() {
+		fmt.Printf("No existing blockchain found, create one!")
+		runtime.Goexit()
+	}
+
+	store, err := blockchain.OpenDefaultStore()
+	utils.HandleError(err)
+
+	chain, err := blockchain.OpenBlockChain(store)
+	utils.HandleError(err)
+
+	return chain
+}
+
+// parseAddress checks an address given on the command line, stopping with
+// the reason when it is not valid.
+func (c *CommandLine) parseAddress(address string) wallet.Address {
+	parsed, err := wallet.ParseAddress(address)
+	utils.HandleError(err)
+
+	return parsed
+}
+
+func (c *CommandLine) readPartialTransaction(path string) *blockchain.PartialTransaction {
+	content, err := os.ReadFile(path)
+	utils.HandleError(err)
+
+	data, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	utils.HandleError(err)
+
+	ptx, err := blockchain.DeserializePartialTransaction(data)
+	utils.HandleError(err)
+
+	return ptx
+}
+
+func (c *CommandLine) writePartialTransaction(path string, ptx *blockchain.PartialTransaction) {
+	data, err := ptx.Serialize()
+	utils.HandleError(err)
+
+	err = os.WriteFile(path, []byte(hex.EncodeToString(data)+"\n"), 0644)
+	utils.HandleError(err)
+}
+
+func (c *CommandLine) handleCreateHTLC(from, to string, amount int, timeout int64, hashHex, strategy string) {
+	c.parseAddress(from)
+	c.parseAddress(to)
+
+	if hashHex == "" {
+		preimage := make([]byte, 32)
+		_, err := rand.Read(preimage)
+		utils.HandleError(err)
+
+		hash := sha256.Sum256(preimage)
+		hashHex = hex.EncodeToString(hash[:])
+
+		fmt.Printf("Preimage: %x\n", preimage)
+		fmt.Printf("Hash:     %s\n", hashHex)
+	}
+
+	hash, err := hex.DecodeString(hashHex)
+	utils.HandleError(err)
+
+	selector, err := blockchain.NewCoinSelector(strategy)
+	utils.HandleError(err)
+
+	wallets, err := wallet.NewWallets()
+	utils.HandleError(err)
+
+	chain := c.openChain()
+	defer chain.Close()
+
+	tx, err := blockchain.NewHTLCTransaction(from, to, amount, hash, timeout, chain, wallets, selector)
+	utils.HandleError(err)
+
+	c.submitTransaction(chain, tx)
+	fmt.Printf("HTLC output: -txid %x -out 0\n", tx.ID)
+}
+
+func (c *CommandLine) handleClaimHTLC(txID string, out int, preimageHex, address string) {
+	c.parseAddress(address)
+
+	htlcTxID, err := hex.DecodeString(txID)
+	utils.HandleError(err)
+	preimage, err := hex.DecodeString(preimageHex)
+	utils.HandleError(err)
+
+	wallets, err := wallet.NewWallets()
+	utils.HandleError(err)
+
+	chain := c.openChain()
+	defer chain.Close()
+
+	tx, err := blockchain.NewHTLCClaimTransaction(htlcTxID, out, preimage, address, chain, wallets)
+	utils.HandleError(err)
+
+	c.submitTransaction(chain, tx)
+}
+
+func (c *CommandLine) handleRefundHTLC(txID string, out int, address string) {
+	c.parseAddress(address)
+
+	htlcTxID, err := hex.DecodeString(txID)
+	utils.HandleError(err)
+
+	wallets, err := wallet.NewWallets()
+	utils.HandleError(err)
+
+	chain := c.openChain()
+	defer chain.Close()
+
+	tx, err := blockchain.NewHTLCRefundTransaction(htlcTxID, out, address, chain, wallets)
+	utils.HandleError(err)
+
+	c.submitTransaction(chain, tx)
+}
+
+func (c *CommandLine) handleHTLCPreimage(txID string, out int) {
+	htlcTxID, err := hex.DecodeString(txID)
+	utils.HandleError(err)
+
+	chain := c.openChain()
+	defer chain.Close()
+
+	preimage, err := chain.FindHTLCPreimage(htlcTxID, out)
+	utils.HandleError(err)
+
+	fmt.Printf("%x\n", preimage)
+}
+
+func (c *CommandLine) handleNotarize(from, dataHex, file, strategy string) {
+	c.parseAddress(from)
+
+	var data []byte
+	if file != "" {
+		content, err := os.ReadFile(file)
+		utils.HandleError(err)
+
+		digest := sha256.Sum256(content)
+		data = digest[:]
+	} else {
+		decoded, err := hex.DecodeString(dataHex)
+		utils.HandleError(err)
+		data = decoded
+	}
+
+	selector, err := blockchain.NewCoinSelector(strategy)
+	utils.HandleError(err)
+
+	wallets, err := wallet.NewWallets()
+	utils.HandleError(err)
+
+	chain := c.openChain()
+	defer chain.Close()
+
+	tx, err := blockchain.NewDataTransaction(from, data, chain, wallets, selector)
+	utils.HandleError(err)
+
+	c.submitTransaction(chain, tx)
+	fmt.Printf("Notarized %x in transaction %x\n", data, tx.ID)
+}
+
+func (c *CommandLine) handleFindNotarization(dataHex string) {
+	data, err := hex.DecodeString(dataHex)
+	utils.HandleError(err)
+
+	chain := c.openChain()
+	defer chain.Close()
+
+	n, err := chain.FindNotarization(data)
+	utils.HandleError(err)
+
+	fmt.Printf("Data:        %x\n", n.Data)
+	fmt.Printf("Transaction: %x\n", n.TxID)
+	fmt.Printf("Block:       %x\n", n.BlockHash)
+	fmt.Printf("Height:      %d\n", n.Height)
+	fmt.Printf("Time:        %s\n", time.Unix(n.Timestamp, 0).UTC().Format(time.RFC3339))
+}
+
+func (c *CommandLine) handleExplorer(listen string, submit bool) {
+	chain := c.openChain()
+	defer chain.Close()
+
+	// Stop on Ctrl-C, so the chain is closed cleanly.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	handler := explorer.NewServer(chain, explorer.Options{Submit: submit})
+	go webhook.NewNotifier(chain, handler.ChainLock()).Run(ctx)
+
+	server := &http.Server{
+		Addr:    listen,
+		Handler: handler,
+	}
+	go func() {
+		<-ctx.Done()
+		// Shutdown does not wait for WebSocket connections; closing the
+		// handler ends them.
+		handler.Close()
+		server.Shutdown(context.Background())
+	}()
+
+	fmt.Printf("Explorer listening on http://%s/ui/\n", listen)
+
+	err := server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		utils.HandleError(err)
+	}
+}
+
+func (c *CommandLine) handleAddWebhook(address, url string, confirmations int) {
+	chain := c.openChain()
+	defer chain.Close()
+
+	hook, err := webhook.Add(chain, address, url, confirmations)
+	utils.HandleError(err)
+
+	fmt.Printf("Webhook: %s\n", hook.ID)
+	fmt.Printf("Secret:  %x\n", hook.Secret)
+	fmt.Printf("Payloads are signed in the %s header with HMAC-SHA256 of the body keyed with the secret\n", webhook.SignatureHeader)
+}
+
+func (c *CommandLine) handleRemoveWebhook(id string) {
+	chain := c.openChain()
+	defer chain.Close()
+
+	err := webhook.Remove(chain, id)
+	utils.HandleError(err)
+
+	fmt.Printf("Removed webhook %s\n", id)
+}
+
+func (c *CommandLine) handleWebhooks() {
+	chain := c.openChain()
+	defer chain.Close()
+
+	hooks, err := webhook.List(chain)
+	utils.HandleError(err)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tADDRESS\tCONFIRMATIONS\tURL\n")
+	for _, hook := range hooks {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", hook.ID, hook.Address, hook.Confirmations, hook.URL)
+	}
+	w.Flush()
+
+	deliveries, err := webhook.Deliveries(chain)
+	utils.HandleError(err)
+	if len(deliveries) == 0 {
+		return
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "DELIVERY\tWEBHOOK\tEVENT\tTRANSACTION\tSTATUS\tATTEMPTS\tLAST ERROR\n")
+	for _, d := range deliveries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n", d.ID, d.Webhook, d.Event, d.TxID, d.Status, d.Attempts, d.LastError)
+	}
+	w.Flush()
+}
+
+func (c *CommandLine) handleNotify(timeout time.Duration) {
+	chain := c.openChain()
+	defer chain.Close()
+
+	// Stop on Ctrl-C or after timeout, leaving the remaining deliveries
+	// pending.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := webhook.NewNotifier(chain, &sync.Mutex{}).DeliverPending(ctx)
+	if err != nil && err != context.Canceled && err != context.DeadlineExceeded {
+		utils.HandleError(err)
+	}
+
+	counts := map[string]int{}
+	deliveries, err := webhook.Deliveries(chain)
+	utils.HandleError(err)
+	for _, d := range deliveries {
+		counts[d.Status]++
+	}
+
+	fmt.Printf("Delivered %d, failed %d, pending %d\n", counts[webhook.StatusDelivered], counts[webhook.StatusFailed], counts[webhook.StatusPending])
+}
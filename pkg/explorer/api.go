@@ -0,0 +1,451 @@
+package explorer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/blockchain"
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+type blockJSON struct {
+	Hash          string   `json:"hash"`
+	PrevHash      string   `json:"prevHash"`
+	Height        int      `json:"height"`
+	Timestamp     int64    `json:"timestamp"`
+	Nonce         int      `json:"nonce"`
+	TxHash        string   `json:"txHash"`
+	Confirmations int      `json:"confirmations"`
+	Pruned        bool     `json:"pruned,omitempty"`
+	TxCount       int      `json:"txCount,omitempty"`
+	Transactions  []txJSON `json:"transactions,omitempty"`
+}
+
+type txJSON struct {
+	ID            string       `json:"id"`
+	Coinbase      bool         `json:"coinbase,omitempty"`
+	LockTime      int64        `json:"lockTime,omitempty"`
+	BlockHash     string       `json:"blockHash,omitempty"`
+	Height        int          `json:"height"`
+	Confirmations int          `json:"confirmations"`
+	Inputs        []inputJSON  `json:"inputs"`
+	Outputs       []outputJSON `json:"outputs"`
+}
+
+type inputJSON struct {
+	TxID     string `json:"txid"`
+	Out      int    `json:"out"`
+	Script   string `json:"script"`
+	Sequence int    `json:"sequence,omitempty"`
+}
+
+type outputJSON struct {
+	Value   int    `json:"value"`
+	Script  string `json:"script"`
+	Address string `json:"address,omitempty"`
+	Data    string `json:"data,omitempty"`
+	Spent   *bool  `json:"spent,omitempty"`
+}
+
+type utxoJSON struct {
+	TxID   string `json:"txid"`
+	Out    int    `json:"out"`
+	Value  int    `json:"value"`
+	Height int    `json:"height"`
+}
+
+type activityJSON struct {
+	TxID      string `json:"txid"`
+	BlockHash string `json:"blockHash"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Received  int    `json:"received"`
+	Sent      int    `json:"sent"`
+}
+
+type blocksResponse struct {
+	Blocks []blockJSON `json:"blocks"`
+	// Next is the from parameter of the next page, if there is one.
+	Next *int `json:"next,omitempty"`
+}
+
+type addressResponse struct {
+	Address string         `json:"address"`
+	Balance int            `json:"balance"`
+	UTXOs   []utxoJSON     `json:"utxos"`
+	TxCount int            `json:"txCount"`
+	Offset  int            `json:"offset"`
+	History []activityJSON `json:"history"`
+	// HistoryFrom is the first height searched for the history, when
+	// older blocks are pruned.
+	HistoryFrom int `json:"historyFrom,omitempty"`
+}
+
+type mempoolResponse struct {
+	Total        int      `json:"total"`
+	Offset       int      `json:"offset"`
+	Transactions []txJSON `json:"transactions"`
+}
+
+type snapshotJSON struct {
+	Height     int    `json:"height"`
+	Tip        string `json:"tip"`
+	Commitment string `json:"commitment"`
+	Verified   bool   `json:"verified"`
+}
+
+type statsResponse struct {
+	Height       int           `json:"height"`
+	Tip          string        `json:"tip"`
+	TipTime      int64         `json:"tipTime"`
+	Difficulty   int           `json:"difficulty"`
+	UTXOs        int           `json:"utxos"`
+	Supply       int           `json:"supply"`
+	Mempool      int           `json:"mempool"`
+	PrunedHeight *int          `json:"prunedHeight,omitempty"`
+	Services     string        `json:"services"`
+	Snapshot     *snapshotJSON `json:"snapshot,omitempty"`
+}
+
+func (s *Server) handleBlocks(w http.ResponseWriter, r *http.Request) {
+	from, err := queryInt(r, "from", -1)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	limit, err := pageLimit(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp, err := s.blocks(from, limit)
+	writeResult(w, resp, err)
+}
+
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
+	resp, err := s.block(r.PathValue("id"))
+	writeResult(w, resp, err)
+}
+
+func (s *Server) handleTx(w http.ResponseWriter, r *http.Request) {
+	resp, err := s.tx(r.PathValue("id"))
+	writeResult(w, resp, err)
+}
+
+func (s *Server) handleAddress(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := page(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp, err := s.address(r.PathValue("address"), offset, limit)
+	writeResult(w, resp, err)
+}
+
+func (s *Server) handleMempool(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := page(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := s.mempool(offset, limit)
+	writeJSON(w, resp)
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	resp, err := s.stats()
+	writeResult(w, resp, err)
+}
+
+// blocks lists up to limit block summaries newest first, starting at
+// height from, or at the tip when from is negative.
+func (s *Server) blocks(from, limit int) (blocksResponse, error) {
+	best := s.chain.GetBestHeight()
+	if from < 0 {
+		from = best
+	}
+
+	// A node loaded from a UTXO snapshot has no headers below it.
+	lowest := 0
+	if info, ok := s.chain.AssumedUTXOSet(); ok {
+		lowest = info.Height
+	}
+
+	from = max(min(from, best), lowest)
+	to := max(from-limit+1, lowest)
+
+	blocks := []blockJSON{}
+	it := s.chain.HeaderIterator(to, from)
+	for header := range it.Headers() {
+		block, err := s.blockJSON(header, best, false)
+		if err != nil {
+			return blocksResponse{}, err
+		}
+		blocks = append(blocks, block)
+	}
+	if err := it.Err(); err != nil {
+		return blocksResponse{}, err
+	}
+	slices.Reverse(blocks)
+
+	resp := blocksResponse{Blocks: blocks}
+	if to > lowest {
+		next := to - 1
+		resp.Next = &next
+	}
+
+	return resp, nil
+}
+
+// block returns a block with its transactions, looked up by hash or height.
+// A pruned block is returned with its header only.
+func (s *Server) block(id string) (blockJSON, error) {
+	header, err := s.findHeader(id)
+	if err != nil {
+		return blockJSON{}, err
+	}
+
+	return s.blockJSON(header, s.chain.GetBestHeight(), true)
+}
+
+// tx returns a mined or mempool transaction.
+func (s *Server) tx(id string) (txJSON, error) {
+	txID, err := hex.DecodeString(id)
+	if err != nil || len(txID) == 0 {
+		return txJSON{}, badRequest("transaction ID must be hex")
+	}
+
+	for _, tx := range s.chain.MempoolTransactions() {
+		if bytes.Equal(tx.ID, txID) {
+			return s.txJSON(tx, nil, 0), nil
+		}
+	}
+
+	tx, block, err := s.chain.FindTransactionBlock(txID)
+	if errors.Is(err, blockchain.ErrPruned) {
+		err = notFound(err)
+	}
+	if err != nil {
+		return txJSON{}, err
+	}
+
+	return s.txJSON(&tx, block, s.chain.GetBestHeight()), nil
+}
+
+// address returns the balance, unspent outputs and a page of the history
+// of an address, newest first.
+func (s *Server) address(addr string, offset, limit int) (addressResponse, error) {
+	address, err := wallet.ParseAddress(addr)
+	if err != nil {
+		return addressResponse{}, badRequest("%v", err)
+	}
+
+	lock := blockchain.LockingScript(address)
+	resp := addressResponse{
+		Address: address.String(),
+		UTXOs:   []utxoJSON{},
+		Offset:  offset,
+		History: []activityJSON{},
+	}
+
+	for _, utxo := range s.chain.FindUnspentOutputs(lock) {
+		resp.Balance += utxo.Output.Value
+		resp.UTXOs = append(resp.UTXOs, utxoJSON{
+			TxID:   hex.EncodeToString(utxo.TxID),
+			Out:    utxo.Index,
+			Value:  utxo.Output.Value,
+			Height: utxo.Height,
+		})
+	}
+
+	history, from, err := s.chain.AddressHistory(lock)
+	if err != nil {
+		return addressResponse{}, err
+	}
+	slices.Reverse(history)
+
+	resp.TxCount = len(history)
+	resp.HistoryFrom = from
+	start, end := pageBounds(len(history), offset, limit)
+	for _, activity := range history[start:end] {
+		resp.History = append(resp.History, activityJSON{
+			TxID:      hex.EncodeToString(activity.TxID),
+			BlockHash: hex.EncodeToString(activity.BlockHash),
+			Height:    activity.Height,
+			Timestamp: activity.Timestamp,
+			Received:  activity.Received,
+			Sent:      activity.Sent,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *Server) mempool(offset, limit int) mempoolResponse {
+	txs := s.chain.MempoolTransactions()
+	resp := mempoolResponse{
+		Total:        len(txs),
+		Offset:       offset,
+		Transactions: []txJSON{},
+	}
+
+	start, end := pageBounds(len(txs), offset, limit)
+	for _, tx := range txs[start:end] {
+		resp.Transactions = append(resp.Transactions, s.txJSON(tx, nil, 0))
+	}
+
+	return resp
+}
+
+func (s *Server) stats() (statsResponse, error) {
+	header, err := s.chain.GetHeader(s.chain.LastHash)
+	if err != nil {
+		return statsResponse{}, err
+	}
+
+	utxos, supply, err := s.chain.UTXOSetStats()
+	if err != nil {
+		return statsResponse{}, err
+	}
+
+	resp := statsResponse{
+		Height:     header.Height,
+		Tip:        hex.EncodeToString(header.Hash),
+		TipTime:    header.Timestamp,
+		Difficulty: blockchain.Difficulty,
+		UTXOs:      utxos,
+		Supply:     supply,
+		Mempool:    len(s.chain.MempoolTransactions()),
+		Services:   s.chain.Services().String(),
+	}
+
+	if pruned := s.chain.PrunedHeight(); pruned >= 0 {
+		resp.PrunedHeight = &pruned
+	}
+
+	if info, ok := s.chain.AssumedUTXOSet(); ok {
+		resp.Snapshot = &snapshotJSON{
+			Height:     info.Height,
+			Tip:        hex.EncodeToString(info.TipHash),
+			Commitment: hex.EncodeToString(info.Commitment),
+			Verified:   info.Verified,
+		}
+	}
+
+	return resp, nil
+}
+
+// findHeader looks a block up by height or by hash.
+func (s *Server) findHeader(id string) (*blockchain.BlockHeader, error) {
+	if height, err := strconv.Atoi(id); err == nil && len(id) < 2*32 {
+		header, err := s.chain.GetHeaderByHeight(height)
+		if errors.Is(err, blockchain.ErrNotFound) {
+			return nil, notFound(fmt.Errorf("no block at height %d", height))
+		}
+
+		return header, err
+	}
+
+	hash, err := hex.DecodeString(id)
+	if err != nil || len(hash) == 0 {
+		return nil, badRequest("%s is neither a block hash nor a height", id)
+	}
+
+	header, err := s.chain.GetHeader(hash)
+	if errors.Is(err, blockchain.ErrNotFound) || (err == nil && !s.chain.OnChain(header)) {
+		return nil, notFound(fmt.Errorf("block %s not found", id))
+	}
+
+	return header, err
+}
+
+// blockJSON describes the block of header, with its transactions when
+// withTxs is set. Pruned blocks only have their header.
+func (s *Server) blockJSON(header *blockchain.BlockHeader, best int, withTxs bool) (blockJSON, error) {
+	resp := blockJSON{
+		Hash:          hex.EncodeToString(header.Hash),
+		PrevHash:      hex.EncodeToString(header.PrevHash),
+		Height:        header.Height,
+		Timestamp:     header.Timestamp,
+		Nonce:         header.Nonce,
+		TxHash:        hex.EncodeToString(header.TxHash),
+		Confirmations: best - header.Height + 1,
+	}
+
+	block, err := s.chain.GetBlock(header.Hash)
+	if errors.Is(err, blockchain.ErrPruned) {
+		resp.Pruned = true
+		return resp, nil
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	resp.TxCount = len(block.Transactions)
+	if withTxs {
+		for _, tx := range block.Transactions {
+			resp.Transactions = append(resp.Transactions, s.txJSON(tx, block, best))
+		}
+	}
+
+	return resp, nil
+}
+
+// txJSON describes tx, mined in block, or waiting in the mempool when block
+// is nil.
+func (s *Server) txJSON(tx *blockchain.Transaction, block *blockchain.Block, best int) txJSON {
+	resp := txJSON{
+		ID:       hex.EncodeToString(tx.ID),
+		Coinbase: tx.IsCoinbase(),
+		LockTime: tx.LockTime,
+		Height:   -1,
+		Inputs:   []inputJSON{},
+		Outputs:  []outputJSON{},
+	}
+
+	if block != nil {
+		resp.BlockHash = hex.EncodeToString(block.Hash)
+		resp.Height = block.Height
+		resp.Confirmations = best - block.Height + 1
+	}
+
+	for _, txIn := range tx.Inputs {
+		resp.Inputs = append(resp.Inputs, inputJSON{
+			TxID:     hex.EncodeToString(txIn.ID),
+			Out:      txIn.Out,
+			Script:   txIn.Script.String(),
+			Sequence: txIn.Sequence,
+		})
+	}
+
+	for idx, txOut := range tx.Outputs {
+		out := outputJSON{
+			Value:  txOut.Value,
+			Script: txOut.Script.String(),
+		}
+
+		if address, ok := blockchain.ScriptAddress(txOut.Script); ok {
+			out.Address = address.String()
+		}
+
+		if data, ok := txOut.Script.Data(); ok {
+			out.Data = hex.EncodeToString(data)
+		} else if block != nil {
+			_, err := s.chain.FindUnspentOutput(tx.ID, idx)
+			spent := err != nil
+			out.Spent = &spent
+		}
+
+		resp.Outputs = append(resp.Outputs, out)
+	}
+
+	return resp
+}
@@ -0,0 +1,102 @@
+package explorer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/blockchain"
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+func TestAPIErrors(t *testing.T) {
+	n := newTestNode(t, Options{})
+	n.chain.MineBlock(n.miner)
+
+	disconnected, err := n.chain.DisconnectBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknown := strings.Repeat("ab", 32)
+	address := string(wallet.NewWallet(wallet.KeyP256).Address())
+	badChecksum := address[:len(address)-1] + "2"
+	if strings.HasSuffix(address, "2") {
+		badChecksum = address[:len(address)-1] + "3"
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/block/nothex", http.StatusBadRequest},
+		{"/block/" + unknown, http.StatusNotFound},
+		{"/block/1", http.StatusNotFound},
+		{"/block/" + hex.EncodeToString(disconnected.Hash), http.StatusNotFound},
+		{"/tx/nothex", http.StatusBadRequest},
+		{"/tx/" + unknown, http.StatusNotFound},
+		{"/address/nonsense", http.StatusBadRequest},
+		{"/address/" + badChecksum, http.StatusBadRequest},
+		{"/blocks?from=-1", http.StatusBadRequest},
+		{"/mempool?limit=x", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if status := n.get(t, test.path, &resp); status != test.status || resp.Error == "" {
+			t.Errorf("GET %s = %d %+v, want %d with an error", test.path, status, resp, test.status)
+		}
+	}
+
+	// An address that was never paid is not an error.
+	var resp addressResponse
+	if status := n.get(t, "/address/"+address, &resp); status != http.StatusOK || resp.Balance != 0 || resp.TxCount != 0 {
+		t.Fatalf("GET /address of an unused address = %d %+v", status, resp)
+	}
+}
+
+func TestAPIPrunedBlock(t *testing.T) {
+	source := newTestNode(t, Options{})
+	source.chain.MineBlock(source.miner)
+	tip, err := source.chain.GetBlock(source.chain.LastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A node loaded from a UTXO snapshot has pruned every block up to it.
+	var snapshot bytes.Buffer
+	if _, _, err := source.chain.DumpUTXOSet(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	store := blockchain.NewMemoryStore()
+	if _, _, err := blockchain.LoadUTXOSet(store, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := blockchain.OpenBlockChain(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := serve(t, chain, Options{})
+
+	var block blockJSON
+	if status := n.get(t, "/block/"+hex.EncodeToString(tip.Hash), &block); status != http.StatusOK || !block.Pruned || block.Height != 1 || len(block.Transactions) != 0 {
+		t.Fatalf("GET /block of a pruned block = %d %+v, want its header only", status, block)
+	}
+
+	var failed map[string]string
+	if status := n.get(t, "/tx/"+hex.EncodeToString(tip.Transactions[0].ID), &failed); status != http.StatusNotFound {
+		t.Fatalf("GET /tx in a pruned block = %d %v, want 404", status, failed)
+	}
+	if status := n.get(t, "/block/0", &failed); status != http.StatusGone {
+		t.Fatalf("GET /block below the snapshot = %d %v, want 410", status, failed)
+	}
+
+	var stats statsResponse
+	if status := n.get(t, "/stats", &stats); status != http.StatusOK || stats.PrunedHeight == nil || *stats.PrunedHeight != 1 || stats.Snapshot == nil {
+		t.Fatalf("GET /stats = %d %+v, want the pruned height and the snapshot", status, stats)
+	}
+}
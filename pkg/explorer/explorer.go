@@ -0,0 +1,231 @@
+package explorer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/blockchain"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Server serves a JSON API over a chain, HTML pages built on it and a
+// WebSocket stream of chain events. Reads are served concurrently; with
+// Options.Submit, transactions, mining and disconnect requests take the
+// chain for themselves.
+type Server struct {
+	chain   *blockchain.BlockChain
+	mu      sync.RWMutex
+	mux     *http.ServeMux
+	hub     *hub
+	watcher *watcher
+}
+
+// Options configure a Server.
+type Options struct {
+	// Submit enables POST /tx, POST /mine and POST /disconnect. The chain
+	// store is locked by the serving process, so these are the only way it
+	// changes while the explorer runs.
+	Submit bool
+}
+
+// NewServer serves chain until Close is called.
+func NewServer(chain *blockchain.BlockChain, opts Options) *Server {
+	s := &Server{
+		chain: chain,
+		mux:   http.NewServeMux(),
+		hub:   newHub(),
+	}
+
+	s.handleRead("GET /blocks", s.handleBlocks)
+	s.handleRead("GET /block/{id}", s.handleBlock)
+	s.handleRead("GET /tx/{id}", s.handleTx)
+	s.handleRead("GET /address/{address}", s.handleAddress)
+	s.handleRead("GET /mempool", s.handleMempool)
+	s.handleRead("GET /stats", s.handleStats)
+	s.registerUI()
+	s.registerWebSocket()
+	if opts.Submit {
+		s.registerSubmit()
+	}
+
+	s.watcher = newWatcher(s)
+	go s.watcher.run()
+
+	return s
+}
+
+// Close stops watching the chain and disconnects the WebSocket
+// subscribers. It does not close the chain.
+func (s *Server) Close() {
+	s.watcher.stop()
+	s.hub.close()
+}
+
+// ChainLock returns the lock the server holds while it changes the chain,
+// for code writing to the store next to the server.
+func (s *Server) ChainLock() sync.Locker {
+	return &s.mu
+}
+
+// handleRead registers a handler that only reads the chain.
+func (s *Server) handleRead(pattern string, handler http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		handler(w, r)
+	})
+}
+
+// handleWrite registers a handler that changes the chain.
+func (s *Server) handleWrite(pattern string, handler http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		handler(w, r)
+	})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Chain queries panic on store errors; answer those with a 500 instead
+	// of dropping the connection.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err := fmt.Errorf("internal error: %v", recovered)
+			if strings.HasPrefix(r.URL.Path, "/ui/") {
+				renderPage(w, "", nil, err)
+			} else {
+				writeError(w, err)
+			}
+		}
+	}()
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// httpError is an error with the status code it is reported with.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func (e *httpError) Unwrap() error {
+	return e.err
+}
+
+func badRequest(format string, args ...any) error {
+	return &httpError{status: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+func notFound(err error) error {
+	return &httpError{status: http.StatusNotFound, err: err}
+}
+
+func statusOf(err error) int {
+	var httpErr *httpError
+	switch {
+	case errors.As(err, &httpErr):
+		return httpErr.status
+	case errors.Is(err, blockchain.ErrNotFound), errors.Is(err, blockchain.ErrTxNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, blockchain.ErrPruned):
+		return http.StatusGone
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		log.Printf("explorer: writing response: %v", err)
+	}
+}
+
+// writeResult writes value, or err when it is not nil.
+func writeResult(w http.ResponseWriter, value any, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, value)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusOf(err))
+
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{Error: err.Error()})
+}
+
+// queryInt returns the non-negative integer query parameter name, or def
+// when it is missing.
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return 0, badRequest("%s must be a non-negative integer", name)
+	}
+
+	return number, nil
+}
+
+// pageLimit returns the limit query parameter, capped at maxPageSize.
+func pageLimit(r *http.Request) (int, error) {
+	limit, err := queryInt(r, "limit", defaultPageSize)
+	if err != nil {
+		return 0, err
+	}
+	if limit == 0 || limit > maxPageSize {
+		return 0, badRequest("limit must be between 1 and %d", maxPageSize)
+	}
+
+	return limit, nil
+}
+
+// page returns the offset and limit query parameters.
+func page(r *http.Request) (int, int, error) {
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	limit, err := pageLimit(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return offset, limit, nil
+}
+
+// pageBounds returns the slice bounds of a page of a list of total items.
+func pageBounds(total, offset, limit int) (int, int) {
+	start := min(offset, total)
+	end := min(start+limit, total)
+
+	return start, end
+}
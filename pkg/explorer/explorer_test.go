@@ -0,0 +1,320 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/blockchain"
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+	"golang.org/x/net/websocket"
+)
+
+type testNode struct {
+	chain   *blockchain.BlockChain
+	wallets *wallet.Wallets
+	miner   string
+	server  *httptest.Server
+}
+
+func newTestWallets() *wallet.Wallets {
+	return &wallet.Wallets{
+		Wallets:     map[string]*wallet.Wallet{},
+		Scripts:     map[string][]byte{},
+		Aggregates:  map[string][][]byte{},
+		Labels:      map[string]string{},
+		Contacts:    map[string]string{},
+		MuSigNonces: map[string][]byte{},
+	}
+}
+
+// newTestNode serves a new in-memory chain whose genesis pays miner.
+func newTestNode(t *testing.T, opts Options) *testNode {
+	t.Helper()
+
+	wallets := newTestWallets()
+	miner := wallets.AddWallet(wallet.KeyP256)
+
+	chain, err := blockchain.NewBlockChain(blockchain.NewMemoryStore(), miner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := serve(t, chain, opts)
+	n.wallets = wallets
+	n.miner = miner
+
+	return n
+}
+
+// serve serves chain, and closes it with the server.
+func serve(t *testing.T, chain *blockchain.BlockChain, opts Options) *testNode {
+	t.Helper()
+
+	handler := NewServer(chain, opts)
+	server := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		server.Close()
+		handler.Close()
+		chain.Close()
+	})
+
+	return &testNode{chain: chain, server: server}
+}
+
+// get decodes the JSON response to a GET of path into resp and returns
+// the status code.
+func (n *testNode) get(t *testing.T, path string, resp any) int {
+	t.Helper()
+
+	r, err := http.Get(n.server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(resp); err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+
+	return r.StatusCode
+}
+
+// post is like get for a POST of body, and leaves the response alone when
+// resp is nil.
+func (n *testNode) post(t *testing.T, path, body string, resp any) int {
+	t.Helper()
+
+	r, err := http.Post(n.server.URL+path, "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	if resp == nil {
+		return r.StatusCode
+	}
+	if err := json.NewDecoder(r.Body).Decode(resp); err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+
+	return r.StatusCode
+}
+
+// rawTx returns a signed payment from the miner, hex encoded like the files
+// of createrawtx and signrawtx.
+func (n *testNode) rawTx(t *testing.T, to string, amount int, opts blockchain.TxOptions) (string, string) {
+	t.Helper()
+
+	p, err := blockchain.NewPartialTransaction(n.miner, to, amount, n.chain, n.wallets, blockchain.LargestFirst{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Sign(n.wallets.Wallets[n.miner].PrivateKey)
+
+	data, err := p.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := p.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hex.EncodeToString(data), hex.EncodeToString(tx.ID)
+}
+
+func TestRESTEndpoints(t *testing.T) {
+	n := newTestNode(t, Options{})
+	payee := string(wallet.NewWallet(wallet.KeyP256).Address())
+
+	tx := blockchain.NewTransaction(n.miner, payee, 30, n.chain, n.wallets, blockchain.LargestFirst{}, blockchain.TxOptions{})
+	if mined, err := n.chain.SubmitTransaction(tx); err != nil || !mined {
+		t.Fatalf("SubmitTransaction = %v, %v", mined, err)
+	}
+	txID := hex.EncodeToString(tx.ID)
+	tipHash := hex.EncodeToString(n.chain.LastHash)
+
+	var stats statsResponse
+	if status := n.get(t, "/stats", &stats); status != http.StatusOK || stats.Height != 1 || stats.Tip != tipHash {
+		t.Fatalf("GET /stats = %d %+v", status, stats)
+	}
+
+	var blocks blocksResponse
+	if status := n.get(t, "/blocks", &blocks); status != http.StatusOK || len(blocks.Blocks) != 2 || blocks.Blocks[0].Hash != tipHash {
+		t.Fatalf("GET /blocks = %d %+v", status, blocks)
+	}
+
+	for _, id := range []string{"1", tipHash} {
+		var block blockJSON
+		status := n.get(t, "/block/"+id, &block)
+		if status != http.StatusOK || block.Hash != tipHash || len(block.Transactions) != 1 || block.Transactions[0].ID != txID {
+			t.Fatalf("GET /block/%s = %d %+v", id, status, block)
+		}
+	}
+
+	var txResp txJSON
+	if status := n.get(t, "/tx/"+txID, &txResp); status != http.StatusOK || txResp.BlockHash != tipHash || txResp.Confirmations != 1 {
+		t.Fatalf("GET /tx = %d %+v", status, txResp)
+	}
+
+	var address addressResponse
+	if status := n.get(t, "/address/"+payee, &address); status != http.StatusOK || address.Balance != 30 || address.TxCount != 1 {
+		t.Fatalf("GET /address = %d %+v", status, address)
+	}
+
+	var mempool mempoolResponse
+	if status := n.get(t, "/mempool", &mempool); status != http.StatusOK || mempool.Total != 0 {
+		t.Fatalf("GET /mempool = %d %+v", status, mempool)
+	}
+
+	// Without Options.Submit the chain cannot be changed over HTTP.
+	if status := n.post(t, "/mine?address="+payee, "", nil); status == http.StatusOK {
+		t.Fatal("POST /mine succeeded without Submit")
+	}
+}
+
+func TestSubmitEndpoints(t *testing.T) {
+	n := newTestNode(t, Options{Submit: true})
+	payee := string(wallet.NewWallet(wallet.KeyP256).Address())
+
+	var submitted submitResponse
+	raw, txID := n.rawTx(t, payee, 30, blockchain.TxOptions{})
+	if status := n.post(t, "/tx", raw, &submitted); status != http.StatusOK || !submitted.Mined || submitted.TxID != txID {
+		t.Fatalf("POST /tx = %d %+v", status, submitted)
+	}
+
+	var mined blockJSON
+	if status := n.post(t, "/mine?address="+payee, "", &mined); status != http.StatusOK || mined.Height != 2 {
+		t.Fatalf("POST /mine = %d %+v", status, mined)
+	}
+
+	var disconnected disconnectResponse
+	if status := n.post(t, "/disconnect", "", &disconnected); status != http.StatusOK || disconnected.Disconnected != mined.Hash || disconnected.Height != 2 {
+		t.Fatalf("POST /disconnect = %d %+v", status, disconnected)
+	}
+
+	var failed map[string]string
+	if status := n.post(t, "/tx", "not hex", &failed); status != http.StatusBadRequest {
+		t.Fatalf("POST /tx of garbage = %d, want 400", status)
+	}
+	if status := n.post(t, "/mine?address=nonsense", "", &failed); status != http.StatusBadRequest {
+		t.Fatalf("POST /mine to a bad address = %d, want 400", status)
+	}
+}
+
+// wsClient is a /ws connection.
+type wsClient struct {
+	t  *testing.T
+	ws *websocket.Conn
+}
+
+func dialWS(t *testing.T, n *testNode, topics ...string) *wsClient {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(n.server.URL, "http") + "/ws"
+	ws, err := websocket.Dial(url, "", n.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+
+	c := &wsClient{t: t, ws: ws}
+	for _, topic := range topics {
+		if err := websocket.JSON.Send(ws, subscribeRequest{Action: "subscribe", Topic: topic}); err != nil {
+			t.Fatal(err)
+		}
+		if event := c.next(); event["type"] != "subscribed" {
+			t.Fatalf("subscribing to %s: got %v", topic, event)
+		}
+	}
+
+	return c
+}
+
+// next returns the next event.
+func (c *wsClient) next() map[string]any {
+	c.t.Helper()
+
+	c.ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var event map[string]any
+	if err := websocket.JSON.Receive(c.ws, &event); err != nil {
+		c.t.Fatalf("waiting for an event: %v", err)
+	}
+
+	return event
+}
+
+func TestWebSocketStream(t *testing.T) {
+	n := newTestNode(t, Options{Submit: true})
+	payee := string(wallet.NewWallet(wallet.KeyP256).Address())
+
+	blocks := dialWS(t, n, topicNewBlock)
+	txs := dialWS(t, n, topicNewTx)
+	activity := dialWS(t, n, topicAddress+payee)
+
+	// A payment that waits in the mempool until height 2.
+	raw, txID := n.rawTx(t, payee, 30, blockchain.TxOptions{LockTime: 2})
+	var submitted submitResponse
+	if status := n.post(t, "/tx", raw, &submitted); status != http.StatusOK || submitted.Mined {
+		t.Fatalf("POST /tx = %d %+v, want it kept in the mempool", status, submitted)
+	}
+
+	if event := txs.next(); event["type"] != "newtx" || event["tx"].(map[string]any)["id"] != txID {
+		t.Fatalf("got %v, want newtx of the payment", event)
+	}
+	if event := activity.next(); event["type"] != "address" || event["txid"] != txID || event["received"] != 30.0 || event["height"] != -1.0 {
+		t.Fatalf("got %v, want the pending payment to the address", event)
+	}
+
+	confirmations := dialWS(t, n)
+	websocket.JSON.Send(confirmations.ws, subscribeRequest{Action: "subscribe", Topic: topicConfirmations + txID})
+	confirmations.next()
+	if event := confirmations.next(); event["status"] != "mempool" {
+		t.Fatalf("got %v, want the payment in the mempool", event)
+	}
+
+	var mined blockJSON
+	for height := 1; height <= 2; height++ {
+		n.post(t, "/mine?address="+n.miner, "", &mined)
+		if event := blocks.next(); event["type"] != "newblock" || event["block"].(map[string]any)["hash"] != mined.Hash {
+			t.Fatalf("got %v, want newblock at height %d", event, height)
+		}
+	}
+
+	// The coinbase of each block is new; the payment was already seen.
+	for height := 1; height <= 2; height++ {
+		if event := txs.next(); event["tx"].(map[string]any)["coinbase"] != true {
+			t.Fatalf("got %v, want the coinbase of block %d", event, height)
+		}
+	}
+	if event := activity.next(); event["txid"] != txID || event["height"] != 2.0 || event["confirmations"] != 1.0 {
+		t.Fatalf("got %v, want the payment mined at height 2", event)
+	}
+	if event := confirmations.next(); event["status"] != "mempool" {
+		t.Fatalf("got %v at height 1, want the payment still in the mempool", event)
+	}
+	if event := confirmations.next(); event["status"] != "confirmed" || event["blockHash"] != mined.Hash || event["confirmations"] != 1.0 {
+		t.Fatalf("got %v, want the payment confirmed in block %s", event, mined.Hash)
+	}
+
+	// Disconnecting the block is a reorg back to height 1.
+	var disconnected disconnectResponse
+	n.post(t, "/disconnect", "", &disconnected)
+
+	event := blocks.next()
+	if event["type"] != "reorg" || event["forkHeight"] != 1.0 {
+		t.Fatalf("got %v, want a reorg to height 1", event)
+	}
+	if hashes := event["disconnected"].([]any); len(hashes) != 1 || hashes[0] != mined.Hash {
+		t.Fatalf("reorg disconnected %v, want %s", hashes, mined.Hash)
+	}
+	if event := confirmations.next(); event["status"] != "mempool" {
+		t.Fatalf("got %v, want the payment back in the mempool", event)
+	}
+}
@@ -0,0 +1,103 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/blockchain"
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// maxRawTxSize limits the body of POST /tx.
+const maxRawTxSize = 1 << 20
+
+type submitResponse struct {
+	TxID  string `json:"txid"`
+	Mined bool   `json:"mined"`
+}
+
+type disconnectResponse struct {
+	Disconnected string `json:"disconnected"`
+	Height       int    `json:"height"`
+	Tip          string `json:"tip"`
+}
+
+func (s *Server) registerSubmit() {
+	s.handleWrite("POST /tx", s.handleSubmitTx)
+	s.handleWrite("POST /mine", s.handleMine)
+	s.handleWrite("POST /disconnect", s.handleDisconnect)
+}
+
+// handleSubmitTx takes a signed raw transaction, hex encoded as written by
+// createrawtx and signrawtx, and mines it or adds it to the mempool like
+// sendrawtx.
+func (s *Server) handleSubmitTx(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRawTxSize))
+	if err != nil {
+		writeError(w, badRequest("reading the transaction: %v", err))
+		return
+	}
+
+	data, err := hex.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		writeError(w, badRequest("the transaction must be hex encoded"))
+		return
+	}
+
+	ptx, err := blockchain.DeserializePartialTransaction(data)
+	if err != nil {
+		writeError(w, badRequest("%v", err))
+		return
+	}
+
+	tx, err := ptx.Finalize()
+	if err != nil {
+		writeError(w, badRequest("%v", err))
+		return
+	}
+
+	mined, err := s.chain.SubmitTransaction(tx)
+	if err != nil {
+		writeError(w, badRequest("%v", err))
+		return
+	}
+
+	writeJSON(w, submitResponse{
+		TxID:  hex.EncodeToString(tx.ID),
+		Mined: mined,
+	})
+}
+
+// handleMine mines a block with the ready mempool transactions, paying the
+// reward to the address query parameter.
+func (s *Server) handleMine(w http.ResponseWriter, r *http.Request) {
+	address := r.URL.Query().Get("address")
+	if _, err := wallet.ParseAddress(address); err != nil {
+		writeError(w, badRequest("%v", err))
+		return
+	}
+
+	block := s.chain.MineBlock(address)
+
+	header := block.Header()
+	resp, err := s.blockJSON(&header, block.Height, true)
+	writeResult(w, resp, err)
+}
+
+// handleDisconnect takes the tip block off the chain like disconnectblock,
+// returning its transactions to the mempool.
+func (s *Server) handleDisconnect(w http.ResponseWriter, r *http.Request) {
+	block, err := s.chain.DisconnectBlock()
+	if err != nil {
+		writeError(w, badRequest("%v", err))
+		return
+	}
+
+	writeJSON(w, disconnectResponse{
+		Disconnected: hex.EncodeToString(block.Hash),
+		Height:       block.Height,
+		Tip:          hex.EncodeToString(s.chain.LastHash),
+	})
+}
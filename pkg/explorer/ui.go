@@ -0,0 +1,211 @@
+package explorer
+
+import (
+	"bytes"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// The HTML pages live under /ui/ and render the same data as the JSON API.
+// Templates and styles are embedded, so the explorer needs no other files.
+
+//go:embed templates
+var templateFS embed.FS
+
+var pages = parsePages("index.html", "block.html", "tx.html", "address.html", "error.html")
+
+func parsePages(names ...string) map[string]*template.Template {
+	funcs := template.FuncMap{
+		"time": func(timestamp int64) string {
+			return time.Unix(timestamp, 0).UTC().Format("2006-01-02 15:04:05 UTC")
+		},
+		"short": func(hash string) string {
+			if len(hash) <= 16 {
+				return hash
+			}
+			return hash[:8] + "…" + hash[len(hash)-8:]
+		},
+		"spentStatus": func(spent *bool) string {
+			switch {
+			case spent == nil:
+				return ""
+			case *spent:
+				return "spent"
+			default:
+				return "unspent"
+			}
+		},
+		"add": func(a, b int) int { return a + b },
+		"sub": func(a, b int) int { return a - b },
+	}
+
+	pages := map[string]*template.Template{}
+	for _, name := range names {
+		pages[name] = template.Must(template.New(name).Funcs(funcs).ParseFS(templateFS,
+			"templates/layout.html", "templates/partials.html", "templates/"+name))
+	}
+
+	return pages
+}
+
+type indexPage struct {
+	Stats   statsResponse
+	Blocks  blocksResponse
+	Mempool mempoolResponse
+}
+
+type addressPage struct {
+	addressResponse
+	PrevOffset int
+	NextOffset int
+}
+
+type errorPage struct {
+	Status  string
+	Message string
+}
+
+func (s *Server) registerUI() {
+	s.mux.Handle("GET /{$}", http.RedirectHandler("/ui/", http.StatusFound))
+	s.handleRead("GET /ui/{$}", s.handleIndexPage)
+	s.handleRead("GET /ui/block/{id}", s.handleBlockPage)
+	s.handleRead("GET /ui/tx/{id}", s.handleTxPage)
+	s.handleRead("GET /ui/address/{address}", s.handleAddressPage)
+	s.handleRead("GET /ui/search", s.handleSearch)
+}
+
+func (s *Server) handleIndexPage(w http.ResponseWriter, r *http.Request) {
+	from, err := queryInt(r, "from", -1)
+	if err != nil {
+		renderPage(w, "", nil, err)
+		return
+	}
+
+	stats, err := s.stats()
+	if err != nil {
+		renderPage(w, "", nil, err)
+		return
+	}
+
+	blocks, err := s.blocks(from, defaultPageSize)
+	if err != nil {
+		renderPage(w, "", nil, err)
+		return
+	}
+
+	renderPage(w, "index.html", indexPage{
+		Stats:   stats,
+		Blocks:  blocks,
+		Mempool: s.mempool(0, defaultPageSize),
+	}, nil)
+}
+
+func (s *Server) handleBlockPage(w http.ResponseWriter, r *http.Request) {
+	block, err := s.block(r.PathValue("id"))
+	renderPage(w, "block.html", block, err)
+}
+
+func (s *Server) handleTxPage(w http.ResponseWriter, r *http.Request) {
+	tx, err := s.tx(r.PathValue("id"))
+	renderPage(w, "tx.html", tx, err)
+}
+
+func (s *Server) handleAddressPage(w http.ResponseWriter, r *http.Request) {
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		renderPage(w, "", nil, err)
+		return
+	}
+
+	address, err := s.address(r.PathValue("address"), offset, defaultPageSize)
+	if err != nil {
+		renderPage(w, "", nil, err)
+		return
+	}
+
+	page := addressPage{
+		addressResponse: address,
+		PrevOffset:      max(offset-defaultPageSize, 0),
+	}
+	if offset+defaultPageSize < address.TxCount {
+		page.NextOffset = offset + defaultPageSize
+	}
+
+	renderPage(w, "address.html", page, nil)
+}
+
+// handleSearch sends a block hash or height, transaction ID or address to
+// its page.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	target, err := s.search(strings.TrimSpace(r.URL.Query().Get("q")))
+	if err != nil {
+		renderPage(w, "", nil, err)
+		return
+	}
+
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (s *Server) search(query string) (string, error) {
+	if query == "" {
+		return "", badRequest("search for a block hash or height, a transaction ID or an address")
+	}
+
+	if _, err := strconv.Atoi(query); err == nil && len(query) < 2*32 {
+		if _, err := s.findHeader(query); err != nil {
+			return "", err
+		}
+		return "/ui/block/" + query, nil
+	}
+
+	if hash, err := hex.DecodeString(query); err == nil && len(hash) > 0 {
+		if _, err := s.chain.GetHeader(hash); err == nil {
+			return "/ui/block/" + query, nil
+		}
+		if _, err := s.tx(query); err == nil {
+			return "/ui/tx/" + query, nil
+		}
+	}
+
+	if _, err := wallet.ParseAddress(query); err == nil {
+		return "/ui/address/" + url.PathEscape(query), nil
+	}
+
+	return "", notFound(fmt.Errorf("no block, transaction or address matches %q", query))
+}
+
+// renderPage renders the named page with data, or an error page when err
+// is not nil.
+func renderPage(w http.ResponseWriter, name string, data any, err error) {
+	status := http.StatusOK
+	if err != nil {
+		status = statusOf(err)
+		name = "error.html"
+		data = errorPage{
+			Status:  fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			Message: err.Error(),
+		}
+	}
+
+	var page bytes.Buffer
+	err = pages[name].ExecuteTemplate(&page, "layout.html", data)
+	if err != nil {
+		log.Printf("explorer: rendering %s: %v", name, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	page.WriteTo(w)
+}
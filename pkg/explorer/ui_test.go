@@ -0,0 +1,126 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/blockchain"
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// page returns the status, body and redirect location of a GET of path,
+// without following the redirect.
+func (n *testNode) page(t *testing.T, path string) (int, string, string) {
+	t.Helper()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	r, err := client.Get(n.server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r.StatusCode, string(body), r.Header.Get("Location")
+}
+
+func TestUIPages(t *testing.T) {
+	n := newTestNode(t, Options{})
+	payee := string(wallet.NewWallet(wallet.KeyP256).Address())
+
+	tx := blockchain.NewTransaction(n.miner, payee, 30, n.chain, n.wallets, blockchain.LargestFirst{}, blockchain.TxOptions{})
+	if mined, err := n.chain.SubmitTransaction(tx); err != nil || !mined {
+		t.Fatalf("SubmitTransaction = %v, %v", mined, err)
+	}
+	txID := hex.EncodeToString(tx.ID)
+	tipHash := hex.EncodeToString(n.chain.LastHash)
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/ui/", []string{tipHash}},
+		{"/ui/block/" + tipHash, []string{txID}},
+		{"/ui/block/1", []string{tipHash, txID}},
+		{"/ui/tx/" + txID, []string{tipHash, payee}},
+		{"/ui/address/" + payee, []string{payee, txID}},
+	}
+
+	for _, test := range tests {
+		status, body, _ := n.page(t, test.path)
+		if status != http.StatusOK || !strings.Contains(body, "<html") {
+			t.Errorf("GET %s = %d, want an HTML page", test.path, status)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(body, want) {
+				t.Errorf("GET %s does not show %s", test.path, want)
+			}
+		}
+	}
+
+	if status, _, location := n.page(t, "/"); status != http.StatusFound || location != "/ui/" {
+		t.Errorf("GET / = %d to %q, want a redirect to /ui/", status, location)
+	}
+}
+
+func TestUIErrors(t *testing.T) {
+	n := newTestNode(t, Options{})
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/ui/block/nothex", http.StatusBadRequest},
+		{"/ui/block/7", http.StatusNotFound},
+		{"/ui/tx/" + strings.Repeat("ab", 32), http.StatusNotFound},
+		{"/ui/address/nonsense", http.StatusBadRequest},
+		{"/ui/?from=x", http.StatusBadRequest},
+		{"/ui/search?q=", http.StatusBadRequest},
+		{"/ui/search?q=nonsense", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		status, body, _ := n.page(t, test.path)
+		if status != test.status || !strings.Contains(body, http.StatusText(test.status)) {
+			t.Errorf("GET %s = %d, want an error page for %d", test.path, status, test.status)
+		}
+	}
+}
+
+func TestUISearch(t *testing.T) {
+	n := newTestNode(t, Options{})
+	block := n.chain.MineBlock(n.miner)
+	tipHash := hex.EncodeToString(block.Hash)
+	txID := hex.EncodeToString(block.Transactions[0].ID)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"1", "/ui/block/1"},
+		{tipHash, "/ui/block/" + tipHash},
+		{txID, "/ui/tx/" + txID},
+		{n.miner, "/ui/address/" + n.miner},
+	}
+
+	for _, test := range tests {
+		status, _, location := n.page(t, "/ui/search?q="+url.QueryEscape(test.query))
+		if status != http.StatusFound || location != test.want {
+			t.Errorf("search for %s = %d to %q, want %s", test.query, status, location, test.want)
+		}
+	}
+}
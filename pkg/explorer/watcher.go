@@ -0,0 +1,390 @@
+package explorer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/blockchain"
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+type blockEvent struct {
+	Type  string    `json:"type"`
+	Topic string    `json:"topic"`
+	Block blockJSON `json:"block"`
+}
+
+type reorgEvent struct {
+	Type         string   `json:"type"`
+	Topic        string   `json:"topic"`
+	ForkHeight   int      `json:"forkHeight"`
+	Disconnected []string `json:"disconnected"`
+	Connected    []string `json:"connected"`
+}
+
+type txEvent struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+	Tx    txJSON `json:"tx"`
+}
+
+type addressEvent struct {
+	Type          string `json:"type"`
+	Topic         string `json:"topic"`
+	Address       string `json:"address"`
+	TxID          string `json:"txid"`
+	Received      int    `json:"received"`
+	Sent          int    `json:"sent"`
+	Height        int    `json:"height"`
+	Confirmations int    `json:"confirmations"`
+}
+
+type confirmationsEvent struct {
+	Type          string `json:"type"`
+	Topic         string `json:"topic"`
+	TxID          string `json:"txid"`
+	Status        string `json:"status"`
+	BlockHash     string `json:"blockHash,omitempty"`
+	Height        int    `json:"height"`
+	Confirmations int    `json:"confirmations"`
+}
+
+// watcher follows the chain's events and publishes what changed to the hub.
+// Events only wake it up: it compares the tip and mempool with what it saw
+// last, so events it drops while busy lose nothing.
+type watcher struct {
+	server  *Server
+	events  *blockchain.Subscription
+	done    chan struct{}
+	stopped sync.Once
+
+	// Only used by the run goroutine.
+	tip     *blockchain.BlockHeader
+	mempool map[string]bool
+
+	mu sync.Mutex
+	// txBlocks caches the blocks watched transactions were mined in.
+	txBlocks map[string]*blockchain.BlockHeader
+}
+
+func newWatcher(s *Server) *watcher {
+	w := &watcher{
+		server:   s,
+		events:   s.chain.Events().Subscribe(1, blockchain.DropOnOverflow),
+		done:     make(chan struct{}),
+		mempool:  map[string]bool{},
+		txBlocks: map[string]*blockchain.BlockHeader{},
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tip, err := s.chain.GetHeader(s.chain.LastHash)
+	if err != nil {
+		log.Panic(err)
+	}
+	w.tip = tip
+
+	for _, tx := range s.chain.MempoolTransactions() {
+		w.mempool[hex.EncodeToString(tx.ID)] = true
+	}
+
+	return w
+}
+
+func (w *watcher) run() {
+	defer w.events.Close()
+
+	for {
+		select {
+		case <-w.events.Events():
+		case <-w.done:
+			return
+		}
+
+		w.catchUp()
+	}
+}
+
+func (w *watcher) stop() {
+	w.stopped.Do(func() {
+		close(w.done)
+	})
+}
+
+func (w *watcher) catchUp() {
+	s := w.server
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Store errors panic like everywhere else; they must not take the
+	// server down with them.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("explorer: watching the chain: %v", recovered)
+		}
+	}()
+
+	if !bytes.Equal(s.chain.LastHash, w.tip.Hash) {
+		w.tipChanged()
+	}
+	w.mempoolChanged()
+}
+
+// tipChanged publishes the blocks between the last tip seen and the current
+// one. When the new tip is not a descendant of the old one, the blocks back
+// to their fork point are reported disconnected first.
+func (w *watcher) tipChanged() {
+	chain := w.server.chain
+
+	tip, err := chain.GetHeader(chain.LastHash)
+	if err != nil {
+		log.Printf("explorer: reading the tip: %v", err)
+		return
+	}
+
+	disconnected, connected, err := w.forkPath(tip)
+	if err != nil {
+		log.Printf("explorer: finding the fork point: %v", err)
+		w.tip = tip
+		return
+	}
+	w.tip = tip
+
+	if len(disconnected) > 0 {
+		event := reorgEvent{
+			Type:         "reorg",
+			Topic:        topicNewBlock,
+			ForkHeight:   disconnected[len(disconnected)-1].Height - 1,
+			Disconnected: []string{},
+			Connected:    []string{},
+		}
+		for _, header := range disconnected {
+			event.Disconnected = append(event.Disconnected, hex.EncodeToString(header.Hash))
+		}
+		for _, header := range connected {
+			event.Connected = append(event.Connected, hex.EncodeToString(header.Hash))
+		}
+		w.server.hub.publish(topicNewBlock, event)
+
+		w.forgetBlocks(disconnected)
+	}
+
+	for _, header := range connected {
+		w.blockConnected(header, tip.Height)
+	}
+
+	for _, topic := range w.server.hub.topics(topicConfirmations) {
+		w.server.hub.publish(topic, w.confirmations(topic))
+	}
+}
+
+// forkPath returns the headers from the last tip seen back to the fork
+// point with tip, newest first, and from the fork point up to tip, oldest
+// first. Disconnected blocks keep their headers, so both sides can be walked.
+func (w *watcher) forkPath(tip *blockchain.BlockHeader) ([]*blockchain.BlockHeader, []*blockchain.BlockHeader, error) {
+	chain := w.server.chain
+	disconnected := []*blockchain.BlockHeader{}
+	connected := []*blockchain.BlockHeader{}
+
+	oldTip, newTip := w.tip, tip
+	var err error
+	for !bytes.Equal(oldTip.Hash, newTip.Hash) {
+		if newTip.Height >= oldTip.Height {
+			connected = append(connected, newTip)
+			newTip, err = chain.GetHeader(newTip.PrevHash)
+		} else {
+			disconnected = append(disconnected, oldTip)
+			oldTip, err = chain.GetHeader(oldTip.PrevHash)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	slices.Reverse(connected)
+
+	return disconnected, connected, nil
+}
+
+func (w *watcher) blockConnected(header *blockchain.BlockHeader, best int) {
+	s := w.server
+
+	summary, err := s.blockJSON(header, best, false)
+	if err != nil {
+		log.Printf("explorer: describing block %x: %v", header.Hash, err)
+		return
+	}
+	s.hub.publish(topicNewBlock, blockEvent{Type: "newblock", Topic: topicNewBlock, Block: summary})
+
+	block, err := s.chain.GetBlock(header.Hash)
+	if err != nil {
+		// Pruned before it was seen.
+		return
+	}
+
+	spent, err := s.chain.SpentOutputs(block.Hash)
+	if err != nil || len(spent) != len(block.Transactions) {
+		log.Printf("explorer: reading the outputs spent by block %x: %v", block.Hash, err)
+		spent = make([][]blockchain.TxOutput, len(block.Transactions))
+	}
+
+	for idx, tx := range block.Transactions {
+		if !w.mempool[hex.EncodeToString(tx.ID)] {
+			s.hub.publish(topicNewTx, txEvent{Type: "newtx", Topic: topicNewTx, Tx: s.txJSON(tx, block, best)})
+		}
+		w.publishAddressActivity(tx, spent[idx], block, best)
+	}
+}
+
+// mempoolChanged publishes the transactions that entered the mempool since
+// the watcher last caught up.
+func (w *watcher) mempoolChanged() {
+	s := w.server
+	mempool := map[string]bool{}
+	watched := s.hub.topics(topicConfirmations)
+
+	for _, tx := range s.chain.MempoolTransactions() {
+		id := hex.EncodeToString(tx.ID)
+		mempool[id] = true
+		if w.mempool[id] {
+			continue
+		}
+
+		s.hub.publish(topicNewTx, txEvent{Type: "newtx", Topic: topicNewTx, Tx: s.txJSON(tx, nil, 0)})
+		w.publishAddressActivity(tx, w.spentOutputs(tx), nil, 0)
+
+		topic := topicConfirmations + id
+		if slices.Contains(watched, topic) {
+			s.hub.publish(topic, w.confirmations(topic))
+		}
+	}
+
+	w.mempool = mempool
+}
+
+// publishAddressActivity tells the subscribers of each address tx pays to
+// or spends from, given the outputs spent by its inputs. tx is mined in
+// block, or waiting in the mempool when block is nil.
+func (w *watcher) publishAddressActivity(tx *blockchain.Transaction, spent []blockchain.TxOutput, block *blockchain.Block, best int) {
+	topics := w.server.hub.topics(topicAddress)
+	if len(topics) == 0 {
+		return
+	}
+
+	for _, topic := range topics {
+		address, err := wallet.ParseAddress(strings.TrimPrefix(topic, topicAddress))
+		if err != nil {
+			continue
+		}
+		lock := blockchain.LockingScript(address)
+
+		event := addressEvent{
+			Type:    "address",
+			Topic:   topic,
+			Address: address.String(),
+			TxID:    hex.EncodeToString(tx.ID),
+			Height:  -1,
+		}
+		if block != nil {
+			event.Height = block.Height
+			event.Confirmations = best - block.Height + 1
+		}
+
+		matched := false
+		for _, txOut := range tx.Outputs {
+			if txOut.IsLockedWith(lock) {
+				event.Received += txOut.Value
+				matched = true
+			}
+		}
+		for _, txOut := range spent {
+			if txOut.IsLockedWith(lock) {
+				event.Sent += txOut.Value
+				matched = true
+			}
+		}
+
+		if matched {
+			w.server.hub.publish(topic, event)
+		}
+	}
+}
+
+// spentOutputs returns the outputs the inputs of a mempool transaction
+// spend, which are still in the UTXO set.
+func (w *watcher) spentOutputs(tx *blockchain.Transaction) []blockchain.TxOutput {
+	spent := []blockchain.TxOutput{}
+
+	for _, txIn := range tx.Inputs {
+		utxo, err := w.server.chain.FindUnspentOutput(txIn.ID, txIn.Out)
+		if err == nil {
+			spent = append(spent, utxo.Output)
+		}
+	}
+
+	return spent
+}
+
+// confirmations describes the confirmations of the transaction of a
+// confirmations topic. The caller holds the server's read lock.
+func (w *watcher) confirmations(topic string) confirmationsEvent {
+	chain := w.server.chain
+	id := strings.TrimPrefix(topic, topicConfirmations)
+	event := confirmationsEvent{
+		Type:   "confirmations",
+		Topic:  topic,
+		TxID:   id,
+		Status: "unknown",
+		Height: -1,
+	}
+
+	w.mu.Lock()
+	header, ok := w.txBlocks[id]
+	w.mu.Unlock()
+
+	if !ok {
+		txID, _ := hex.DecodeString(id)
+		_, block, err := chain.FindTransactionBlock(txID)
+		if err != nil {
+			for _, tx := range chain.MempoolTransactions() {
+				if bytes.Equal(tx.ID, txID) {
+					event.Status = "mempool"
+				}
+			}
+			return event
+		}
+
+		blockHeader := block.Header()
+		header = &blockHeader
+
+		w.mu.Lock()
+		w.txBlocks[id] = header
+		w.mu.Unlock()
+	}
+
+	event.Status = "confirmed"
+	event.BlockHash = hex.EncodeToString(header.Hash)
+	event.Height = header.Height
+	event.Confirmations = chain.GetBestHeight() - header.Height + 1
+
+	return event
+}
+
+// forgetBlocks drops the cached blocks of watched transactions that are no
+// longer on the chain.
+func (w *watcher) forgetBlocks(headers []*blockchain.BlockHeader) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for id, cached := range w.txBlocks {
+		for _, header := range headers {
+			if bytes.Equal(cached.Hash, header.Hash) {
+				delete(w.txBlocks, id)
+			}
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+	"golang.org/x/net/websocket"
+)
+
+// Clients of /ws send {"action": "subscribe", "topic": TOPIC} or
+// "unsubscribe" and receive JSON events with a type and topic:
+//
+//	newblock                 "newblock" for every block added to the tip, and
+//	                         "reorg" when the tip moves to another branch
+//	newtx                    "newtx" for every transaction first seen, in the
+//	                         mempool or in a block
+//	address:ADDRESS          "address" for every transaction paying to or
+//	                         spending from ADDRESS
+//	confirmations:TXID       "confirmations" on subscribing and whenever the
+//	                         confirmations of TXID change
+const (
+	topicNewBlock      = "newblock"
+	topicNewTx         = "newtx"
+	topicAddress       = "address:"
+	topicConfirmations = "confirmations:"
+)
+
+// subscriberBuffer is how many events a client may fall behind before it
+// is disconnected.
+const subscriberBuffer = 256
+
+type subscribeRequest struct {
+	Action string `json:"action"`
+	Topic  string `json:"topic"`
+}
+
+type replyEvent struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func (s *Server) registerWebSocket() {
+	// websocket.Server, unlike websocket.Handler, accepts clients that send
+	// no Origin, like most non-browser clients.
+	s.mux.Handle("GET /ws", websocket.Server{Handler: s.handleWebSocket})
+}
+
+func (s *Server) handleWebSocket(ws *websocket.Conn) {
+	sub := s.hub.add()
+	defer s.hub.remove(sub)
+
+	go func() {
+		// Unblocks the read loop when the subscriber is dropped.
+		<-sub.done
+		ws.Close()
+	}()
+	go writeEvents(ws, sub)
+
+	for {
+		var msg string
+		err := websocket.Message.Receive(ws, &msg)
+		if err != nil {
+			return
+		}
+
+		var req subscribeRequest
+		err = json.Unmarshal([]byte(msg), &req)
+		if err != nil {
+			s.hub.send(sub, replyEvent{Type: "error", Error: "requests must be JSON objects"})
+			continue
+		}
+
+		s.handleSubscribeRequest(sub, req)
+	}
+}
+
+func writeEvents(ws *websocket.Conn, sub *subscriber) {
+	for {
+		select {
+		case event := <-sub.events:
+			err := websocket.JSON.Send(ws, event)
+			if err != nil {
+				sub.close()
+				return
+			}
+		case <-sub.done:
+			return
+		}
+	}
+}
+
+func (s *Server) handleSubscribeRequest(sub *subscriber, req subscribeRequest) {
+	topic, err := parseTopic(req.Topic)
+	if err != nil {
+		s.hub.send(sub, replyEvent{Type: "error", Topic: req.Topic, Error: err.Error()})
+		return
+	}
+
+	switch req.Action {
+	case "subscribe":
+		s.hub.subscribe(sub, topic)
+		s.hub.send(sub, replyEvent{Type: "subscribed", Topic: topic})
+
+		if strings.HasPrefix(topic, topicConfirmations) {
+			s.mu.RLock()
+			event := s.watcher.confirmations(topic)
+			s.mu.RUnlock()
+
+			s.hub.send(sub, event)
+		}
+	case "unsubscribe":
+		s.hub.unsubscribe(sub, topic)
+		s.hub.send(sub, replyEvent{Type: "unsubscribed", Topic: topic})
+	default:
+		s.hub.send(sub, replyEvent{Type: "error", Topic: topic, Error: "action must be subscribe or unsubscribe"})
+	}
+}
+
+// parseTopic checks topic and returns it in canonical form, so that every
+// spelling of an address or transaction ID gets the same events.
+func parseTopic(topic string) (string, error) {
+	switch {
+	case topic == topicNewBlock, topic == topicNewTx:
+		return topic, nil
+	case strings.HasPrefix(topic, topicAddress):
+		address, err := wallet.ParseAddress(strings.TrimPrefix(topic, topicAddress))
+		if err != nil {
+			return "", err
+		}
+		return topicAddress + address.String(), nil
+	case strings.HasPrefix(topic, topicConfirmations):
+		txID, err := hex.DecodeString(strings.TrimPrefix(topic, topicConfirmations))
+		if err != nil || len(txID) == 0 {
+			return "", fmt.Errorf("transaction ID must be hex")
+		}
+		return topicConfirmations + hex.EncodeToString(txID), nil
+	default:
+		return "", fmt.Errorf("unknown topic %q", topic)
+	}
+}
+
+type subscriber struct {
+	events chan any
+	done   chan struct{}
+	once   sync.Once
+	// topics is guarded by the hub.
+	topics map[string]bool
+}
+
+func (s *subscriber) close() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+// hub tracks the WebSocket subscribers and what they subscribed to.
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]bool
+}
+
+func newHub() *hub {
+	return &hub{
+		subscribers: map[*subscriber]bool{},
+	}
+}
+
+func (h *hub) add() *subscriber {
+	sub := &subscriber{
+		events: make(chan any, subscriberBuffer),
+		done:   make(chan struct{}),
+		topics: map[string]bool{},
+	}
+
+	h.mu.Lock()
+	h.subscribers[sub] = true
+	h.mu.Unlock()
+
+	return sub
+}
+
+func (h *hub) remove(sub *subscriber) {
+	h.mu.Lock()
+	delete(h.subscribers, sub)
+	h.mu.Unlock()
+
+	sub.close()
+}
+
+func (h *hub) subscribe(sub *subscriber, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub.topics[topic] = true
+}
+
+func (h *hub) unsubscribe(sub *subscriber, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(sub.topics, topic)
+}
+
+// topics returns the subscribed topics starting with prefix, in order.
+func (h *hub) topics(prefix string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	found := map[string]bool{}
+	for sub := range h.subscribers {
+		for topic := range sub.topics {
+			if strings.HasPrefix(topic, prefix) {
+				found[topic] = true
+			}
+		}
+	}
+
+	topics := []string{}
+	for topic := range found {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	return topics
+}
+
+// publish sends event to the subscribers of topic.
+func (h *hub) publish(topic string, event any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		if sub.topics[topic] {
+			h.send(sub, event)
+		}
+	}
+}
+
+// send queues event for sub. A subscriber that has fallen too far behind is
+// disconnected rather than holding up the others.
+func (h *hub) send(sub *subscriber, event any) {
+	select {
+	case sub.events <- event:
+	default:
+		sub.close()
+	}
+}
+
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		sub.close()
+	}
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	dataRoot   = "/tmp"
+	networkEnv = "BLOCKCHAIN_NETWORK"
+)
+
+// Network returns the name of the network selected with the
+// BLOCKCHAIN_NETWORK environment variable, or "" for the default one.
+func Network() string {
+	return os.Getenv(networkEnv)
+}
+
+// DataPath returns where the named file or directory of the current network
+// is stored, so several independent chains can run on one machine.
+func DataPath(name string) string {
+	if Network() == "" {
+		return filepath.Join(dataRoot, name)
+	}
+
+	return filepath.Join(dataRoot, Network(), name)
+}
@@ -0,0 +1,98 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/utils"
+)
+
+const addressHashLength = 20
+
+// Address is a parsed pay-to-pubkey-hash or pay-to-script-hash address.
+type Address struct {
+	Version byte
+	Hash    []byte
+}
+
+// ParseAddress decodes a Base58Check or Bech32 address, checking its
+// length, checksum, version byte and, for Bech32, that it belongs to the
+// current network.
+func ParseAddress(address string) (Address, error) {
+	if address == "" {
+		return Address{}, fmt.Errorf("invalid address: empty")
+	}
+
+	if isBech32Address(address) {
+		version, hash, err := decodeBech32Address(address)
+		if err != nil {
+			return Address{}, fmt.Errorf("invalid address %s: %w", address, err)
+		}
+
+		return newAddress(address, version, hash)
+	}
+
+	if hrp, _, _, err := DecodeBech32(address); err == nil {
+		return Address{}, fmt.Errorf("invalid address %s: address is for network %q, not %q", address, hrp, AddressHRP())
+	}
+
+	decoded, err := utils.Base58Decode([]byte(address))
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid address %s: %w", address, err)
+	}
+	if len(decoded) != 1+addressHashLength+checksumLength {
+		return Address{}, fmt.Errorf("invalid address %s: decodes to %d bytes, expected %d", address, len(decoded), 1+addressHashLength+checksumLength)
+	}
+
+	payload := decoded[:len(decoded)-checksumLength]
+	if !bytes.Equal(decoded[len(payload):], Checksum(payload)) {
+		return Address{}, fmt.Errorf("invalid address %s: checksum mismatch", address)
+	}
+
+	return newAddress(address, payload[0], payload[1:])
+}
+
+func newAddress(address string, version byte, hash []byte) (Address, error) {
+	if version != PubKeyHashVersion && version != ScriptHashVersion {
+		return Address{}, fmt.Errorf("invalid address %s: unknown version %d", address, version)
+	}
+
+	if len(hash) != addressHashLength {
+		return Address{}, fmt.Errorf("invalid address %s: hash must be %d bytes", address, addressHashLength)
+	}
+
+	return Address{Version: version, Hash: hash}, nil
+}
+
+// IsScriptHash reports whether the address pays to a script hash rather than
+// a key hash.
+func (a Address) IsScriptHash() bool {
+	return a.Version == ScriptHashVersion
+}
+
+// String returns the Base58Check form, under which wallets and scripts are
+// stored.
+func (a Address) String() string {
+	return string(encodeAddress(a.Version, a.Hash))
+}
+
+// Bech32 returns the Bech32 form on the current network.
+func (a Address) Bech32() string {
+	return EncodeBech32Address(a.Version, a.Hash)
+}
+
+func ValidateAddress(address string) bool {
+	_, err := ParseAddress(address)
+	return err == nil
+}
+
+// canonicalAddress returns the Base58Check form of address, or address
+// itself when it cannot be parsed.
+func canonicalAddress(address string) string {
+	parsed, err := ParseAddress(address)
+	if err != nil {
+		return address
+	}
+
+	return parsed.String()
+}
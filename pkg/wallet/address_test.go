@@ -0,0 +1,92 @@
+package wallet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/utils"
+)
+
+func TestKeyAddressVersion(t *testing.T) {
+	for _, keyType := range []KeyType{KeyP256, KeySecp256k1, KeyEd25519, KeySchnorr} {
+		w := NewWallet(keyType)
+
+		address, err := ParseAddress(string(w.Address()))
+		if err != nil {
+			t.Fatalf("%s: %v", keyType, err)
+		}
+		if address.Version != PubKeyHashVersion || !bytes.Equal(address.Hash, PublicKeyHash(w.PublicKey)) {
+			t.Errorf("%s: address = %+v, want the key hash with version %d", keyType, address, PubKeyHashVersion)
+		}
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	hash := PublicKeyHash([]byte("key"))
+
+	for _, version := range []byte{PubKeyHashVersion, ScriptHashVersion} {
+		base58 := string(encodeAddress(version, hash))
+		for _, encoded := range []string{base58, EncodeBech32Address(version, hash)} {
+			address, err := ParseAddress(encoded)
+			if err != nil {
+				t.Fatalf("%s: %v", encoded, err)
+			}
+			if address.Version != version || !bytes.Equal(address.Hash, hash) || address.String() != base58 {
+				t.Errorf("%s parses as %+v", encoded, address)
+			}
+			if address.IsScriptHash() != (version == ScriptHashVersion) {
+				t.Errorf("%s: IsScriptHash() = %v", encoded, address.IsScriptHash())
+			}
+		}
+	}
+}
+
+func TestParseAddressErrors(t *testing.T) {
+	hash := PublicKeyHash([]byte("key"))
+
+	badChecksum := append([]byte{PubKeyHashVersion}, hash...)
+	badChecksum = append(badChecksum, 1, 2, 3, 4)
+
+	short := append([]byte{PubKeyHashVersion}, hash[1:]...)
+	short = append(short, Checksum(short)...)
+
+	bech32 := EncodeBech32Address(PubKeyHashVersion, hash)
+	_, data, _, err := DecodeBech32(bech32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		address string
+		want    string
+	}{
+		{"empty", "", "invalid address: empty"},
+		{"bad checksum", string(utils.Base58Encode(badChecksum)), "checksum mismatch"},
+		{"wrong length", string(utils.Base58Encode(short)), "decodes to 24 bytes, expected 25"},
+		{"unknown version", string(encodeAddress(0x09, hash)), "unknown version 9"},
+		{"not Base58", "0OIl" + string(encodeAddress(PubKeyHashVersion, hash))[4:], "invalid address 0OIl"},
+		{"Bech32 typo", bech32[:len(bech32)-1] + "q", "invalid checksum"},
+		{"Bech32 outside the alphabet", bech32[:10] + "b" + bech32[11:], "invalid character"},
+		{"Bech32 of another network", EncodeBech32("xx", data, Bech32), `address is for network "xx"`},
+		{"Bech32 wrong length", EncodeBech32Address(PubKeyHashVersion, hash[1:]), "hash must be 20 bytes"},
+		{"Bech32 unknown version", EncodeBech32Address(0x09, hash), "unknown version 9"},
+		{"Bech32m key hash", EncodeBech32(AddressHRP(), data, Bech32m), "version 0 addresses must use bech32"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseAddress(test.address)
+			if err == nil {
+				t.Fatalf("%s parses", test.address)
+			}
+			if !strings.HasPrefix(err.Error(), "invalid address") || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("got %q, want it to contain %q", err, test.want)
+			}
+			if ValidateAddress(test.address) {
+				t.Fatal("ValidateAddress accepts it")
+			}
+		})
+	}
+}
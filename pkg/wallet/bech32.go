@@ -0,0 +1,295 @@
+package wallet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/utils"
+)
+
+const (
+	bech32Charset   = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	bech32MaxLength = 90
+	defaultHRP      = "gb"
+)
+
+// Bech32Variant selects the checksum constant: Bech32 (BIP173) or Bech32m
+// (BIP350).
+type Bech32Variant int
+
+const (
+	Bech32 Bech32Variant = iota
+	Bech32m
+)
+
+func (v Bech32Variant) constant() uint32 {
+	if v == Bech32m {
+		return 0x2bc830a3
+	}
+
+	return 1
+}
+
+func (v Bech32Variant) String() string {
+	if v == Bech32m {
+		return "bech32m"
+	}
+
+	return "bech32"
+}
+
+// Bech32Error describes why a Bech32 string was rejected. Positions holds
+// the indexes of characters that are likely wrong, when they can be found.
+type Bech32Error struct {
+	Reason    string
+	Positions []int
+}
+
+func (e *Bech32Error) Error() string {
+	if len(e.Positions) == 0 {
+		return e.Reason
+	}
+
+	positions := []string{}
+	for _, pos := range e.Positions {
+		positions = append(positions, fmt.Sprint(pos))
+	}
+
+	return fmt.Sprintf("%s (check character at position %s)", e.Reason, strings.Join(positions, ", "))
+}
+
+// AddressHRP returns the human-readable prefix of Bech32 addresses on the
+// current network.
+func AddressHRP() string {
+	if network := utils.Network(); network != "" {
+		return strings.ToLower(network)
+	}
+
+	return defaultHRP
+}
+
+// EncodeBech32 encodes 5-bit data under hrp with a checksum of the given
+// variant.
+func EncodeBech32(hrp string, data []byte, variant Bech32Variant) string {
+	var builder strings.Builder
+
+	builder.WriteString(hrp)
+	builder.WriteByte('1')
+
+	checksum := bech32Checksum(hrp, data, variant)
+	for _, value := range append(append([]byte{}, data...), checksum...) {
+		builder.WriteByte(bech32Charset[value])
+	}
+
+	return builder.String()
+}
+
+// DecodeBech32 splits a Bech32 or Bech32m string into its prefix and 5-bit
+// data, reporting which checksum variant it uses.
+func DecodeBech32(s string) (string, []byte, Bech32Variant, error) {
+	if len(s) > bech32MaxLength {
+		return "", nil, 0, &Bech32Error{Reason: fmt.Sprintf("longer than %d characters", bech32MaxLength)}
+	}
+	// Only ASCII letters have a case. Other bytes are left for the checks
+	// below to reject, at the position they are at.
+	lowered := []byte(s)
+	hasLower, hasUpper := false, false
+	for idx, c := range lowered {
+		switch {
+		case 'a' <= c && c <= 'z':
+			hasLower = true
+		case 'A' <= c && c <= 'Z':
+			hasUpper = true
+			lowered[idx] = c + 'a' - 'A'
+		}
+	}
+	if hasLower && hasUpper {
+		return "", nil, 0, &Bech32Error{Reason: "mixed case"}
+	}
+	s = string(lowered)
+
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, 0, &Bech32Error{Reason: "missing separator or checksum"}
+	}
+
+	hrp := s[:sep]
+	for idx := 0; idx < len(hrp); idx++ {
+		if hrp[idx] < 33 || hrp[idx] > 126 {
+			return "", nil, 0, &Bech32Error{Reason: "invalid prefix character", Positions: []int{idx}}
+		}
+	}
+
+	data := []byte{}
+	invalid := []int{}
+	for idx := sep + 1; idx < len(s); idx++ {
+		value := strings.IndexByte(bech32Charset, s[idx])
+		if value < 0 {
+			invalid = append(invalid, idx)
+			continue
+		}
+		data = append(data, byte(value))
+	}
+	if len(invalid) > 0 {
+		return "", nil, 0, &Bech32Error{Reason: "invalid character", Positions: invalid}
+	}
+
+	polymod := bech32Polymod(hrp, data)
+	for _, variant := range []Bech32Variant{Bech32, Bech32m} {
+		if polymod == variant.constant() {
+			return hrp, data[:len(data)-6], variant, nil
+		}
+	}
+
+	return "", nil, 0, &Bech32Error{Reason: "invalid checksum", Positions: locateBech32Error(hrp, data, sep+1)}
+}
+
+// locateBech32Error looks for a single substituted character that would make
+// the checksum valid and returns its position in the full string.
+func locateBech32Error(hrp string, data []byte, offset int) []int {
+	positions := []int{}
+	candidate := append([]byte{}, data...)
+
+	for idx := range candidate {
+		original := candidate[idx]
+
+		for value := byte(0); value < 32; value++ {
+			if value == original {
+				continue
+			}
+
+			candidate[idx] = value
+			polymod := bech32Polymod(hrp, candidate)
+			if polymod == Bech32.constant() || polymod == Bech32m.constant() {
+				positions = append(positions, offset+idx)
+				break
+			}
+		}
+
+		candidate[idx] = original
+	}
+
+	return positions
+}
+
+func bech32Checksum(hrp string, data []byte, variant Bech32Variant) []byte {
+	values := append(append([]byte{}, data...), make([]byte, 6)...)
+	polymod := bech32Polymod(hrp, values) ^ variant.constant()
+
+	checksum := make([]byte, 6)
+	for idx := range checksum {
+		checksum[idx] = byte(polymod>>uint(5*(5-idx))) & 31
+	}
+
+	return checksum
+}
+
+func bech32Polymod(hrp string, data []byte) uint32 {
+	generator := []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+
+	step := func(value byte) {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(value)
+		for idx, gen := range generator {
+			if (top>>uint(idx))&1 == 1 {
+				chk ^= gen
+			}
+		}
+	}
+
+	for idx := 0; idx < len(hrp); idx++ {
+		step(hrp[idx] >> 5)
+	}
+	step(0)
+	for idx := 0; idx < len(hrp); idx++ {
+		step(hrp[idx] & 31)
+	}
+	for _, value := range data {
+		step(value)
+	}
+
+	return chk
+}
+
+// convertBits regroups data from fromBits to toBits wide values.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	acc := uint32(0)
+	bits := uint(0)
+	maxValue := uint32(1)<<toBits - 1
+	result := []byte{}
+
+	for _, value := range data {
+		if uint32(value)>>fromBits != 0 {
+			return nil, fmt.Errorf("value %d does not fit in %d bits", value, fromBits)
+		}
+
+		acc = acc<<fromBits | uint32(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&maxValue))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+
+	return result, nil
+}
+
+// EncodeBech32Address returns the Bech32 form of an address with the given
+// version byte and hash. Key hash addresses of version 0 use the original
+// Bech32 checksum and every other version uses Bech32m, as in BIP350.
+func EncodeBech32Address(version byte, hash []byte) string {
+	data, _ := convertBits(hash, 8, 5, true)
+
+	return EncodeBech32(AddressHRP(), append([]byte{version}, data...), bech32VariantFor(version))
+}
+
+// decodeBech32Address returns the version byte and hash of a Bech32 address
+// on the current network.
+func decodeBech32Address(address string) (byte, []byte, error) {
+	hrp, data, variant, err := DecodeBech32(address)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if hrp != AddressHRP() {
+		return 0, nil, fmt.Errorf("address is for network %q, not %q", hrp, AddressHRP())
+	}
+	if len(data) == 0 {
+		return 0, nil, fmt.Errorf("address has no version")
+	}
+
+	version := data[0]
+	if variant != bech32VariantFor(version) {
+		return 0, nil, fmt.Errorf("version %d addresses must use %s", version, bech32VariantFor(version))
+	}
+
+	hash, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return version, hash, nil
+}
+
+func bech32VariantFor(version byte) Bech32Variant {
+	if version == PubKeyHashVersion {
+		return Bech32
+	}
+
+	return Bech32m
+}
+
+// isBech32Address reports whether address looks like a Bech32 address of
+// the current network rather than Base58Check.
+func isBech32Address(address string) bool {
+	return strings.HasPrefix(strings.ToLower(address), AddressHRP()+"1")
+}
@@ -0,0 +1,181 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBech32Valid(t *testing.T) {
+	tests := []struct {
+		variant Bech32Variant
+		strings []string
+	}{
+		// BIP173
+		{Bech32, []string{
+			"A12UEL5L",
+			"a12uel5l",
+			"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs",
+			"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+			"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j",
+			"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+			"?1ezyfcl",
+		}},
+		// BIP350
+		{Bech32m, []string{
+			"A1LQFN3A",
+			"a1lqfn3a",
+			"an83characterlonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11sg7hg6",
+			"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx",
+			"11llllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllludsr8",
+			"split1checkupstagehandshakeupstreamerranterredcaperredlc445v",
+			"?1v759aa",
+		}},
+	}
+
+	for _, test := range tests {
+		for _, s := range test.strings {
+			hrp, data, variant, err := DecodeBech32(s)
+			if err != nil {
+				t.Errorf("%s: %v", s, err)
+				continue
+			}
+			if variant != test.variant {
+				t.Errorf("%s decodes as %s, want %s", s, variant, test.variant)
+			}
+
+			// Encoding gives the lowercase form back.
+			if got := EncodeBech32(hrp, data, variant); got != strings.ToLower(s) {
+				t.Errorf("%s encodes back as %s", s, got)
+			}
+		}
+	}
+}
+
+func TestBech32Invalid(t *testing.T) {
+	tests := []struct {
+		s      string
+		reason string
+	}{
+		// BIP173
+		{"\x201nwldj5", "invalid prefix character"},
+		{"\x7f1axkwrx", "invalid prefix character"},
+		{"\x801eym55h", "invalid prefix character"},
+		{"an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx", "longer than"},
+		{"pzry9x0s0muk", "missing separator"},
+		{"1pzry9x0s0muk", "missing separator"},
+		{"x1b4n0q5v", "invalid character"},
+		{"li1dgmt3", "missing separator or checksum"},
+		{"de1lg7wt\xff", "invalid character"},
+		{"A1G7SGD8", "invalid checksum"},
+		{"10a06t8", "missing separator"},
+		{"1qzzfhee", "missing separator"},
+		// BIP350
+		{"\x201xj0phk", "invalid prefix character"},
+		{"\x7f1g6xzxy", "invalid prefix character"},
+		{"\x801vctc34", "invalid prefix character"},
+		{"an84characterslonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11d6pts4", "longer than"},
+		{"qyrz8wqd2c9m", "missing separator"},
+		{"1qyrz8wqd2c9m", "missing separator"},
+		{"y1b0jsk6g", "invalid character"},
+		{"lt1igcx5c0", "invalid character"},
+		{"in1muywd", "missing separator or checksum"},
+		{"mm1crxm3i", "invalid character"},
+		{"au1s5cgom", "invalid character"},
+		{"M1VUXWEZ", "invalid checksum"},
+		{"16plkw9", "missing separator"},
+		{"1p2gdwpf", "missing separator"},
+		// Neither upper nor lower case.
+		{"a12UEL5L", "mixed case"},
+	}
+
+	for _, test := range tests {
+		_, _, _, err := DecodeBech32(test.s)
+
+		var bech32Err *Bech32Error
+		if !errors.As(err, &bech32Err) || !strings.HasPrefix(bech32Err.Reason, test.reason) {
+			t.Errorf("%q: got %v, want %q", test.s, err, test.reason)
+		}
+	}
+}
+
+func TestBech32ErrorPosition(t *testing.T) {
+	valid := EncodeBech32("gb", []byte{0, 14, 20, 15, 7, 13, 26, 0, 25, 18, 6, 11, 13, 8, 21, 4, 20, 3, 17, 2}, Bech32)
+	decode := func(s string) *Bech32Error {
+		t.Helper()
+
+		_, _, _, err := DecodeBech32(s)
+
+		var bech32Err *Bech32Error
+		if !errors.As(err, &bech32Err) {
+			t.Fatalf("%s: got %v, want a Bech32Error", s, err)
+		}
+
+		return bech32Err
+	}
+	replace := func(pos int, c byte) string {
+		s := []byte(valid)
+		s[pos] = c
+		return string(s)
+	}
+
+	// A mistyped character is found from the checksum, in the data and in
+	// the checksum itself.
+	for _, pos := range []int{3, 10, len(valid) - 1} {
+		typo := byte('q')
+		if valid[pos] == typo {
+			typo = 'p'
+		}
+
+		err := decode(replace(pos, typo))
+		if err.Reason != "invalid checksum" || !slices.Equal(err.Positions, []int{pos}) {
+			t.Errorf("typo at %d: got %v", pos, err)
+		}
+		if !strings.Contains(err.Error(), "position") {
+			t.Errorf("typo at %d: %q does not point at the position", pos, err.Error())
+		}
+	}
+
+	// Characters outside the alphabet are all reported.
+	err := decode(replace(12, 'b')[:20] + "i" + valid[21:])
+	if err.Reason != "invalid character" || !slices.Equal(err.Positions, []int{12, 20}) {
+		t.Errorf("characters outside the alphabet: got %v", err)
+	}
+
+	// Two typos are caught, but no single character is to blame.
+	if err := decode(replace(5, 'l')[:8] + "l" + valid[9:]); err.Reason != "invalid checksum" || len(err.Positions) != 0 {
+		t.Errorf("two typos: got %v", err)
+	}
+}
+
+func TestBech32Address(t *testing.T) {
+	hash := PublicKeyHash([]byte("key"))
+
+	for _, version := range []byte{PubKeyHashVersion, ScriptHashVersion} {
+		encoded := EncodeBech32Address(version, hash)
+		if !strings.HasPrefix(encoded, AddressHRP()+"1") {
+			t.Fatalf("%s does not start with the network prefix", encoded)
+		}
+
+		gotVersion, gotHash, err := decodeBech32Address(strings.ToUpper(encoded))
+		if err != nil || gotVersion != version || !bytes.Equal(gotHash, hash) {
+			t.Fatalf("decoding %s = %d, %x, %v", encoded, gotVersion, gotHash, err)
+		}
+
+		// Each version has its own checksum variant.
+		_, data, variant, _ := DecodeBech32(encoded)
+		other := Bech32m
+		if variant == Bech32m {
+			other = Bech32
+		}
+		if _, _, err := decodeBech32Address(EncodeBech32(AddressHRP(), data, other)); err == nil {
+			t.Errorf("version %d address with a %s checksum decodes", version, other)
+		}
+	}
+
+	if _, _, err := decodeBech32Address(EncodeBech32("xx", []byte{0}, Bech32)); err == nil {
+		t.Error("address of another network decodes")
+	}
+}
@@ -0,0 +1,317 @@
+package wallet
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// KeyType identifies the signature scheme of a key. Encoded public keys start
+// with it.
+type KeyType byte
+
+const (
+	KeyP256 KeyType = iota
+	KeySecp256k1
+	KeyEd25519
+	KeySchnorr
+)
+
+var keyTypeNames = map[KeyType]string{
+	KeyP256:      "p256",
+	KeySecp256k1: "secp256k1",
+	KeyEd25519:   "ed25519",
+	KeySchnorr:   "schnorr",
+}
+
+// ParseKeyType returns the key type with the given name. An empty name
+// selects KeyP256.
+func ParseKeyType(name string) (KeyType, error) {
+	if name == "" {
+		return KeyP256, nil
+	}
+
+	for keyType, keyName := range keyTypeNames {
+		if strings.EqualFold(name, keyName) {
+			return keyType, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown key type %q, use p256, secp256k1, ed25519 or schnorr", name)
+}
+
+func (t KeyType) String() string {
+	if name, ok := keyTypeNames[t]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("keytype(%d)", byte(t))
+}
+
+func (t KeyType) curve() elliptic.Curve {
+	switch t {
+	case KeyP256:
+		return elliptic.P256()
+	case KeySecp256k1, KeySchnorr:
+		return Secp256k1()
+	default:
+		return nil
+	}
+}
+
+// Signer is a private key of any supported type.
+type Signer interface {
+	KeyType() KeyType
+	// PublicKey returns the encoded public key, prefixed with the key type.
+	PublicKey() []byte
+	Sign(hash []byte) ([]byte, error)
+}
+
+// GenerateKey creates a new random private key of the given type.
+func GenerateKey(keyType KeyType) (Signer, error) {
+	if keyType == KeyEd25519 {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		return ed25519Signer{key: private}, nil
+	}
+
+	curve := keyType.curve()
+	if curve == nil {
+		return nil, fmt.Errorf("unsupported key type %s", keyType)
+	}
+
+	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyType == KeySchnorr {
+		return schnorrSigner{d: private.D}, nil
+	}
+
+	return ecdsaSigner{keyType: keyType, key: private}, nil
+}
+
+// ParsePrivateKey restores a key saved with MarshalPrivateKey.
+func ParsePrivateKey(keyType KeyType, data []byte) (Signer, error) {
+	if keyType == KeyEd25519 {
+		if len(data) != ed25519.SeedSize {
+			return nil, fmt.Errorf("ed25519 private key must be %d bytes", ed25519.SeedSize)
+		}
+
+		return ed25519Signer{key: ed25519.NewKeyFromSeed(data)}, nil
+	}
+
+	curve := keyType.curve()
+	if curve == nil {
+		return nil, fmt.Errorf("unsupported key type %s", keyType)
+	}
+
+	d := new(big.Int).SetBytes(data)
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, fmt.Errorf("%s private key out of range", keyType)
+	}
+
+	if keyType == KeySchnorr {
+		return schnorrSigner{d: d}, nil
+	}
+
+	private := &ecdsa.PrivateKey{D: d}
+	private.Curve = curve
+	private.X, private.Y = curve.ScalarBaseMult(intToOctets(d, (curve.Params().BitSize+7)/8))
+
+	return ecdsaSigner{keyType: keyType, key: private}, nil
+}
+
+// MarshalPrivateKey returns the raw private key: the scalar of an ECDSA key
+// or the seed of an Ed25519 key.
+func MarshalPrivateKey(signer Signer) []byte {
+	switch key := signer.(type) {
+	case ecdsaSigner:
+		return intToOctets(key.key.D, (key.key.Curve.Params().BitSize+7)/8)
+	case legacyP256Signer:
+		return intToOctets(key.key.D, (key.key.Curve.Params().BitSize+7)/8)
+	case ed25519Signer:
+		return key.key.Seed()
+	case schnorrSigner:
+		return intToOctets(key.d, 32)
+	default:
+		return nil
+	}
+}
+
+// DecodePublicKey parses a public key as returned by Signer.PublicKey. ECDSA
+// keys may be compressed or uncompressed SEC1, Schnorr keys are x-only, and
+// all of them must be points on the curve.
+func DecodePublicKey(data []byte) (KeyType, crypto.PublicKey, error) {
+	if len(data) < 2 {
+		return 0, nil, fmt.Errorf("public key is too short")
+	}
+
+	if x, y, ok := decodeLegacyP256(data); ok {
+		return KeyP256, &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+
+	keyType := KeyType(data[0])
+	key := data[1:]
+
+	if keyType == KeyEd25519 {
+		if len(key) != ed25519.PublicKeySize {
+			return 0, nil, fmt.Errorf("ed25519 public key must be %d bytes", ed25519.PublicKeySize)
+		}
+
+		return keyType, ed25519.PublicKey(key), nil
+	}
+
+	if keyType == KeySchnorr {
+		x := new(big.Int).SetBytes(key)
+		if len(key) != 32 || Secp256k1().(*secp256k1Curve).decompress(x, false) == nil {
+			return 0, nil, fmt.Errorf("schnorr public key is not a point on the curve")
+		}
+
+		return keyType, xOnlyKey(key), nil
+	}
+
+	curve := keyType.curve()
+	if curve == nil {
+		return 0, nil, fmt.Errorf("unsupported key type %d", data[0])
+	}
+
+	x, y, err := decodeSEC1(curve, key)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return keyType, &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// VerifySignature checks sig over hash against an encoded public key, using
+// the scheme given by the key type.
+func VerifySignature(pubKey, hash, sig []byte) bool {
+	_, key, err := DecodePublicKey(pubKey)
+	if err != nil {
+		return false
+	}
+
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		return VerifyECDSA(key, hash, sig)
+	case ed25519.PublicKey:
+		return len(sig) == ed25519.SignatureSize && ed25519.Verify(key, hash, sig)
+	case xOnlyKey:
+		return VerifySchnorr(key, hash, sig)
+	default:
+		return false
+	}
+}
+
+type ecdsaSigner struct {
+	keyType KeyType
+	key     *ecdsa.PrivateKey
+}
+
+func (s ecdsaSigner) KeyType() KeyType {
+	return s.keyType
+}
+
+func (s ecdsaSigner) PublicKey() []byte {
+	encoded := elliptic.MarshalCompressed(s.key.Curve, s.key.X, s.key.Y)
+
+	return append([]byte{byte(s.keyType)}, encoded...)
+}
+
+func (s ecdsaSigner) Sign(hash []byte) ([]byte, error) {
+	return SignECDSA(s.key, hash)
+}
+
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+func (s ed25519Signer) KeyType() KeyType {
+	return KeyEd25519
+}
+
+func (s ed25519Signer) PublicKey() []byte {
+	public := s.key.Public().(ed25519.PublicKey)
+
+	return append([]byte{byte(KeyEd25519)}, public...)
+}
+
+func (s ed25519Signer) Sign(hash []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, hash), nil
+}
+
+// legacyP256Signer is a P256 key from a wallet file written before key types
+// were supported. It keeps the public key encoding of those files, so that
+// the outputs paid to its address can still be spent.
+type legacyP256Signer struct {
+	ecdsaSigner
+}
+
+// PublicKey returns X and Y concatenated, each without leading zero bytes
+// and without a key type in front.
+func (s legacyP256Signer) PublicKey() []byte {
+	return append(s.key.X.Bytes(), s.key.Y.Bytes()...)
+}
+
+// decodeLegacyP256 parses a public key of a legacyP256Signer. Typed keys are
+// 33, 34 or 66 bytes long, so the lengths cannot be confused; where the
+// coordinates split is found by trying, as either may be short.
+func decodeLegacyP256(data []byte) (*big.Int, *big.Int, bool) {
+	if len(data) <= 34 || len(data) > 64 || data[0] == 0 {
+		return nil, nil, false
+	}
+
+	curve := elliptic.P256()
+	for xLen := len(data) - 32; xLen <= 32; xLen++ {
+		if data[xLen] == 0 {
+			continue
+		}
+
+		x := new(big.Int).SetBytes(data[:xLen])
+		y := new(big.Int).SetBytes(data[xLen:])
+		if curve.IsOnCurve(x, y) {
+			return x, y, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+func decodeSEC1(curve elliptic.Curve, data []byte) (*big.Int, *big.Int, error) {
+	size := (curve.Params().BitSize + 7) / 8
+
+	var x, y *big.Int
+	switch {
+	case len(data) == 1+size && (data[0] == 0x02 || data[0] == 0x03):
+		x = new(big.Int).SetBytes(data[1:])
+		if k1, ok := curve.(*secp256k1Curve); ok {
+			y = k1.decompress(x, data[0] == 0x03)
+		} else {
+			x, y = elliptic.UnmarshalCompressed(curve, data)
+		}
+	case len(data) == 1+2*size && data[0] == 0x04:
+		x = new(big.Int).SetBytes(data[1 : 1+size])
+		y = new(big.Int).SetBytes(data[1+size:])
+		if !curve.IsOnCurve(x, y) {
+			y = nil
+		}
+	default:
+		return nil, nil, fmt.Errorf("public key is not SEC1 encoded")
+	}
+
+	if x == nil || y == nil {
+		return nil, nil, fmt.Errorf("public key is not a point on the curve")
+	}
+
+	return x, y, nil
+}
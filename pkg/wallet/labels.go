@@ -0,0 +1,136 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SetLabel names one of the wallet's own addresses: a key, multisig script
+// or aggregated key address. An empty label removes the current one.
+func (w *Wallets) SetLabel(address, label string) error {
+	parsed, err := ParseAddress(address)
+	if err != nil {
+		return err
+	}
+
+	address = parsed.String()
+	if !w.IsOwnAddress(address) {
+		return fmt.Errorf("%s is not an address of this wallet", address)
+	}
+
+	label = strings.TrimSpace(label)
+	if label == "" {
+		delete(w.Labels, address)
+		return nil
+	}
+
+	if err := w.checkLabel(label, address); err != nil {
+		return err
+	}
+
+	w.Labels[address] = label
+
+	return nil
+}
+
+// Label returns the label of an own address, or an empty string.
+func (w *Wallets) Label(address string) string {
+	return w.Labels[canonicalAddress(address)]
+}
+
+// AddContact stores address in the address book under label, replacing the
+// address of an existing contact with the same label.
+func (w *Wallets) AddContact(label, address string) error {
+	parsed, err := ParseAddress(address)
+	if err != nil {
+		return err
+	}
+
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return fmt.Errorf("contact label is empty")
+	}
+	if err := w.checkLabel(label, ""); err != nil {
+		return err
+	}
+
+	w.Contacts[label] = parsed.String()
+
+	return nil
+}
+
+func (w *Wallets) RemoveContact(label string) error {
+	if _, ok := w.Contacts[label]; !ok {
+		return fmt.Errorf("no contact is labeled %q", label)
+	}
+
+	delete(w.Contacts, label)
+
+	return nil
+}
+
+// ContactLabels returns the labels in the address book in sorted order.
+func (w *Wallets) ContactLabels() []string {
+	labels := []string{}
+
+	for label := range w.Contacts {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	return labels
+}
+
+// ResolveAddress parses name as an address, or else looks it up as the
+// label of an own address or of a contact.
+func (w *Wallets) ResolveAddress(name string) (Address, error) {
+	parsed, err := ParseAddress(name)
+	if err == nil {
+		return parsed, nil
+	}
+
+	for address, label := range w.Labels {
+		if label == name {
+			return ParseAddress(address)
+		}
+	}
+	if address, ok := w.Contacts[name]; ok {
+		return ParseAddress(address)
+	}
+
+	return Address{}, fmt.Errorf("%q is not a known label and not a valid address: %w", name, err)
+}
+
+// checkLabel makes sure label cannot be mistaken for an address and is not
+// already used, other than by own address.
+func (w *Wallets) checkLabel(label, own string) error {
+	if _, err := ParseAddress(label); err == nil {
+		return fmt.Errorf("label %q is an address", label)
+	}
+
+	for address, existing := range w.Labels {
+		if existing == label && address != own {
+			return fmt.Errorf("label %q is already used for %s", label, address)
+		}
+	}
+	if address, ok := w.Contacts[label]; ok && own != "" {
+		return fmt.Errorf("label %q is already used by the contact %s", label, address)
+	}
+
+	return nil
+}
+
+// IsOwnAddress reports whether address is one of the wallet's keys, scripts
+// or aggregated keys, given in its Base58Check form.
+func (w *Wallets) IsOwnAddress(address string) bool {
+	if _, ok := w.Wallets[address]; ok {
+		return true
+	}
+	if _, ok := w.Scripts[address]; ok {
+		return true
+	}
+	_, ok := w.Aggregates[address]
+
+	return ok
+}
@@ -0,0 +1,167 @@
+package wallet
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newTestWallets() *Wallets {
+	return &Wallets{
+		Wallets:    map[string]*Wallet{},
+		Scripts:    map[string][]byte{},
+		Aggregates: map[string][][]byte{},
+		Labels:     map[string]string{},
+		Contacts:   map[string]string{},
+	}
+}
+
+// foreignAddress returns the address of a key outside the wallet.
+func foreignAddress() string {
+	return string(NewWallet(KeyP256).Address())
+}
+
+func TestLabels(t *testing.T) {
+	wallets := newTestWallets()
+	own := wallets.AddWallet(KeyP256)
+	other := wallets.AddWallet(KeyP256)
+	stranger := foreignAddress()
+
+	if err := wallets.SetLabel(own, " savings "); err != nil {
+		t.Fatal(err)
+	}
+	if got := wallets.Label(own); got != "savings" {
+		t.Fatalf("Label() = %q, want savings", got)
+	}
+
+	// Labels are found by the Bech32 form as well.
+	parsed, err := ParseAddress(own)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wallets.Label(parsed.Bech32()); got != "savings" {
+		t.Fatalf("Label() of the Bech32 form = %q, want savings", got)
+	}
+
+	if err := wallets.SetLabel(other, "savings"); err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Fatalf("second use of a label: got %v", err)
+	}
+	if err := wallets.SetLabel(stranger, "theirs"); err == nil || !strings.Contains(err.Error(), "not an address of this wallet") {
+		t.Fatalf("labeling another wallet's address: got %v", err)
+	}
+	if err := wallets.SetLabel(other, other); err == nil || !strings.Contains(err.Error(), "is an address") {
+		t.Fatalf("address as a label: got %v", err)
+	}
+
+	// Relabeling replaces the label and an empty one removes it.
+	if err := wallets.SetLabel(own, "spending"); err != nil {
+		t.Fatal(err)
+	}
+	if got := wallets.Label(own); got != "spending" {
+		t.Fatalf("Label() after relabeling = %q, want spending", got)
+	}
+	if err := wallets.SetLabel(own, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := wallets.Label(own); got != "" {
+		t.Fatalf("Label() after removing = %q, want none", got)
+	}
+}
+
+func TestContacts(t *testing.T) {
+	wallets := newTestWallets()
+	own := wallets.AddWallet(KeyP256)
+	alice, bob := foreignAddress(), foreignAddress()
+
+	if err := wallets.SetLabel(own, "me"); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseAddress(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.AddContact("alice", parsed.Bech32()); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.AddContact("bob", bob); err != nil {
+		t.Fatal(err)
+	}
+	if got := wallets.Contacts["alice"]; got != alice {
+		t.Fatalf("contact stored as %s, want the Base58Check form %s", got, alice)
+	}
+	if got := wallets.ContactLabels(); !slices.Equal(got, []string{"alice", "bob"}) {
+		t.Fatalf("ContactLabels() = %v", got)
+	}
+
+	tests := []struct {
+		label   string
+		address string
+		want    string
+	}{
+		{"", alice, "label is empty"},
+		{"me", alice, "already used"},
+		{"carol", "nonsense", "invalid address"},
+		{bob, alice, "is an address"},
+	}
+	for _, test := range tests {
+		err := wallets.AddContact(test.label, test.address)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("AddContact(%q, %q) = %v, want an error containing %q", test.label, test.address, err, test.want)
+		}
+	}
+
+	// A contact's address can be replaced under the same label.
+	if err := wallets.AddContact("bob", alice); err != nil || wallets.Contacts["bob"] != alice {
+		t.Fatalf("replacing bob's address: %v", err)
+	}
+
+	if err := wallets.RemoveContact("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if got := wallets.ContactLabels(); !slices.Equal(got, []string{"alice"}) {
+		t.Fatalf("ContactLabels() after removing bob = %v", got)
+	}
+	if err := wallets.RemoveContact("bob"); err == nil || !strings.Contains(err.Error(), `no contact is labeled "bob"`) {
+		t.Fatalf("removing bob again: got %v", err)
+	}
+}
+
+func TestResolveAddress(t *testing.T) {
+	wallets := newTestWallets()
+	own := wallets.AddWallet(KeyP256)
+	alice := foreignAddress()
+
+	if err := wallets.SetLabel(own, "me"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.AddContact("alice", alice); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseAddress(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"me":            own,
+		"alice":         alice,
+		alice:           alice,
+		parsed.Bech32(): alice,
+	} {
+		address, err := wallets.ResolveAddress(name)
+		if err != nil {
+			t.Errorf("ResolveAddress(%q): %v", name, err)
+			continue
+		}
+		if address.String() != want {
+			t.Errorf("ResolveAddress(%q) = %s, want %s", name, address, want)
+		}
+	}
+
+	_, err = wallets.ResolveAddress("carol")
+	if err == nil || !strings.Contains(err.Error(), `"carol" is not a known label and not a valid address`) {
+		t.Fatalf("ResolveAddress of an unknown label: got %v", err)
+	}
+}
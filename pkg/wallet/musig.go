@@ -0,0 +1,399 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// Key aggregation and two round signing follow MuSig2: every signer
+// publishes two nonce points, then a partial signature, and the partial
+// signatures add up to a BIP340 signature for the aggregated key. On chain
+// the result is indistinguishable from a single key spend.
+
+// keyAggContext holds the aggregated key Q = sum(a_i * P_i) of a key list.
+type keyAggContext struct {
+	xs     [][]byte
+	points [][2]*big.Int
+	coefs  []*big.Int
+	qx, qy *big.Int
+}
+
+func newKeyAggContext(pubKeys [][]byte) (*keyAggContext, error) {
+	curve := Secp256k1().(*secp256k1Curve)
+
+	if len(pubKeys) == 0 {
+		return nil, fmt.Errorf("no public keys to aggregate")
+	}
+
+	ctx := &keyAggContext{
+		xs:     [][]byte{},
+		points: [][2]*big.Int{},
+		coefs:  []*big.Int{},
+		qx:     new(big.Int),
+		qy:     new(big.Int),
+	}
+
+	for idx, pubKey := range pubKeys {
+		keyType, key, err := DecodePublicKey(pubKey)
+		if err != nil {
+			return nil, fmt.Errorf("public key %d: %w", idx+1, err)
+		}
+		if keyType != KeySchnorr {
+			return nil, fmt.Errorf("public key %d is a %s key, aggregation needs schnorr keys", idx+1, keyType)
+		}
+
+		x := new(big.Int).SetBytes(key.(xOnlyKey))
+		ctx.xs = append(ctx.xs, key.(xOnlyKey))
+		ctx.points = append(ctx.points, [2]*big.Int{x, curve.decompress(x, false)})
+	}
+
+	list := taggedHash("KeyAgg list", ctx.xs...)
+
+	for idx, pt := range ctx.points {
+		coef := new(big.Int).SetBytes(taggedHash("KeyAgg coefficient", list, ctx.xs[idx]))
+		coef.Mod(coef, curve.Params().N)
+		ctx.coefs = append(ctx.coefs, coef)
+
+		x, y := curve.ScalarMult(pt[0], pt[1], coef.Bytes())
+		ctx.qx, ctx.qy = curve.Add(ctx.qx, ctx.qy, x, y)
+	}
+
+	if ctx.qx.Sign() == 0 && ctx.qy.Sign() == 0 {
+		return nil, fmt.Errorf("aggregated key is the point at infinity")
+	}
+
+	return ctx, nil
+}
+
+// oddKey reports whether Q has an odd y coordinate, in which case signers
+// negate their keys so that the x-only key stands for Q.
+func (ctx *keyAggContext) oddKey() bool {
+	return ctx.qy.Bit(0) == 1
+}
+
+func (ctx *keyAggContext) index(pubKey []byte) int {
+	for idx, x := range ctx.xs {
+		if len(pubKey) == 33 && bytes.Equal(pubKey[1:], x) {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// AggregatePublicKeys combines Schnorr public keys into a single Schnorr key
+// that can only sign with the cooperation of all of them. Every signer must
+// use the keys in the same order.
+func AggregatePublicKeys(pubKeys [][]byte) ([]byte, error) {
+	ctx, err := newKeyAggContext(pubKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{byte(KeySchnorr)}, intToOctets(ctx.qx, 32)...), nil
+}
+
+// MuSigSession is one signer's state while signing hash with an aggregated
+// key. A session signs once; its secret nonces are discarded afterwards.
+type MuSigSession struct {
+	ctx   *keyAggContext
+	index int
+	d     *big.Int
+	hash  []byte
+	k1    *big.Int
+	k2    *big.Int
+	nonce []byte
+}
+
+// NewMuSigSession starts signing hash with signer, one of pubKeys.
+func NewMuSigSession(signer Signer, pubKeys [][]byte, hash []byte) (*MuSigSession, error) {
+	key, ok := signer.(schnorrSigner)
+	if !ok {
+		return nil, fmt.Errorf("aggregated signing needs a schnorr key")
+	}
+
+	ctx, err := newKeyAggContext(pubKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+
+	n := Secp256k1().Params().N
+
+	secret := []byte{}
+	for i := byte(0); i < 2; i++ {
+		k := new(big.Int).SetBytes(taggedHash("MuSig/nonce", seed, intToOctets(key.d, 32), intToOctets(ctx.qx, 32), hash, []byte{i}))
+		k.Mod(k, n)
+		secret = append(secret, intToOctets(k, 32)...)
+	}
+
+	return newMuSigSession(key, ctx, hash, secret)
+}
+
+// ResumeMuSigSession continues a session started in an earlier process from
+// the secret nonce it returned. The secret nonce must never be resumed twice.
+func ResumeMuSigSession(signer Signer, pubKeys [][]byte, hash []byte, secretNonce []byte) (*MuSigSession, error) {
+	key, ok := signer.(schnorrSigner)
+	if !ok {
+		return nil, fmt.Errorf("aggregated signing needs a schnorr key")
+	}
+
+	ctx, err := newKeyAggContext(pubKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMuSigSession(key, ctx, hash, secretNonce)
+}
+
+func newMuSigSession(key schnorrSigner, ctx *keyAggContext, hash []byte, secretNonce []byte) (*MuSigSession, error) {
+	index := ctx.index(key.PublicKey())
+	if index < 0 {
+		return nil, fmt.Errorf("signer is not one of the aggregated keys")
+	}
+	if len(secretNonce) != 64 {
+		return nil, fmt.Errorf("invalid musig secret nonce length %d", len(secretNonce))
+	}
+
+	curve := Secp256k1().(*secp256k1Curve)
+
+	session := &MuSigSession{
+		ctx:   ctx,
+		index: index,
+		d:     key.d,
+		hash:  hash,
+	}
+
+	nonces := []*big.Int{}
+	for i := 0; i < 2; i++ {
+		k := new(big.Int).SetBytes(secretNonce[i*32 : (i+1)*32])
+		if k.Sign() == 0 || k.Cmp(curve.Params().N) >= 0 {
+			return nil, fmt.Errorf("invalid musig secret nonce")
+		}
+		nonces = append(nonces, k)
+
+		x, y := curve.ScalarBaseMult(k.Bytes())
+		session.nonce = append(session.nonce, encodePoint(x, y)...)
+	}
+	session.k1, session.k2 = nonces[0], nonces[1]
+
+	return session, nil
+}
+
+// PublicNonce returns the nonce to send to the other signers in the first
+// round.
+func (s *MuSigSession) PublicNonce() []byte {
+	return s.nonce
+}
+
+// SecretNonce returns the private half of the session's nonce, for signers
+// that send their public nonce and sign in separate runs. It must be kept as
+// secret as the signing key.
+func (s *MuSigSession) SecretNonce() []byte {
+	if s.k1 == nil {
+		return nil
+	}
+
+	return append(intToOctets(s.k1, 32), intToOctets(s.k2, 32)...)
+}
+
+// Sign returns this signer's partial signature, given the public nonces of
+// all signers in key order.
+func (s *MuSigSession) Sign(nonces [][]byte) ([]byte, error) {
+	if s.k1 == nil {
+		return nil, fmt.Errorf("musig session has already signed")
+	}
+	if len(nonces) != len(s.ctx.xs) || !bytes.Equal(nonces[s.index], s.nonce) {
+		return nil, fmt.Errorf("nonces do not match the signing session")
+	}
+
+	curve := Secp256k1().(*secp256k1Curve)
+	n := curve.Params().N
+
+	round, err := newSigningRound(s.ctx, s.hash, nonces)
+	if err != nil {
+		return nil, err
+	}
+
+	k1, k2 := s.k1, s.k2
+	s.k1, s.k2 = nil, nil
+
+	if round.oddNonce {
+		k1 = new(big.Int).Sub(n, k1)
+		k2 = new(big.Int).Sub(n, k2)
+	}
+
+	_, py := curve.ScalarBaseMult(s.d.Bytes())
+	d := evenScalar(s.d, py, n)
+	if s.ctx.oddKey() {
+		d = new(big.Int).Sub(n, d)
+	}
+
+	partial := new(big.Int).Mul(round.e, s.ctx.coefs[s.index])
+	partial.Mul(partial, d)
+	partial.Add(partial, k1)
+	partial.Add(partial, new(big.Int).Mul(round.b, k2))
+	partial.Mod(partial, n)
+
+	return intToOctets(partial, 32), nil
+}
+
+// AggregateMuSig checks the partial signatures of all signers and combines
+// them into a BIP340 signature for the aggregated key.
+func AggregateMuSig(pubKeys [][]byte, hash []byte, nonces, partials [][]byte) ([]byte, error) {
+	ctx, err := newKeyAggContext(pubKeys)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonces) != len(pubKeys) || len(partials) != len(pubKeys) {
+		return nil, fmt.Errorf("need a nonce and partial signature from each of %d signers", len(pubKeys))
+	}
+
+	round, err := newSigningRound(ctx, hash, nonces)
+	if err != nil {
+		return nil, err
+	}
+
+	curve := Secp256k1().(*secp256k1Curve)
+	n := curve.Params().N
+	s := new(big.Int)
+
+	for idx, partial := range partials {
+		si := new(big.Int).SetBytes(partial)
+		if len(partial) != 32 || si.Cmp(n) >= 0 {
+			return nil, fmt.Errorf("partial signature %d is malformed", idx+1)
+		}
+		if !round.verifyPartial(ctx, idx, nonces[idx], si) {
+			return nil, fmt.Errorf("partial signature %d is invalid", idx+1)
+		}
+
+		s.Add(s, si)
+	}
+	s.Mod(s, n)
+
+	sig := append(intToOctets(round.rx, 32), intToOctets(s, 32)...)
+	if !VerifySchnorr(intToOctets(ctx.qx, 32), hash, sig) {
+		return nil, fmt.Errorf("aggregated signature does not verify")
+	}
+
+	return sig, nil
+}
+
+// signingRound holds the values every signer derives from the collected
+// nonces: the nonce coefficient b, the final nonce R and the challenge e.
+type signingRound struct {
+	b        *big.Int
+	e        *big.Int
+	rx       *big.Int
+	oddNonce bool
+}
+
+func newSigningRound(ctx *keyAggContext, hash []byte, nonces [][]byte) (*signingRound, error) {
+	curve := Secp256k1().(*secp256k1Curve)
+	n := curve.Params().N
+
+	r1x, r1y := new(big.Int), new(big.Int)
+	r2x, r2y := new(big.Int), new(big.Int)
+
+	for idx, nonce := range nonces {
+		pts, err := decodeNonce(nonce)
+		if err != nil {
+			return nil, fmt.Errorf("nonce %d: %w", idx+1, err)
+		}
+
+		r1x, r1y = curve.Add(r1x, r1y, pts[0][0], pts[0][1])
+		r2x, r2y = curve.Add(r2x, r2y, pts[1][0], pts[1][1])
+	}
+
+	b := new(big.Int).SetBytes(taggedHash("MuSig/noncecoef", encodePoint(r1x, r1y), encodePoint(r2x, r2y), intToOctets(ctx.qx, 32), hash))
+	b.Mod(b, n)
+
+	bx, by := curve.ScalarMult(r2x, r2y, b.Bytes())
+	rx, ry := curve.Add(r1x, r1y, bx, by)
+	if rx.Sign() == 0 && ry.Sign() == 0 {
+		rx, ry = curve.Params().Gx, curve.Params().Gy
+	}
+
+	return &signingRound{
+		b:        b,
+		e:        schnorrChallenge(rx, ctx.qx, hash, n),
+		rx:       rx,
+		oddNonce: ry.Bit(0) == 1,
+	}, nil
+}
+
+// verifyPartial checks s_i*G == R1_i + b*R2_i + e*a_i*P_i, with the same
+// negations the signer applied.
+func (r *signingRound) verifyPartial(ctx *keyAggContext, idx int, nonce []byte, si *big.Int) bool {
+	curve := Secp256k1().(*secp256k1Curve)
+	n := curve.Params().N
+
+	pts, err := decodeNonce(nonce)
+	if err != nil {
+		return false
+	}
+
+	bx, by := curve.ScalarMult(pts[1][0], pts[1][1], r.b.Bytes())
+	rx, ry := curve.Add(pts[0][0], pts[0][1], bx, by)
+	if r.oddNonce {
+		ry = negateY(curve, ry)
+	}
+
+	pt := ctx.points[idx]
+	px, py := pt[0], pt[1]
+	if ctx.oddKey() {
+		py = negateY(curve, py)
+	}
+
+	scalar := new(big.Int).Mul(r.e, ctx.coefs[idx])
+	scalar.Mod(scalar, n)
+	ex, ey := curve.ScalarMult(px, py, scalar.Bytes())
+	wantX, wantY := curve.Add(rx, ry, ex, ey)
+
+	gotX, gotY := curve.ScalarBaseMult(si.Bytes())
+
+	return gotX.Cmp(wantX) == 0 && gotY.Cmp(wantY) == 0
+}
+
+func decodeNonce(nonce []byte) ([2][2]*big.Int, error) {
+	var pts [2][2]*big.Int
+
+	if len(nonce) != 66 {
+		return pts, fmt.Errorf("nonce must be 66 bytes")
+	}
+
+	for i := 0; i < 2; i++ {
+		x, y, err := decodeSEC1(Secp256k1(), nonce[i*33:(i+1)*33])
+		if err != nil {
+			return pts, err
+		}
+		pts[i] = [2]*big.Int{x, y}
+	}
+
+	return pts, nil
+}
+
+// encodePoint returns the compressed point, or 33 zero bytes for the point
+// at infinity.
+func encodePoint(x, y *big.Int) []byte {
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return make([]byte, 33)
+	}
+
+	return elliptic.MarshalCompressed(Secp256k1(), x, y)
+}
+
+func negateY(curve *secp256k1Curve, y *big.Int) *big.Int {
+	if y.Sign() == 0 {
+		return y
+	}
+
+	return new(big.Int).Sub(curve.Params().P, y)
+}
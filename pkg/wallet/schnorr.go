@@ -0,0 +1,141 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// xOnlyKey is a BIP340 public key: the x coordinate of a secp256k1 point
+// with an even y coordinate.
+type xOnlyKey []byte
+
+// SignSchnorr produces a BIP340 Schnorr signature of hash with the secp256k1
+// private key d.
+func SignSchnorr(d *big.Int, hash []byte) ([]byte, error) {
+	aux := make([]byte, 32)
+	if _, err := rand.Read(aux); err != nil {
+		return nil, err
+	}
+
+	return signSchnorr(d, hash, aux)
+}
+
+func signSchnorr(d *big.Int, hash, aux []byte) ([]byte, error) {
+	curve := Secp256k1().(*secp256k1Curve)
+	n := curve.Params().N
+
+	if d.Sign() == 0 || d.Cmp(n) >= 0 {
+		return nil, fmt.Errorf("schnorr private key out of range")
+	}
+
+	px, py := curve.ScalarBaseMult(d.Bytes())
+	d = evenScalar(d, py, n)
+
+	t := intToOctets(d, 32)
+	auxHash := taggedHash("BIP0340/aux", aux)
+	for i := range t {
+		t[i] ^= auxHash[i]
+	}
+
+	k := new(big.Int).SetBytes(taggedHash("BIP0340/nonce", t, intToOctets(px, 32), hash))
+	k.Mod(k, n)
+	if k.Sign() == 0 {
+		return nil, fmt.Errorf("schnorr nonce is zero")
+	}
+
+	rx, ry := curve.ScalarBaseMult(k.Bytes())
+	k = evenScalar(k, ry, n)
+
+	e := schnorrChallenge(rx, px, hash, n)
+
+	s := new(big.Int).Mul(e, d)
+	s.Add(s, k)
+	s.Mod(s, n)
+
+	return append(intToOctets(rx, 32), intToOctets(s, 32)...), nil
+}
+
+// VerifySchnorr checks a BIP340 signature of hash against an x-only public
+// key.
+func VerifySchnorr(pubKey, hash, sig []byte) bool {
+	curve := Secp256k1().(*secp256k1Curve)
+	n := curve.Params().N
+
+	if len(pubKey) != 32 || len(sig) != 64 {
+		return false
+	}
+
+	px := new(big.Int).SetBytes(pubKey)
+	py := curve.decompress(px, false)
+	if py == nil {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if r.Cmp(curve.Params().P) >= 0 || s.Cmp(n) >= 0 {
+		return false
+	}
+
+	e := schnorrChallenge(r, px, hash, n)
+	negE := new(big.Int).Sub(n, e)
+
+	sx, sy := curve.ScalarBaseMult(s.Bytes())
+	ex, ey := curve.ScalarMult(px, py, negE.Bytes())
+	rx, ry := curve.Add(sx, sy, ex, ey)
+
+	if rx.Sign() == 0 && ry.Sign() == 0 {
+		return false
+	}
+
+	return ry.Bit(0) == 0 && rx.Cmp(r) == 0
+}
+
+func schnorrChallenge(rx, px *big.Int, hash []byte, n *big.Int) *big.Int {
+	e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", intToOctets(rx, 32), intToOctets(px, 32), hash))
+
+	return e.Mod(e, n)
+}
+
+// evenScalar returns k, or n-k when the point kG has an odd y coordinate, so
+// that the point used is always the even one.
+func evenScalar(k, y, n *big.Int) *big.Int {
+	if y.Bit(0) == 1 {
+		return new(big.Int).Sub(n, k)
+	}
+
+	return k
+}
+
+func taggedHash(tag string, parts ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+
+	hasher := sha256.New()
+	hasher.Write(tagHash[:])
+	hasher.Write(tagHash[:])
+	for _, part := range parts {
+		hasher.Write(part)
+	}
+
+	return hasher.Sum(nil)
+}
+
+type schnorrSigner struct {
+	d *big.Int
+}
+
+func (s schnorrSigner) KeyType() KeyType {
+	return KeySchnorr
+}
+
+func (s schnorrSigner) PublicKey() []byte {
+	x, _ := Secp256k1().ScalarBaseMult(s.d.Bytes())
+
+	return append([]byte{byte(KeySchnorr)}, intToOctets(x, 32)...)
+}
+
+func (s schnorrSigner) Sign(hash []byte) ([]byte, error) {
+	return SignSchnorr(s.d, hash)
+}
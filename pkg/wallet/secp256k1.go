@@ -0,0 +1,44 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// secp256k1Curve implements elliptic.Curve for y² = x³ + 7. The generic
+// CurveParams methods assume a = -3, so the point arithmetic is left to the
+// dcrd secp256k1 package.
+//
+// That arithmetic is not constant time: like crypto/elliptic's generic
+// curves, scalar multiplication takes longer or shorter depending on the
+// scalar, which leaks information about private keys and nonces to anyone
+// able to time many signatures precisely.
+type secp256k1Curve struct {
+	*secp256k1.KoblitzCurve
+}
+
+var secp256k1Instance = &secp256k1Curve{KoblitzCurve: secp256k1.S256()}
+
+// Secp256k1 returns the curve used by Bitcoin and Ethereum keys.
+func Secp256k1() elliptic.Curve {
+	return secp256k1Instance
+}
+
+// decompress returns the y coordinate of x with the given parity, or nil when
+// x is not on the curve.
+func (c *secp256k1Curve) decompress(x *big.Int, odd bool) *big.Int {
+	if x.Sign() < 0 || x.Cmp(c.Params().P) >= 0 {
+		return nil
+	}
+
+	var fx, fy secp256k1.FieldVal
+	fx.SetByteSlice(x.Bytes())
+	if !secp256k1.DecompressY(&fx, odd, &fy) {
+		return nil
+	}
+	fy.Normalize()
+
+	return new(big.Int).SetBytes(fy.Bytes()[:])
+}
@@ -0,0 +1,233 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestSecp256k1ScalarBaseMult(t *testing.T) {
+	tests := []struct {
+		k string
+		x string
+		y string
+	}{
+		{
+			k: "1",
+			x: "79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798",
+			y: "483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8",
+		},
+		{
+			k: "2",
+			x: "C6047F9441ED7D6D3045406E95C07CD85C778E4B8CEF3CA7ABAC09B95C709EE5",
+			y: "1AE168FEA63DC339A3C58419466CEAEEF7F632653266D0E1236431A950CFE52A",
+		},
+		{
+			k: "3",
+			x: "F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+			y: "388F7B0F632DE8140FE337E62A37F3566500A99934C2231B6CB9FD7584B8E672",
+		},
+		{
+			// n - 1 is -G.
+			k: "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364140",
+			x: "79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798",
+			y: "B7C52588D95C3B9AA25B0403F1EEF75702E84BB7597AABE663B82F6F04EF2777",
+		},
+	}
+
+	curve := Secp256k1()
+	for _, test := range tests {
+		k := hexInt(t, test.k)
+		x, y := curve.ScalarBaseMult(k.Bytes())
+		if x.Cmp(hexInt(t, test.x)) != 0 || y.Cmp(hexInt(t, test.y)) != 0 {
+			t.Errorf("%s*G = (%X, %X), want (%s, %s)", test.k, x, y, test.x, test.y)
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			t.Errorf("%s*G is not on the curve", test.k)
+		}
+
+		if got := curve.(*secp256k1Curve).decompress(x, y.Bit(0) == 1); got == nil || got.Cmp(y) != 0 {
+			t.Errorf("decompressing %s*G = %X, want %X", test.k, got, y)
+		}
+	}
+
+	n := curve.Params().N
+	if x, y := curve.ScalarBaseMult(n.Bytes()); x.Sign() != 0 || y.Sign() != 0 {
+		t.Errorf("n*G = (%X, %X), want the point at infinity", x, y)
+	}
+
+	// x = 5 has no point on the curve.
+	if y := curve.(*secp256k1Curve).decompress(big.NewInt(5), false); y != nil {
+		t.Errorf("decompressing x = 5 = %X, want no point", y)
+	}
+}
+
+// The test vectors of BIP340.
+var bip340Vectors = []struct {
+	secretKey string
+	publicKey string
+	aux       string
+	message   string
+	signature string
+	valid     bool
+}{
+	{
+		secretKey: "0000000000000000000000000000000000000000000000000000000000000003",
+		publicKey: "F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+		aux:       "0000000000000000000000000000000000000000000000000000000000000000",
+		message:   "0000000000000000000000000000000000000000000000000000000000000000",
+		signature: "E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0",
+		valid:     true,
+	},
+	{
+		secretKey: "B7E151628AED2A6ABF7158809CF4F3C762E7160F38B4DA56A784D9045190CFEF",
+		publicKey: "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+		aux:       "0000000000000000000000000000000000000000000000000000000000000001",
+		message:   "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+		signature: "6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A",
+		valid:     true,
+	},
+	{
+		secretKey: "C90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B14E5C9",
+		publicKey: "DD308AFEC5777E13121FA72B9CC1B7CC0139715309B086C960E18FD969774EB8",
+		aux:       "C87AA53824B4D7AE2EB035A2B5BBBCCC080E76CDC6D1692C4B0B62D798E6D906",
+		message:   "7E2D58D8B3BCDF1ABADEC7829054F90DDA9805AAB56C77333024B9D0A508B75C",
+		signature: "5831AAEED7B44BB74E5EAB94BA9D4294C49BCF2A60728D8B4C200F50DD313C1BAB745879A5AD954A72C45A91C3A51D3C7ADEA98D82F8481E0E1E03674A6F3FB7",
+		valid:     true,
+	},
+	{
+		secretKey: "0B432B2677937381AEF05BB02A66ECD012773062CF3FA2549E44F58ED2401710",
+		publicKey: "25D1DFF95105F5253C4022F628A996AD3A0D95FBF21D468A1B33F8C160D8F517",
+		aux:       "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF",
+		message:   "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF",
+		signature: "7EB0509757E246F19449885651611CB965ECC1A187DD51B64FDA1EDC9637D5EC97582B9CB13DB3933705B32BA982AF5AF25FD78881EBB32771FC5922EFC66EA3",
+		valid:     true,
+	},
+	{
+		publicKey: "D69C3509BB99E412E68B0FE8544E72837DFA30746D8BE2AA65975F29D22DC7B9",
+		message:   "4DF3C3F68FCC83B27E9D42C90431A72499F17875C81A599B566C9889B9696703",
+		signature: "00000000000000000000003B78CE563F89A0ED9414F5AA28AD0D96D6795F9C6376AFB1548AF603B3EB45C9F8207DEE1060CB71C04E80F593060B07D28308D7F4",
+		valid:     true,
+	},
+	{
+		// The public key is not on the curve.
+		publicKey: "EEFDEA4CDB677750A420FEE807EACF21EB9898AE79B9768766E4FAA04A2D4A34",
+		message:   "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+		signature: "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E17776969E89B4C5564D00349106B8497785DD7D1D713A8AE82B32FA79D5F7FC407D39B",
+	},
+	{
+		// R has an odd y coordinate.
+		publicKey: "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+		message:   "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+		signature: "FFF97BD5755EEEA420453A14355235D382F6472F8568A18B2F057A14602975563CC27944640AC607CD107AE10923D9EF7A73C643E166BE5EBEAFA34B1AC553E2",
+	},
+	{
+		// The message is negated.
+		publicKey: "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+		message:   "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+		signature: "1FA62E331EDBC21C394792D2AB1100A7B432B013DF3F6FF4F99FCB33E0E1515F28890B3EDB6E7189B630448B515CE4F8622A954CFE545735AAEA5134FCCDB2BD",
+	},
+	{
+		// s is negated.
+		publicKey: "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+		message:   "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+		signature: "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E177769961764B3AA9B2FFCB6EF947B6887A226E8D7C93E00C5ED0C1834FF0D0C2E6DA6",
+	},
+	{
+		// r is the field size.
+		publicKey: "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+		message:   "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+		signature: "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F69E89B4C5564D00349106B8497785DD7D1D713A8AE82B32FA79D5F7FC407D39B",
+	},
+	{
+		// s is the curve order.
+		publicKey: "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+		message:   "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+		signature: "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E177769FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141",
+	},
+	{
+		// The public key is the field size.
+		publicKey: "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC30",
+		message:   "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+		signature: "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E17776969E89B4C5564D00349106B8497785DD7D1D713A8AE82B32FA79D5F7FC407D39B",
+	},
+}
+
+func TestSchnorrBIP340Vectors(t *testing.T) {
+	for idx, test := range bip340Vectors {
+		publicKey := decodeHex(t, test.publicKey)
+		message := decodeHex(t, test.message)
+		signature := decodeHex(t, test.signature)
+
+		if test.secretKey != "" {
+			d := hexInt(t, test.secretKey)
+			sig, err := signSchnorr(d, message, decodeHex(t, test.aux))
+			if err != nil {
+				t.Fatalf("vector %d: %v", idx, err)
+			}
+			if !bytes.Equal(sig, signature) {
+				t.Errorf("vector %d: signature = %X, want %s", idx, sig, test.signature)
+			}
+
+			signer, err := ParsePrivateKey(KeySchnorr, intToOctets(d, 32))
+			if err != nil {
+				t.Fatalf("vector %d: %v", idx, err)
+			}
+			if got := signer.PublicKey()[1:]; !bytes.Equal(got, publicKey) {
+				t.Errorf("vector %d: public key = %X, want %s", idx, got, test.publicKey)
+			}
+		}
+
+		if got := VerifySchnorr(publicKey, message, signature); got != test.valid {
+			t.Errorf("vector %d: VerifySchnorr = %t, want %t", idx, got, test.valid)
+		}
+	}
+}
+
+func TestSecp256k1ECDSA(t *testing.T) {
+	key, err := GenerateKey(KeySecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := decodeHex(t, strings.Repeat("AB", 32))
+	sig, err := key.Sign(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !VerifySignature(key.PublicKey(), hash, sig) {
+		t.Fatal("signature does not verify")
+	}
+
+	other := bytes.Clone(hash)
+	other[0] ^= 0x01
+	if VerifySignature(key.PublicKey(), other, sig) {
+		t.Fatal("signature verifies for another hash")
+	}
+
+	// The compressed public key decodes back to the signing key.
+	keyType, decoded, err := DecodePublicKey(key.PublicKey())
+	if err != nil || keyType != KeySecp256k1 {
+		t.Fatalf("DecodePublicKey = %v, %v", keyType, err)
+	}
+	private := key.(ecdsaSigner).key
+	if public := decoded.(*ecdsa.PublicKey); public.X.Cmp(private.X) != 0 || public.Y.Cmp(private.Y) != 0 {
+		t.Fatal("decoded public key differs from the signing key")
+	}
+}
@@ -0,0 +1,243 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// SignECDSA produces a deterministic (RFC 6979) ECDSA signature of hash in
+// the low-S form, DER encoded.
+func SignECDSA(privKey *ecdsa.PrivateKey, hash []byte) ([]byte, error) {
+	params := privKey.Curve.Params()
+	n := params.N
+
+	e := hashToInt(hash, n)
+	nonces := newNonceGenerator(privKey.D, hash, n)
+
+	for {
+		k := nonces.next()
+
+		x, _ := privKey.Curve.ScalarBaseMult(k.Bytes())
+		r := new(big.Int).Mod(x, n)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		s := new(big.Int).Mul(r, privKey.D)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, n))
+		s.Mod(s, n)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		return encodeDER(r, normalizeS(s, n)), nil
+	}
+}
+
+// VerifyECDSA checks a DER encoded signature produced by SignECDSA.
+// Signatures that are not strictly encoded or not in the low-S form are
+// rejected, so a valid signature cannot be altered into another valid one.
+func VerifyECDSA(pubKey *ecdsa.PublicKey, hash, sig []byte) bool {
+	r, s, err := ParseDER(sig)
+	if err != nil {
+		return false
+	}
+
+	n := pubKey.Curve.Params().N
+	if r.Cmp(n) >= 0 || s.Cmp(halfOrder(n)) > 0 {
+		return false
+	}
+
+	return ecdsa.Verify(pubKey, hash, r, s)
+}
+
+// ParseDER strictly decodes a DER signature: a sequence of exactly two
+// minimally encoded positive integers and nothing else.
+func ParseDER(sig []byte) (*big.Int, *big.Int, error) {
+	if len(sig) < 8 || len(sig) > 72 {
+		return nil, nil, fmt.Errorf("signature has invalid length %d", len(sig))
+	}
+	if sig[0] != 0x30 {
+		return nil, nil, fmt.Errorf("signature is not a DER sequence")
+	}
+	if int(sig[1]) != len(sig)-2 {
+		return nil, nil, fmt.Errorf("signature sequence length mismatch")
+	}
+
+	r, rest, err := parseDERInt(sig[2:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("signature r: %w", err)
+	}
+
+	s, rest, err := parseDERInt(rest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signature s: %w", err)
+	}
+
+	if len(rest) != 0 {
+		return nil, nil, fmt.Errorf("signature has trailing bytes")
+	}
+
+	return r, s, nil
+}
+
+func parseDERInt(data []byte) (*big.Int, []byte, error) {
+	if len(data) < 2 || data[0] != 0x02 {
+		return nil, nil, fmt.Errorf("not a DER integer")
+	}
+
+	size := int(data[1])
+	if size == 0 || size > 33 || len(data) < 2+size {
+		return nil, nil, fmt.Errorf("invalid integer length %d", size)
+	}
+
+	value := data[2 : 2+size]
+	if value[0]&0x80 != 0 {
+		return nil, nil, fmt.Errorf("negative integer")
+	}
+	if size > 1 && value[0] == 0 && value[1]&0x80 == 0 {
+		return nil, nil, fmt.Errorf("integer is not minimally encoded")
+	}
+
+	num := new(big.Int).SetBytes(value)
+	if num.Sign() == 0 {
+		return nil, nil, fmt.Errorf("zero integer")
+	}
+
+	return num, data[2+size:], nil
+}
+
+func encodeDER(r, s *big.Int) []byte {
+	rBytes := derIntBytes(r)
+	sBytes := derIntBytes(s)
+
+	var buf bytes.Buffer
+	buf.WriteByte(0x30)
+	buf.WriteByte(byte(4 + len(rBytes) + len(sBytes)))
+	buf.WriteByte(0x02)
+	buf.WriteByte(byte(len(rBytes)))
+	buf.Write(rBytes)
+	buf.WriteByte(0x02)
+	buf.WriteByte(byte(len(sBytes)))
+	buf.Write(sBytes)
+
+	return buf.Bytes()
+}
+
+// derIntBytes returns the minimal big-endian encoding of a positive integer,
+// with a zero byte in front when the high bit would otherwise make it
+// negative.
+func derIntBytes(num *big.Int) []byte {
+	data := num.Bytes()
+	if len(data) == 0 || data[0]&0x80 != 0 {
+		data = append([]byte{0}, data...)
+	}
+
+	return data
+}
+
+func normalizeS(s, n *big.Int) *big.Int {
+	if s.Cmp(halfOrder(n)) > 0 {
+		return new(big.Int).Sub(n, s)
+	}
+
+	return s
+}
+
+func halfOrder(n *big.Int) *big.Int {
+	return new(big.Int).Rsh(n, 1)
+}
+
+// hashToInt converts a hash to an integer as in RFC 6979 bits2int, keeping
+// the leftmost bits when the hash is longer than the group order.
+func hashToInt(hash []byte, n *big.Int) *big.Int {
+	orderBits := n.BitLen()
+	orderBytes := (orderBits + 7) / 8
+	if len(hash) > orderBytes {
+		hash = hash[:orderBytes]
+	}
+
+	num := new(big.Int).SetBytes(hash)
+	if excess := len(hash)*8 - orderBits; excess > 0 {
+		num.Rsh(num, uint(excess))
+	}
+
+	return num
+}
+
+// nonceGenerator derives ECDSA nonces from the private key and message hash
+// with HMAC-SHA256, following RFC 6979 section 3.2.
+type nonceGenerator struct {
+	n *big.Int
+	k []byte
+	v []byte
+}
+
+func newNonceGenerator(d *big.Int, hash []byte, n *big.Int) *nonceGenerator {
+	size := (n.BitLen() + 7) / 8
+
+	h := hashToInt(hash, n)
+	h.Mod(h, n)
+
+	key := intToOctets(d, size)
+	msg := intToOctets(h, size)
+
+	g := &nonceGenerator{
+		n: n,
+		k: make([]byte, sha256.Size),
+		v: bytes.Repeat([]byte{0x01}, sha256.Size),
+	}
+
+	g.k = g.mac(g.v, []byte{0x00}, key, msg)
+	g.v = g.mac(g.v)
+	g.k = g.mac(g.v, []byte{0x01}, key, msg)
+	g.v = g.mac(g.v)
+
+	return g
+}
+
+func (g *nonceGenerator) next() *big.Int {
+	size := (g.n.BitLen() + 7) / 8
+
+	for {
+		t := []byte{}
+		for len(t) < size {
+			g.v = g.mac(g.v)
+			t = append(t, g.v...)
+		}
+
+		k := hashToInt(t, g.n)
+
+		// Prepare the state for another candidate in case this one is out
+		// of range or the caller rejects it.
+		g.k = g.mac(g.v, []byte{0x00})
+		g.v = g.mac(g.v)
+
+		if k.Sign() > 0 && k.Cmp(g.n) < 0 {
+			return k
+		}
+	}
+}
+
+func (g *nonceGenerator) mac(parts ...[]byte) []byte {
+	m := hmac.New(sha256.New, g.k)
+	for _, part := range parts {
+		m.Write(part)
+	}
+
+	return m.Sum(nil)
+}
+
+func intToOctets(num *big.Int, size int) []byte {
+	data := num.Bytes()
+	if len(data) >= size {
+		return data[len(data)-size:]
+	}
+
+	return append(make([]byte, size-len(data)), data...)
+}
@@ -0,0 +1,191 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func hexInt(t *testing.T, s string) *big.Int {
+	t.Helper()
+
+	num, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		t.Fatalf("invalid hex integer %q", s)
+	}
+
+	return num
+}
+
+func privateKey(curve elliptic.Curve, d *big.Int) *ecdsa.PrivateKey {
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(d.Bytes())
+
+	return key
+}
+
+func TestSignECDSAVectors(t *testing.T) {
+	tests := []struct {
+		name    string
+		curve   elliptic.Curve
+		d       string
+		message string
+		k       string
+		r       string
+		s       string
+	}{
+		{
+			// RFC 6979 A.2.5, P-256 with SHA-256.
+			name:    "p256 sample",
+			curve:   elliptic.P256(),
+			d:       "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721",
+			message: "sample",
+			k:       "A6E3C57DD01ABE90086538398355DD4C3B17AA873382B0F24D6129493D8AAD60",
+			r:       "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+			s:       "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+		},
+		{
+			// RFC 6979 A.2.5, P-256 with SHA-256.
+			name:    "p256 test",
+			curve:   elliptic.P256(),
+			d:       "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721",
+			message: "test",
+			k:       "D16B6AE827F17175E040871A1C7EC3500192C4C92677336EC2537ACAEE0008E0",
+			r:       "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+			s:       "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+		},
+		{
+			// The widely used secp256k1 RFC 6979 vector.
+			name:    "secp256k1 satoshi",
+			curve:   Secp256k1(),
+			d:       "1",
+			message: "Satoshi Nakamoto",
+			k:       "8F8A276C19F4149656B280621E358CCE24F5F52542772691EE69063B74F15D15",
+			r:       "934B1EA10A4B3C1757E2B0C017D0B6143CE3C9A7E6A4A49860D7A6AB210EE3D8",
+			s:       "2442CE9D2B916064108014783E923EC36B49743E2FFA1C4496F01A512AAFD9E5",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := privateKey(test.curve, hexInt(t, test.d))
+			n := test.curve.Params().N
+			hash := sha256.Sum256([]byte(test.message))
+
+			k := newNonceGenerator(key.D, hash[:], n).next()
+			if k.Cmp(hexInt(t, test.k)) != 0 {
+				t.Fatalf("nonce = %X, want %s", k, test.k)
+			}
+
+			sig, err := SignECDSA(key, hash[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, s, err := ParseDER(sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The vectors are not normalized; SignECDSA always produces
+			// the low-S form.
+			wantS := normalizeS(hexInt(t, test.s), n)
+			if r.Cmp(hexInt(t, test.r)) != 0 || s.Cmp(wantS) != 0 {
+				t.Fatalf("signature = (%X, %X), want (%s, %X)", r, s, test.r, wantS)
+			}
+
+			if !VerifyECDSA(&key.PublicKey, hash[:], sig) {
+				t.Fatal("signature does not verify")
+			}
+
+			high := encodeDER(r, new(big.Int).Sub(n, s))
+			if VerifyECDSA(&key.PublicKey, hash[:], high) {
+				t.Fatal("high-S signature verifies")
+			}
+		})
+	}
+}
+
+func TestDERRoundTrip(t *testing.T) {
+	zeros := func(count int) string {
+		return strings.Repeat("00", count)
+	}
+
+	tests := []struct {
+		name string
+		r    string
+		s    string
+		der  string
+	}{
+		{
+			name: "high bits set",
+			r:    "8011" + zeros(29) + "01",
+			s:    "FF" + zeros(30) + "01",
+			der:  "3046" + "022100" + "8011" + zeros(29) + "01" + "022100" + "FF" + zeros(30) + "01",
+		},
+		{
+			name: "leading zero bytes",
+			r:    "7F" + zeros(29) + "01",
+			s:    "01",
+			der:  "3024" + "021F" + "7F" + zeros(29) + "01" + "0201" + "01",
+		},
+		{
+			name: "leading zero byte and high bit set",
+			r:    "80" + zeros(29) + "01",
+			s:    "7F",
+			der:  "3025" + "022000" + "80" + zeros(29) + "01" + "0201" + "7F",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, s := hexInt(t, test.r), hexInt(t, test.s)
+			want, _ := hex.DecodeString(test.der)
+
+			der := encodeDER(r, s)
+			if !bytes.Equal(der, want) {
+				t.Fatalf("encodeDER = %X, want %X", der, want)
+			}
+
+			gotR, gotS, err := ParseDER(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotR.Cmp(r) != 0 || gotS.Cmp(s) != 0 {
+				t.Fatalf("ParseDER = (%X, %X), want (%X, %X)", gotR, gotS, r, s)
+			}
+		})
+	}
+}
+
+func TestParseDERRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		der  string
+	}{
+		{"padded integer", "3007" + "02020001" + "020101"},
+		{"negative integer", "3006" + "020180" + "020101"},
+		{"zero integer", "3006" + "020100" + "020101"},
+		{"empty integer", "3006" + "0200" + "02020101"},
+		{"trailing bytes", "3009" + "020101" + "020101" + "000000"},
+		{"sequence length", "3007" + "020101" + "020101"},
+		{"not a sequence", "3106" + "020101" + "020101"},
+		{"not an integer", "3006" + "030101" + "020101"},
+		{"truncated integer", "3006" + "020101" + "020201"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			der, _ := hex.DecodeString(test.der)
+			if _, _, err := ParseDER(der); err == nil {
+				t.Fatalf("ParseDER(%s) succeeded", test.der)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/utils"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// VanityOptions configures a vanity address search.
+type VanityOptions struct {
+	Prefix          string
+	KeyType         KeyType
+	Workers         int
+	CaseInsensitive bool
+	// Progress, when set, is called about every ProgressInterval while the
+	// search runs.
+	Progress         func(VanityProgress)
+	ProgressInterval time.Duration
+}
+
+// VanityProgress reports how far a vanity address search has come.
+type VanityProgress struct {
+	Attempts uint64
+	Elapsed  time.Duration
+	Rate     float64
+	// ETA is the expected time left, based on the average number of
+	// attempts the prefix needs; the actual search may be much shorter or
+	// longer.
+	ETA time.Duration
+}
+
+// FindVanityWallet generates keys on opts.Workers goroutines until one has a
+// Base58Check address starting with opts.Prefix.
+func FindVanityWallet(opts VanityOptions) (*Wallet, error) {
+	if err := ValidateVanityPrefix(opts.Prefix, opts.CaseInsensitive); err != nil {
+		return nil, err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	interval := opts.ProgressInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	var attempts atomic.Uint64
+	found := make(chan *Wallet, 1)
+	failed := make(chan error, 1)
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				signer, err := GenerateKey(opts.KeyType)
+				if err != nil {
+					select {
+					case failed <- err:
+					default:
+					}
+					return
+				}
+				attempts.Add(1)
+
+				w := &Wallet{PrivateKey: signer, PublicKey: signer.PublicKey()}
+				if matchesPrefix(string(w.Address()), opts.Prefix, opts.CaseInsensitive) {
+					select {
+					case found <- w:
+					default:
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	expected := VanityDifficulty(opts.Prefix, opts.CaseInsensitive)
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case w := <-found:
+			return w, nil
+		case err := <-failed:
+			return nil, err
+		case <-ticker.C:
+			if opts.Progress == nil {
+				continue
+			}
+
+			progress := VanityProgress{
+				Attempts: attempts.Load(),
+				Elapsed:  time.Since(start),
+			}
+			progress.Rate = float64(progress.Attempts) / progress.Elapsed.Seconds()
+			if progress.Rate > 0 {
+				left := math.Max(expected-float64(progress.Attempts), 0)
+				progress.ETA = time.Duration(left / progress.Rate * float64(time.Second))
+			}
+
+			opts.Progress(progress)
+		}
+	}
+}
+
+// ValidateVanityPrefix checks that key addresses can start with prefix. The
+// prefix includes the leading character fixed by the version byte, "1".
+func ValidateVanityPrefix(prefix string, caseInsensitive bool) error {
+	if prefix == "" {
+		return fmt.Errorf("vanity prefix is empty")
+	}
+
+	for idx, char := range prefix {
+		if alphabetMatches(char, caseInsensitive) == 0 {
+			return fmt.Errorf("vanity prefix character %q at position %d is not in the Base58 alphabet", char, idx)
+		}
+	}
+
+	first := leadingCharacters(PubKeyHashVersion)
+	if !matchesAny(first, prefix[:1], caseInsensitive) {
+		return fmt.Errorf("addresses start with one of %q, so they cannot start with %q", first, prefix[:1])
+	}
+
+	return nil
+}
+
+// VanityDifficulty returns the average number of keys to try before one
+// matches prefix. The first character is fixed by the version byte and is
+// not counted.
+func VanityDifficulty(prefix string, caseInsensitive bool) float64 {
+	difficulty := 1.0
+
+	for _, char := range prefix[1:] {
+		difficulty *= float64(len(base58Alphabet)) / float64(alphabetMatches(char, caseInsensitive))
+	}
+
+	return difficulty
+}
+
+// leadingCharacters returns the Base58 characters an address with version
+// can start with. The first character depends only on the version and the
+// top bits of the hash, so trying every first hash byte finds them all.
+func leadingCharacters(version byte) string {
+	seen := map[byte]bool{}
+
+	for first := 0; first < 256; first++ {
+		for _, fill := range []byte{0x00, 0xff} {
+			payload := bytes.Repeat([]byte{fill}, 1+addressHashLength+checksumLength)
+			payload[0] = version
+			payload[1] = byte(first)
+
+			seen[utils.Base58Encode(payload)[0]] = true
+		}
+	}
+
+	chars := []byte{}
+	for idx := 0; idx < len(base58Alphabet); idx++ {
+		if seen[base58Alphabet[idx]] {
+			chars = append(chars, base58Alphabet[idx])
+		}
+	}
+
+	return string(chars)
+}
+
+func matchesPrefix(address, prefix string, caseInsensitive bool) bool {
+	if len(address) < len(prefix) {
+		return false
+	}
+	if caseInsensitive {
+		return strings.EqualFold(address[:len(prefix)], prefix)
+	}
+
+	return strings.HasPrefix(address, prefix)
+}
+
+func matchesAny(chars, char string, caseInsensitive bool) bool {
+	if caseInsensitive {
+		return strings.Contains(strings.ToLower(chars), strings.ToLower(char))
+	}
+
+	return strings.Contains(chars, char)
+}
+
+// alphabetMatches counts the Base58 characters that char matches.
+func alphabetMatches(char rune, caseInsensitive bool) int {
+	matches := 0
+
+	for _, c := range base58Alphabet {
+		if c == char || caseInsensitive && strings.EqualFold(string(c), string(char)) {
+			matches++
+		}
+	}
+
+	return matches
+}
@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+)
+
+func TestFindVanityWallet(t *testing.T) {
+	tests := []struct {
+		prefix          string
+		caseInsensitive bool
+	}{
+		{"1a", false},
+		{"1Zz", true},
+	}
+
+	for _, test := range tests {
+		w, err := FindVanityWallet(VanityOptions{
+			Prefix:          test.prefix,
+			KeyType:         KeySecp256k1,
+			Workers:         2,
+			CaseInsensitive: test.caseInsensitive,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", test.prefix, err)
+		}
+
+		address := string(w.Address())
+		start := address[:len(test.prefix)]
+		if start != test.prefix && !(test.caseInsensitive && strings.EqualFold(start, test.prefix)) {
+			t.Fatalf("found %s for prefix %s", address, test.prefix)
+		}
+
+		// The key behind the address signs for it.
+		hash := sha256.Sum256([]byte("vanity"))
+		sig, err := w.PrivateKey.Sign(hash[:])
+		if err != nil || !VerifySignature(w.PublicKey, hash[:], sig) {
+			t.Fatalf("%s: key does not sign for its address: %v", address, err)
+		}
+		parsed, err := ParseAddress(address)
+		if err != nil || !bytes.Equal(parsed.Hash, PublicKeyHash(w.PublicKey)) {
+			t.Fatalf("%s is not the address of the returned key: %v", address, err)
+		}
+	}
+}
+
+func TestValidateVanityPrefix(t *testing.T) {
+	tests := []struct {
+		prefix          string
+		caseInsensitive bool
+		want            string
+	}{
+		{"1abc", false, ""},
+		{"1Love", false, ""},
+		{"1love", false, `character 'l' at position 1`},
+		{"1love", true, ""},
+		{"", false, "empty"},
+		{"10", false, `character '0' at position 1`},
+		{"1abO", false, `character 'O' at position 3`},
+		{"1I", true, ""},
+		{"1_", true, `character '_' at position 1`},
+		{"2abc", false, `cannot start with "2"`},
+		{"abc", true, `cannot start with "a"`},
+	}
+
+	for _, test := range tests {
+		err := ValidateVanityPrefix(test.prefix, test.caseInsensitive)
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("%q rejected: %v", test.prefix, err)
+		case test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)):
+			t.Errorf("%q: got %v, want an error containing %q", test.prefix, err, test.want)
+		}
+	}
+
+	if _, err := FindVanityWallet(VanityOptions{Prefix: "1abO"}); err == nil {
+		t.Error("FindVanityWallet searched for an impossible prefix")
+	}
+}
+
+func TestVanityDifficulty(t *testing.T) {
+	tests := []struct {
+		prefix          string
+		caseInsensitive bool
+		want            float64
+	}{
+		// The leading "1" is fixed by the version byte.
+		{"1", false, 1},
+		{"1a", false, 58},
+		{"1ab", false, 58 * 58},
+		// Both cases of a and b are in the alphabet, but only o and L of
+		// theirs.
+		{"1a", true, 29},
+		{"1ab", true, 29 * 29},
+		{"1o", true, 58},
+		{"1L", true, 58},
+	}
+
+	for _, test := range tests {
+		if got := VanityDifficulty(test.prefix, test.caseInsensitive); got != test.want {
+			t.Errorf("VanityDifficulty(%q, %v) = %v, want %v", test.prefix, test.caseInsensitive, got, test.want)
+		}
+	}
+}
@@ -2,36 +2,49 @@ package wallet
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
 	"log"
 	"math/big"
+	"time"
 
 	"github.com/zivlakmilos/go-blockchain/pkg/utils"
 	"golang.org/x/crypto/ripemd160"
 )
 
 const (
-	checksumLength = 4
-	version        = byte(0x00)
+	checksumLength    = 4
+	PubKeyHashVersion = byte(0x00)
+	ScriptHashVersion = byte(0x05)
 )
 
 type Wallet struct {
-	PrivateKey ecdsa.PrivateKey
+	PrivateKey Signer
 	PublicKey  []byte
+	// Created is when the wallet was added to the wallet file. It is zero
+	// for wallets saved before creation times were recorded.
+	Created time.Time
 }
 
+// PrivateKey is the layout of P256 keys in wallet files written before other
+// key types were supported.
 type PrivateKey struct {
 	D          *big.Int
 	PublicKeyX *big.Int
 	PublicKeyY *big.Int
 }
 
-func NewWallet() *Wallet {
-	private, public := NewKeyPair()
+type walletKey struct {
+	KeyType KeyType
+	Private []byte
+	Created int64
+	// Legacy marks P256 keys first saved in the PrivateKey layout, which
+	// keep the public key encoding and address they had then.
+	Legacy bool
+}
+
+func NewWallet(keyType KeyType) *Wallet {
+	private, public := NewKeyPair(keyType)
 	wallet := Wallet{
 		PrivateKey: private,
 		PublicKey:  public,
@@ -41,9 +54,24 @@ func NewWallet() *Wallet {
 }
 
 func (w *Wallet) Address() []byte {
-	publichHash := PublicKeyHash(w.PublicKey)
+	return KeyAddress(w.PublicKey)
+}
+
+// KeyAddress returns the pay-to-pubkey-hash address of an encoded public
+// key. Keys of every type share the version byte: the key type is part of
+// the hashed key, and locking scripts only hold the hash.
+func KeyAddress(pubKey []byte) []byte {
+	publichHash := PublicKeyHash(pubKey)
+
+	return encodeAddress(PubKeyHashVersion, publichHash)
+}
+
+func ScriptAddress(script []byte) []byte {
+	return encodeAddress(ScriptHashVersion, PublicKeyHash(script))
+}
 
-	versionedHash := append([]byte{version}, publichHash...)
+func encodeAddress(version byte, hash []byte) []byte {
+	versionedHash := append([]byte{version}, hash...)
 	checksum := Checksum(versionedHash)
 
 	fullHash := append(versionedHash, checksum...)
@@ -53,21 +81,21 @@ func (w *Wallet) Address() []byte {
 }
 
 func (w *Wallet) GobEncode() ([]byte, error) {
-	privateKey := &PrivateKey{
-		D:          w.PrivateKey.D,
-		PublicKeyX: w.PrivateKey.X,
-		PublicKeyY: w.PrivateKey.Y,
+	key := &walletKey{
+		KeyType: w.PrivateKey.KeyType(),
+		Private: MarshalPrivateKey(w.PrivateKey),
+	}
+	if !w.Created.IsZero() {
+		key.Created = w.Created.Unix()
+	}
+	if _, ok := w.PrivateKey.(legacyP256Signer); ok {
+		key.Legacy = true
 	}
 
 	var buf bytes.Buffer
 
 	encoder := gob.NewEncoder(&buf)
-	err := encoder.Encode(privateKey)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = buf.Write(w.PublicKey)
+	err := encoder.Encode(key)
 	if err != nil {
 		return nil, err
 	}
@@ -76,52 +104,60 @@ func (w *Wallet) GobEncode() ([]byte, error) {
 }
 
 func (w *Wallet) GobDecode(data []byte) error {
-	buf := bytes.NewBuffer(data)
-	var privateKey PrivateKey
+	var key walletKey
 
-	decoder := gob.NewDecoder(buf)
-	err := decoder.Decode(&privateKey)
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&key); err != nil {
+		return w.decodeLegacy(data)
+	}
+
+	signer, err := ParsePrivateKey(key.KeyType, key.Private)
 	if err != nil {
 		return err
 	}
+	if key.Legacy {
+		signer = legacyP256Signer{signer.(ecdsaSigner)}
+	}
 
-	w.PrivateKey = ecdsa.PrivateKey{
-		D: privateKey.D,
-		PublicKey: ecdsa.PublicKey{
-			X: privateKey.PublicKeyX,
-			Y: privateKey.PublicKeyY,
-		},
+	w.PrivateKey = signer
+	w.PublicKey = signer.PublicKey()
+	if key.Created != 0 {
+		w.Created = time.Unix(key.Created, 0)
 	}
 
-	w.PublicKey = make([]byte, buf.Len())
-	_, err = buf.Read(w.PublicKey)
+	return nil
+}
+
+// decodeLegacy reads a P256 wallet in the PrivateKey layout. The public key
+// stored after it is derived again, in the same legacy encoding, so the
+// wallet keeps its address.
+func (w *Wallet) decodeLegacy(data []byte) error {
+	var privateKey PrivateKey
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&privateKey)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	signer, err := ParsePrivateKey(KeyP256, privateKey.D.Bytes())
+	if err != nil {
+		return err
+	}
 
-func ValidateAddress(address string) bool {
-	pubKeyHash := utils.Base58Decode([]byte(address))
-	currentChecksum := Checksum(pubKeyHash[len(pubKeyHash)-checksumLength:])
-	version := pubKeyHash[0]
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-checksumLength]
-	targetChecksum := Checksum(append([]byte{version}, pubKeyHash...))
+	w.PrivateKey = legacyP256Signer{signer.(ecdsaSigner)}
+	w.PublicKey = w.PrivateKey.PublicKey()
 
-	return bytes.Equal(currentChecksum, targetChecksum)
+	return nil
 }
 
-func NewKeyPair() (ecdsa.PrivateKey, []byte) {
-	curve := elliptic.P256()
-
-	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+func NewKeyPair(keyType KeyType) (Signer, []byte) {
+	private, err := GenerateKey(keyType)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	public := append(private.X.Bytes(), private.Y.Bytes()...)
-	return *private, public
+	return private, private.PublicKey()
 }
 
 func PublicKeyHash(publicKey []byte) []byte {
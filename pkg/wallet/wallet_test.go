@@ -0,0 +1,92 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"testing"
+)
+
+// legacyWalletData encodes key as wallet files did before key types were
+// supported: the PrivateKey layout followed by the raw public key.
+func legacyWalletData(t *testing.T, key *ecdsa.PrivateKey) ([]byte, []byte) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&PrivateKey{D: key.D, PublicKeyX: key.X, PublicKeyY: key.Y})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public := append(key.X.Bytes(), key.Y.Bytes()...)
+	buf.Write(public)
+
+	return buf.Bytes(), public
+}
+
+func TestDecodeLegacyWallet(t *testing.T) {
+	for range 32 {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, public := legacyWalletData(t, key)
+		address := string(encodeAddress(PubKeyHashVersion, PublicKeyHash(public)))
+
+		var w Wallet
+		if err := w.GobDecode(data); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(w.Address()); got != address {
+			t.Fatalf("migrated address = %s, want %s", got, address)
+		}
+
+		// Saved again in the current layout, it keeps the address.
+		encoded, err := w.GobEncode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reloaded Wallet
+		if err := reloaded.GobDecode(encoded); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(reloaded.Address()); got != address {
+			t.Fatalf("reloaded address = %s, want %s", got, address)
+		}
+
+		hash := sha256.Sum256([]byte("legacy"))
+		sig, err := reloaded.PrivateKey.Sign(hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifySignature(reloaded.PublicKey, hash[:], sig) {
+			t.Fatal("signature of a legacy key does not verify")
+		}
+	}
+}
+
+func TestGetWallet(t *testing.T) {
+	wallets := &Wallets{Wallets: map[string]*Wallet{}}
+	address := wallets.AddWallet(KeySecp256k1)
+
+	w, err := wallets.GetWallet(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(w.Address()) != address {
+		t.Fatalf("GetWallet(%s) returned %s", address, w.Address())
+	}
+
+	parsed, _ := ParseAddress(address)
+	if _, err := wallets.GetWallet(parsed.Bech32()); err != nil {
+		t.Fatalf("GetWallet with the Bech32 form: %v", err)
+	}
+
+	if _, err := wallets.GetWallet(string(NewWallet(KeyP256).Address())); err == nil {
+		t.Fatal("GetWallet of an unknown address succeeded")
+	}
+}
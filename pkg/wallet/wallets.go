@@ -3,19 +3,40 @@ package wallet
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/hex"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/utils"
 )
 
-const walletFile = "/tmp/wallets.data"
+var walletFile = utils.DataPath("wallets.data")
 
 type Wallets struct {
-	Wallets map[string]*Wallet
+	Wallets    map[string]*Wallet
+	Scripts    map[string][]byte
+	Aggregates map[string][][]byte
+	// Labels names own addresses, keyed by address.
+	Labels map[string]string
+	// Contacts is the address book of recipients, keyed by label.
+	Contacts map[string]string
+	// MuSigNonces holds the secret nonces of aggregated signatures this
+	// wallet has committed to but not signed yet.
+	MuSigNonces map[string][]byte
 }
 
 func NewWallets() (*Wallets, error) {
 	w := &Wallets{
-		Wallets: map[string]*Wallet{},
+		Wallets:     map[string]*Wallet{},
+		Scripts:     map[string][]byte{},
+		Aggregates:  map[string][][]byte{},
+		Labels:      map[string]string{},
+		Contacts:    map[string]string{},
+		MuSigNonces: map[string][]byte{},
 	}
 
 	err := w.LoadFile()
@@ -29,23 +50,106 @@ func (w *Wallets) GetAllAddresses() []string {
 	for address := range w.Wallets {
 		addresses = append(addresses, address)
 	}
+	sort.Strings(addresses)
 
 	return addresses
 }
 
-func (w *Wallets) GetWallet(address string) Wallet {
-	return *w.Wallets[address]
+func (w *Wallets) GetWallet(address string) (Wallet, error) {
+	wallet, ok := w.Wallets[canonicalAddress(address)]
+	if !ok {
+		return Wallet{}, fmt.Errorf("wallet %s does not exist", address)
+	}
+
+	return *wallet, nil
+}
+
+func (w *Wallets) AddWallet(keyType KeyType) string {
+	return w.Add(NewWallet(keyType))
 }
 
-func (w *Wallets) AddWallet() string {
-	wallet := NewWallet()
+// Add stores an existing wallet, such as one found by FindVanityWallet, and
+// returns its address.
+func (w *Wallets) Add(wallet *Wallet) string {
 	address := string(wallet.Address())
 
+	if wallet.Created.IsZero() {
+		wallet.Created = time.Now()
+	}
 	w.Wallets[address] = wallet
 
 	return address
 }
 
+func (w *Wallets) AddScript(script []byte) string {
+	address := string(ScriptAddress(script))
+
+	w.Scripts[address] = script
+
+	return address
+}
+
+func (w *Wallets) GetScript(address string) ([]byte, bool) {
+	script, ok := w.Scripts[canonicalAddress(address)]
+	return script, ok
+}
+
+// AddAggregate records the Schnorr keys behind an aggregated key and returns
+// the address of the aggregated key.
+func (w *Wallets) AddAggregate(pubKeys [][]byte) (string, error) {
+	aggKey, err := AggregatePublicKeys(pubKeys)
+	if err != nil {
+		return "", err
+	}
+
+	address := string(KeyAddress(aggKey))
+
+	w.Aggregates[address] = pubKeys
+
+	return address, nil
+}
+
+func (w *Wallets) GetAggregate(address string) ([][]byte, bool) {
+	pubKeys, ok := w.Aggregates[canonicalAddress(address)]
+	return pubKeys, ok
+}
+
+func musigNonceKey(pubKey, hash []byte) string {
+	return hex.EncodeToString(pubKey) + "-" + hex.EncodeToString(hash)
+}
+
+// PutMuSigNonce keeps the secret nonce pubKey committed to for signing hash
+// until TakeMuSigNonce.
+func (w *Wallets) PutMuSigNonce(pubKey, hash, secretNonce []byte) {
+	if w.MuSigNonces == nil {
+		w.MuSigNonces = map[string][]byte{}
+	}
+
+	w.MuSigNonces[musigNonceKey(pubKey, hash)] = secretNonce
+}
+
+// TakeMuSigNonce returns the secret nonce pubKey committed to for signing
+// hash and forgets it, so that it is never used for two signatures.
+func (w *Wallets) TakeMuSigNonce(pubKey, hash []byte) ([]byte, bool) {
+	key := musigNonceKey(pubKey, hash)
+
+	secretNonce, ok := w.MuSigNonces[key]
+	delete(w.MuSigNonces, key)
+
+	return secretNonce, ok
+}
+
+// FindWallet returns the wallet holding the private key of pubKey.
+func (w *Wallets) FindWallet(pubKey []byte) (*Wallet, bool) {
+	for _, wallet := range w.Wallets {
+		if bytes.Equal(wallet.PublicKey, pubKey) {
+			return wallet, true
+		}
+	}
+
+	return nil, false
+}
+
 func (w *Wallets) SaveFile() {
 	var content bytes.Buffer
 
@@ -55,6 +159,11 @@ func (w *Wallets) SaveFile() {
 		log.Panic(err)
 	}
 
+	err = os.MkdirAll(filepath.Dir(walletFile), 0755)
+	if err != nil {
+		log.Panic(err)
+	}
+
 	err = os.WriteFile(walletFile, content.Bytes(), 0644)
 	if err != nil {
 		log.Panic(err)
@@ -80,6 +189,21 @@ func (w *Wallets) LoadFile() error {
 	}
 
 	w.Wallets = wallets.Wallets
+	if wallets.Scripts != nil {
+		w.Scripts = wallets.Scripts
+	}
+	if wallets.Aggregates != nil {
+		w.Aggregates = wallets.Aggregates
+	}
+	if wallets.Labels != nil {
+		w.Labels = wallets.Labels
+	}
+	if wallets.Contacts != nil {
+		w.Contacts = wallets.Contacts
+	}
+	if wallets.MuSigNonces != nil {
+		w.MuSigNonces = wallets.MuSigNonces
+	}
 
 	return nil
 }
@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/blockchain"
+)
+
+// Delivery states.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+	// pollInterval is how often Run looks for chain changes it was not
+	// told about.
+	pollInterval = 5 * time.Second
+	// maxAttempts is how often a delivery is tried before it fails. The
+	// retries back off from one second, doubling, so the last one is about
+	// two minutes after the first attempt.
+	maxAttempts = 8
+)
+
+// Delivery is one payload in the delivery log, with the outcome of sending
+// it.
+type Delivery struct {
+	ID          uint64
+	Webhook     string
+	Event       string
+	TxID        string
+	Body        []byte
+	Status      string
+	Attempts    int
+	LastError   string
+	Created     time.Time
+	NextAttempt time.Time
+	Delivered   time.Time
+}
+
+// Deliveries returns the delivery log, oldest first.
+func Deliveries(chain *blockchain.BlockChain) ([]Delivery, error) {
+	deliveries := []Delivery{}
+
+	err := chain.Store.ScanIndex(deliveryPrefix, func(key, value []byte) error {
+		var delivery Delivery
+		err := gob.NewDecoder(bytes.NewReader(value)).Decode(&delivery)
+		if err != nil {
+			return err
+		}
+
+		deliveries = append(deliveries, delivery)
+		return nil
+	})
+
+	return deliveries, err
+}
+
+// Deliver sends the pending deliveries that are due, and returns when the
+// next one left pending is due, or the zero Time when none is.
+func (n *Notifier) Deliver(ctx context.Context) (time.Time, error) {
+	deliveries, err := Deliveries(n.chain)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var next time.Time
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		if delivery.Status != StatusPending {
+			continue
+		}
+
+		if time.Now().Before(delivery.NextAttempt) {
+			next = earliest(next, delivery.NextAttempt)
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return next, ctx.Err()
+		}
+
+		n.attempt(ctx, delivery)
+
+		err := n.saveDelivery(delivery)
+		if err != nil {
+			return next, err
+		}
+
+		if delivery.Status == StatusPending {
+			next = earliest(next, delivery.NextAttempt)
+		}
+	}
+
+	return next, nil
+}
+
+func (n *Notifier) attempt(ctx context.Context, delivery *Delivery) {
+	hook, err := Get(n.chain, delivery.Webhook)
+	if err != nil {
+		delivery.Status = StatusFailed
+		delivery.LastError = err.Error()
+		return
+	}
+
+	err = n.post(ctx, hook, delivery)
+	delivery.Attempts++
+
+	switch {
+	case err == nil:
+		delivery.Status = StatusDelivered
+		delivery.LastError = ""
+		delivery.Delivered = time.Now().UTC()
+	case delivery.Attempts >= maxAttempts:
+		delivery.Status = StatusFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttempt = time.Now().UTC().Add(n.backoff(delivery.Attempts))
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, hook *Webhook, delivery *Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, delivery.Body))
+	req.Header.Set("X-Webhook-Id", hook.ID)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(delivery.ID, 10))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", hook.URL, resp.Status)
+	}
+
+	return nil
+}
+
+// retryDelay returns how long to wait after the given number of failed
+// attempts.
+func retryDelay(attempts int) time.Duration {
+	return time.Second << (attempts - 1)
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || b.Before(a) {
+		return b
+	}
+
+	return a
+}
+
+// saveDelivery stores the outcome of an attempt, holding the lock so that
+// it does not write while the chain is changed.
+func (n *Notifier) saveDelivery(delivery *Delivery) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.chain.Store.Update(func(batch blockchain.StoreBatch) error {
+		return putDelivery(batch, delivery)
+	})
+}
+
+func putDelivery(batch blockchain.StoreBatch, delivery *Delivery) error {
+	var encoded bytes.Buffer
+	err := gob.NewEncoder(&encoded).Encode(delivery)
+	if err != nil {
+		return err
+	}
+
+	return batch.PutIndex(deliveryKey(delivery.ID), encoded.Bytes())
+}
@@ -0,0 +1,548 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/blockchain"
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// Notifier finds the activity of the webhooks' addresses and delivers it.
+type Notifier struct {
+	chain *blockchain.BlockChain
+	// lock keeps the chain from changing while it is searched.
+	lock   sync.Locker
+	client *http.Client
+	// backoff returns how long to wait after the given number of failed
+	// attempts.
+	backoff func(attempts int) time.Duration
+	// poll is how often Run checks the tip and the mempool for changes it
+	// was not told about.
+	poll time.Duration
+}
+
+// sighting is a transaction a webhook has reported and not yet seen
+// confirmed.
+type sighting struct {
+	TxID     []byte
+	Received int
+	Sent     int
+	// Height is -1 while the transaction is in the mempool.
+	Height    int
+	BlockHash []byte
+}
+
+// NewNotifier returns a notifier for chain, which holds lock while reading
+// the chain.
+func NewNotifier(chain *blockchain.BlockChain, lock sync.Locker) *Notifier {
+	return &Notifier{
+		chain:   chain,
+		lock:    lock,
+		client:  &http.Client{Timeout: requestTimeout},
+		backoff: retryDelay,
+		poll:    pollInterval,
+	}
+}
+
+// Run syncs and delivers until ctx is done, waking up when the chain changes
+// and when a failed delivery is due to be retried. Besides the chain's
+// events, it polls the tip and the mempool, so that it also finds the
+// changes made without them.
+func (n *Notifier) Run(ctx context.Context) {
+	events := n.chain.Events().Subscribe(1, blockchain.DropOnOverflow)
+	defer events.Close()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	ticker := time.NewTicker(n.poll)
+	defer ticker.Stop()
+
+	synced := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-events.Events():
+		case <-timer.C:
+		case <-ticker.C:
+			state, err := n.state()
+			if err != nil {
+				log.Printf("webhook: %v", err)
+			}
+			if err != nil || state == synced {
+				continue
+			}
+		}
+
+		state, err := n.state()
+		if err == nil {
+			err = n.sync()
+		}
+		if err != nil {
+			log.Printf("webhook: %v", err)
+		} else {
+			synced = state
+		}
+
+		next, err := n.Deliver(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("webhook: %v", err)
+		}
+
+		timer.Stop()
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// state sums up the stored tip and the mempool, which change whenever the
+// chain does.
+func (n *Notifier) state() (string, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	tip, err := n.chain.Store.Tip()
+	if err != nil {
+		return "", err
+	}
+
+	ids := []string{hex.EncodeToString(tip)}
+	for _, tx := range n.chain.MempoolTransactions() {
+		ids = append(ids, hex.EncodeToString(tx.ID))
+	}
+	sort.Strings(ids)
+
+	return fmt.Sprint(ids), nil
+}
+
+// DeliverPending syncs once and delivers until no delivery is pending any
+// more or ctx is done. Deliveries still pending then stay queued for the next
+// run.
+func (n *Notifier) DeliverPending(ctx context.Context) error {
+	err := n.sync()
+	if err != nil {
+		return err
+	}
+
+	for {
+		next, err := n.Deliver(ctx)
+		if err != nil || next.IsZero() {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+func (n *Notifier) sync() error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.Sync()
+}
+
+// Sync searches the blocks added since the last sync and the mempool for the
+// activity of every webhook, and queues the deliveries it calls for. Blocks
+// pruned before they were searched are skipped. The caller keeps the chain
+// from changing meanwhile.
+func (n *Notifier) Sync() error {
+	hooks, err := List(n.chain)
+	if err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	mempool := n.chain.MempoolTransactions()
+	for i := range hooks {
+		err = n.syncHook(&hooks[i], mempool)
+		if err != nil {
+			return fmt.Errorf("webhook %s: %w", hooks[i].ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (n *Notifier) syncHook(hook *Webhook, mempool []*blockchain.Transaction) error {
+	address, err := wallet.ParseAddress(hook.Address)
+	if err != nil {
+		return err
+	}
+	lock := blockchain.LockingScript(address)
+	best := n.chain.GetBestHeight()
+
+	owned, err := n.loadOwned(hook.ID)
+	if err != nil {
+		return err
+	}
+	seen, err := n.loadSeen(hook.ID)
+	if err != nil {
+		return err
+	}
+
+	// Keys whose value changed, written out at the end.
+	changedOwned := map[string]bool{}
+	changedSeen := map[string]bool{}
+	payloads := []Payload{}
+
+	// activity returns what tx moves to and from the address. The outputs
+	// the address owns only change once tx is mined.
+	activity := func(tx *blockchain.Transaction, mined bool) (*sighting, bool) {
+		found := &sighting{TxID: tx.ID, Height: -1}
+		matched := false
+
+		if !tx.IsCoinbase() {
+			for _, txIn := range tx.Inputs {
+				key := string(ownedKey(hook.ID, txIn.ID, txIn.Out))
+				if value, ok := owned[key]; ok {
+					found.Sent += value
+					matched = true
+					if mined {
+						delete(owned, key)
+						changedOwned[key] = true
+					}
+				}
+			}
+		}
+
+		for idx, txOut := range tx.Outputs {
+			if txOut.IsLockedWith(lock) {
+				found.Received += txOut.Value
+				matched = true
+				if mined {
+					key := string(ownedKey(hook.ID, tx.ID, idx))
+					owned[key] = txOut.Value
+					changedOwned[key] = true
+				}
+			}
+		}
+
+		return found, matched
+	}
+
+	// Blocks searched before may have been disconnected since. Their
+	// activity is undone and the new blocks are searched from where the
+	// chain forked.
+	fork, err := n.forkHeight(hook)
+	if err != nil {
+		return err
+	}
+	if fork < hook.ScannedHeight {
+		err = n.ownedAt(hook.ID, lock, owned, fork, changedOwned)
+		if err != nil {
+			return err
+		}
+
+		for key, reported := range seen {
+			if reported.Height > fork {
+				reported.Height = -1
+				reported.BlockHash = nil
+				changedSeen[key] = true
+			}
+		}
+	}
+
+	from := max(fork+1, n.chain.PrunedHeight()+1)
+	if from <= best {
+		it := n.chain.RangeIterator(from, best)
+		for block := range it.All() {
+			for _, tx := range block.Transactions {
+				found, matched := activity(tx, true)
+				if !matched {
+					continue
+				}
+
+				key := string(seenKey(hook.ID, tx.ID))
+				reported, ok := seen[key]
+				if !ok {
+					reported = found
+					seen[key] = reported
+				}
+				reported.Height = block.Height
+				reported.BlockHash = block.Hash
+				changedSeen[key] = true
+
+				if !ok {
+					payloads = append(payloads, newPayload(hook, reported, activityEvent(reported), best))
+				}
+			}
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+	}
+
+	inMempool := map[string]bool{}
+	for _, tx := range mempool {
+		key := string(seenKey(hook.ID, tx.ID))
+		inMempool[key] = true
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		found, matched := activity(tx, false)
+		if !matched {
+			continue
+		}
+
+		seen[key] = found
+		changedSeen[key] = true
+		payloads = append(payloads, newPayload(hook, found, activityEvent(found), best))
+	}
+
+	keys := []string{}
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		reported := seen[key]
+		switch {
+		case reported.Height < 0:
+			if !inMempool[key] {
+				// Dropped from the mempool.
+				delete(seen, key)
+				changedSeen[key] = true
+			}
+		case hook.Confirmations == 0:
+			delete(seen, key)
+			changedSeen[key] = true
+		case best-reported.Height+1 >= hook.Confirmations:
+			payloads = append(payloads, newPayload(hook, reported, EventConfirmed, best))
+			delete(seen, key)
+			changedSeen[key] = true
+		}
+	}
+
+	hook.ScannedHeight = best
+	hook.ScannedHash = n.chain.LastHash
+
+	return n.chain.Store.Update(func(batch blockchain.StoreBatch) error {
+		for key := range changedOwned {
+			var err error
+			if value, ok := owned[key]; ok {
+				err = batch.PutIndex([]byte(key), encodeInt(value))
+			} else {
+				err = batch.DeleteIndex([]byte(key))
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		for key := range changedSeen {
+			var err error
+			if reported, ok := seen[key]; ok {
+				var encoded bytes.Buffer
+				err = gob.NewEncoder(&encoded).Encode(reported)
+				if err == nil {
+					err = batch.PutIndex([]byte(key), encoded.Bytes())
+				}
+			} else {
+				err = batch.DeleteIndex([]byte(key))
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		err := queueDeliveries(batch, hook, payloads)
+		if err != nil {
+			return err
+		}
+
+		return putHook(batch, hook)
+	})
+}
+
+// forkHeight returns the height of the last block searched for hook that is
+// still on the chain.
+func (n *Notifier) forkHeight(hook *Webhook) (int, error) {
+	height := hook.ScannedHeight
+
+	for hash := hook.ScannedHash; len(hash) > 0; {
+		header, err := n.chain.GetHeader(hash)
+		if err != nil {
+			return 0, fmt.Errorf("last searched block %x: %w", hash, err)
+		}
+		if n.chain.OnChain(header) {
+			break
+		}
+
+		height = header.Height - 1
+		hash = header.PrevHash
+	}
+
+	return height, nil
+}
+
+// ownedAt sets owned to the outputs of the webhook's address at height on
+// the current chain: the UTXO set at the tip without what the later blocks
+// did to it. Changed keys are added to changed.
+func (n *Notifier) ownedAt(id string, lock blockchain.Script, owned map[string]int, height int, changed map[string]bool) error {
+	for key := range owned {
+		delete(owned, key)
+		changed[key] = true
+	}
+	for _, utxo := range n.chain.FindUnspentOutputs(lock) {
+		key := string(ownedKey(id, utxo.TxID, utxo.Index))
+		owned[key] = utxo.Output.Value
+		changed[key] = true
+	}
+
+	for later := n.chain.GetBestHeight(); later > height; later-- {
+		header, err := n.chain.GetHeaderByHeight(later)
+		if err != nil {
+			return err
+		}
+		block, err := n.chain.GetBlock(header.Hash)
+		if err != nil {
+			return err
+		}
+		spent, err := n.chain.SpentOutputs(header.Hash)
+		if err != nil {
+			return err
+		}
+
+		// Last transaction first, so outputs created and spent in the same
+		// block end up gone.
+		for txIdx := len(block.Transactions) - 1; txIdx >= 0; txIdx-- {
+			tx := block.Transactions[txIdx]
+
+			for idx := range tx.Outputs {
+				delete(owned, string(ownedKey(id, tx.ID, idx)))
+			}
+
+			for inIdx, txOut := range spent[txIdx] {
+				if txOut.IsLockedWith(lock) {
+					txIn := tx.Inputs[inIdx]
+					owned[string(ownedKey(id, txIn.ID, txIn.Out))] = txOut.Value
+				}
+			}
+		}
+	}
+
+	for key := range owned {
+		changed[key] = true
+	}
+
+	return nil
+}
+
+func (n *Notifier) loadOwned(id string) (map[string]int, error) {
+	owned := map[string]int{}
+
+	err := n.chain.Store.ScanIndex(hookScope(ownedPrefix, id), func(key, value []byte) error {
+		owned[string(key)] = decodeInt(value)
+		return nil
+	})
+
+	return owned, err
+}
+
+func (n *Notifier) loadSeen(id string) (map[string]*sighting, error) {
+	seen := map[string]*sighting{}
+
+	err := n.chain.Store.ScanIndex(hookScope(seenPrefix, id), func(key, value []byte) error {
+		var reported sighting
+		err := gob.NewDecoder(bytes.NewReader(value)).Decode(&reported)
+		if err != nil {
+			return err
+		}
+
+		seen[string(key)] = &reported
+		return nil
+	})
+
+	return seen, err
+}
+
+// queueDeliveries numbers payloads and stores them as pending deliveries.
+func queueDeliveries(batch blockchain.StoreBatch, hook *Webhook, payloads []Payload) error {
+	if len(payloads) == 0 {
+		return nil
+	}
+
+	var seq uint64
+	data, err := batch.GetIndex(deliverySeqKey)
+	if err == nil {
+		seq = binary.BigEndian.Uint64(data)
+	} else if err != blockchain.ErrNotFound {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, payload := range payloads {
+		seq++
+		payload.Delivery = seq
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+
+		err = putDelivery(batch, &Delivery{
+			ID:          seq,
+			Webhook:     hook.ID,
+			Event:       payload.Event,
+			TxID:        payload.TxID,
+			Body:        body,
+			Status:      StatusPending,
+			Created:     now,
+			NextAttempt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return batch.PutIndex(deliverySeqKey, binary.BigEndian.AppendUint64(nil, seq))
+}
+
+func newPayload(hook *Webhook, reported *sighting, event string, best int) Payload {
+	payload := Payload{
+		Webhook:   hook.ID,
+		Event:     event,
+		Address:   hook.Address,
+		TxID:      hex.EncodeToString(reported.TxID),
+		Received:  reported.Received,
+		Sent:      reported.Sent,
+		Height:    reported.Height,
+		Timestamp: time.Now().Unix(),
+	}
+
+	if reported.Height >= 0 {
+		payload.BlockHash = hex.EncodeToString(reported.BlockHash)
+		payload.Confirmations = best - reported.Height + 1
+	}
+
+	return payload
+}
+
+func activityEvent(reported *sighting) string {
+	if reported.Sent > 0 {
+		return EventSpent
+	}
+
+	return EventReceived
+}
@@ -0,0 +1,411 @@
+package webhook
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/blockchain"
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// receiver is a webhook endpoint that checks signatures and answers the
+// first failures requests with an error.
+type receiver struct {
+	t        *testing.T
+	secret   []byte
+	mu       sync.Mutex
+	failures int
+	requests int
+	payloads []Payload
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		r.t.Error(err)
+		return
+	}
+	if !Verify(r.secret, body, req.Header.Get(SignatureHeader)) {
+		r.t.Errorf("payload %s has a bad signature", body)
+	}
+
+	r.requests++
+	if r.failures > 0 {
+		r.failures--
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		r.t.Error(err)
+	}
+	r.payloads = append(r.payloads, payload)
+}
+
+func (r *receiver) events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := []string{}
+	for _, payload := range r.payloads {
+		events = append(events, payload.Event)
+	}
+
+	return events
+}
+
+func newAddress() string {
+	return string(wallet.NewWallet(wallet.KeyP256).Address())
+}
+
+// watch registers a webhook for address on chain, served by a new receiver.
+func watch(t *testing.T, chain *blockchain.BlockChain, address string, confirmations, failures int) *receiver {
+	t.Helper()
+
+	r := &receiver{t: t, failures: failures}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	hook, err := Add(chain, address, server.URL, confirmations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.secret = hook.Secret
+
+	return r
+}
+
+func newNotifier(chain *blockchain.BlockChain) *Notifier {
+	n := NewNotifier(chain, &sync.Mutex{})
+	n.backoff = func(attempts int) time.Duration { return time.Millisecond }
+
+	return n
+}
+
+func deliverPending(t *testing.T, n *Notifier) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := n.DeliverPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"event":"received"}`)
+	signature := Sign(secret, body)
+
+	if !Verify(secret, body, signature) {
+		t.Fatal("signature does not verify")
+	}
+	if Verify([]byte("other"), body, signature) {
+		t.Error("signature verifies with another secret")
+	}
+	if Verify(secret, []byte(`{"event":"spent"}`), signature) {
+		t.Error("signature verifies for another body")
+	}
+}
+
+func TestNotifierDelivers(t *testing.T) {
+	chain, err := blockchain.NewBlockChain(blockchain.NewMemoryStore(), newAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	payee := newAddress()
+	r := watch(t, chain, payee, 0, 0)
+	n := newNotifier(chain)
+
+	block := chain.MineBlock(payee)
+	deliverPending(t, n)
+
+	if len(r.payloads) != 1 {
+		t.Fatalf("received %d payloads, want 1", len(r.payloads))
+	}
+	payload := r.payloads[0]
+	if payload.Event != EventReceived || payload.Address != payee || payload.Received != blockchain.BlockSubsidy || payload.Height != block.Height {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+
+	// Nothing new, nothing sent.
+	deliverPending(t, n)
+	if len(r.payloads) != 1 {
+		t.Fatalf("received %d payloads after a sync without activity, want 1", len(r.payloads))
+	}
+}
+
+func TestNotifierRun(t *testing.T) {
+	chain, err := blockchain.NewBlockChain(blockchain.NewMemoryStore(), newAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	payee := newAddress()
+	r := watch(t, chain, payee, 2, 0)
+	n := newNotifier(chain)
+	n.poll = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Each block wakes the notifier, without anyone calling it.
+	blocks := []struct {
+		to    string
+		event string
+	}{
+		{payee, EventReceived},
+		{newAddress(), EventConfirmed},
+	}
+
+	want := []string{}
+	for _, block := range blocks {
+		chain.MineBlock(block.to)
+		want = append(want, block.event)
+
+		deadline := time.Now().Add(5 * time.Second)
+		for !slices.Equal(r.events(), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("got events %v, want %v", r.events(), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestNotifierRetries(t *testing.T) {
+	chain, err := blockchain.NewBlockChain(blockchain.NewMemoryStore(), newAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	payee := newAddress()
+	r := watch(t, chain, payee, 0, 2)
+
+	n := newNotifier(chain)
+	delays := []int{}
+	n.backoff = func(attempts int) time.Duration {
+		delays = append(delays, attempts)
+		return time.Millisecond
+	}
+
+	chain.MineBlock(payee)
+	deliverPending(t, n)
+
+	if !slices.Equal(r.events(), []string{EventReceived}) || r.requests != 3 {
+		t.Fatalf("got events %v after %d requests, want [received] after 3", r.events(), r.requests)
+	}
+	if len(delays) != 2 || delays[0] != 1 || delays[1] != 2 {
+		t.Fatalf("backed off after attempts %v, want [1 2]", delays)
+	}
+
+	deliveries, err := Deliveries(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != StatusDelivered || deliveries[0].Attempts != 3 {
+		t.Fatalf("delivery log %+v, want one delivered after 3 attempts", deliveries)
+	}
+}
+
+func TestNotifierGivesUp(t *testing.T) {
+	chain, err := blockchain.NewBlockChain(blockchain.NewMemoryStore(), newAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	payee := newAddress()
+	r := watch(t, chain, payee, 0, maxAttempts)
+	n := newNotifier(chain)
+
+	chain.MineBlock(payee)
+	deliverPending(t, n)
+
+	deliveries, err := Deliveries(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != StatusFailed || deliveries[0].Attempts != maxAttempts {
+		t.Fatalf("delivery log %+v, want one failed after %d attempts", deliveries, maxAttempts)
+	}
+	if r.requests != maxAttempts {
+		t.Fatalf("endpoint got %d requests, want %d", r.requests, maxAttempts)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second} {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestNotifierConfirmations(t *testing.T) {
+	funder := newAddress()
+	chain, err := blockchain.NewBlockChain(blockchain.NewMemoryStore(), funder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	payee := newAddress()
+	r := watch(t, chain, payee, 3, 0)
+	n := newNotifier(chain)
+
+	block := chain.MineBlock(payee)
+	deliverPending(t, n)
+	want := []string{EventReceived}
+
+	for confirmations := 2; confirmations <= 4; confirmations++ {
+		chain.MineBlock(funder)
+		deliverPending(t, n)
+
+		if confirmations == 3 {
+			want = append(want, EventConfirmed)
+		}
+		if !slices.Equal(r.events(), want) {
+			t.Fatalf("at %d confirmations got events %v, want %v", confirmations, r.events(), want)
+		}
+	}
+
+	confirmed := r.payloads[1]
+	if confirmed.Confirmations != 3 || confirmed.Height != block.Height || confirmed.TxID != r.payloads[0].TxID {
+		t.Fatalf("unexpected confirmation payload %+v", confirmed)
+	}
+}
+
+func TestDeliveriesPersist(t *testing.T) {
+	path := t.TempDir()
+
+	store, err := blockchain.NewBadgerStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := blockchain.NewBlockChain(store, newAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payee := newAddress()
+	r := watch(t, chain, payee, 0, 1)
+
+	// One failed attempt, then the node stops.
+	chain.MineBlock(payee)
+	ctx, cancel := context.WithCancel(context.Background())
+	n := newNotifier(chain)
+	n.backoff = func(attempts int) time.Duration {
+		cancel()
+		return 0
+	}
+	if err := n.DeliverPending(ctx); err != context.Canceled {
+		t.Fatalf("DeliverPending = %v, want context.Canceled", err)
+	}
+	if err := chain.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = blockchain.NewBadgerStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err = blockchain.OpenBlockChain(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	deliveries, err := Deliveries(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != StatusPending || deliveries[0].Attempts != 1 {
+		t.Fatalf("delivery log after a reopen %+v, want one pending after 1 attempt", deliveries)
+	}
+
+	deliverPending(t, newNotifier(chain))
+
+	if !slices.Equal(r.events(), []string{EventReceived}) || r.payloads[0].Delivery != deliveries[0].ID {
+		t.Fatalf("got payloads %+v after a reopen, want delivery %d", r.payloads, deliveries[0].ID)
+	}
+
+	deliveries, err = Deliveries(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != StatusDelivered || deliveries[0].Attempts != 2 {
+		t.Fatalf("delivery log %+v, want one delivered after 2 attempts", deliveries)
+	}
+}
+
+func TestNotifierDisconnect(t *testing.T) {
+	funder := newAddress()
+	chain, err := blockchain.NewBlockChain(blockchain.NewMemoryStore(), funder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	payee := newAddress()
+	r := watch(t, chain, payee, 2, 0)
+	n := newNotifier(chain)
+
+	chain.MineBlock(payee)
+	deliverPending(t, n)
+
+	// The payment is disconnected and replaced by a block paying someone
+	// else, so it is never confirmed.
+	if _, err := chain.DisconnectBlock(); err != nil {
+		t.Fatal(err)
+	}
+	chain.MineBlock(funder)
+	chain.MineBlock(funder)
+	deliverPending(t, n)
+
+	if !slices.Equal(r.events(), []string{EventReceived}) {
+		t.Fatalf("got events %v after the disconnect, want [received]", r.events())
+	}
+
+	// A payment in a block at the same height is seen and confirmed.
+	if _, err := chain.DisconnectBlock(); err != nil {
+		t.Fatal(err)
+	}
+	block := chain.MineBlock(payee)
+	deliverPending(t, n)
+	chain.MineBlock(funder)
+	deliverPending(t, n)
+
+	want := []string{EventReceived, EventReceived, EventConfirmed}
+	if !slices.Equal(r.events(), want) {
+		t.Fatalf("got events %v, want %v", r.events(), want)
+	}
+	if confirmed := r.payloads[2]; confirmed.Height != block.Height || confirmed.BlockHash != hex.EncodeToString(block.Hash) {
+		t.Fatalf("unexpected confirmation payload %+v", confirmed)
+	}
+}
@@ -0,0 +1,247 @@
+// Package webhook POSTs the activity of watched addresses to HTTP endpoints.
+// Webhooks, what they have seen and the log of every delivery are kept in the
+// index of the chain store, so nothing is lost between runs and pending
+// deliveries are retried the next time a Notifier runs.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/zivlakmilos/go-blockchain/pkg/blockchain"
+	"github.com/zivlakmilos/go-blockchain/pkg/wallet"
+)
+
+// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the request
+// body, keyed with the webhook's secret.
+const SignatureHeader = "X-Webhook-Signature"
+
+// Events a payload reports.
+const (
+	EventReceived  = "received"
+	EventSpent     = "spent"
+	EventConfirmed = "confirmed"
+)
+
+var (
+	hookPrefix     = []byte("webhook-hook-")
+	ownedPrefix    = []byte("webhook-owned-")
+	seenPrefix     = []byte("webhook-seen-")
+	deliveryPrefix = []byte("webhook-delivery-")
+	deliverySeqKey = []byte("webhook-seq")
+)
+
+// Webhook watches one address. A payload is sent when a transaction paying
+// to or spending from it is first seen, in the mempool or in a block, and
+// again once it has Confirmations confirmations, unless that is 0.
+type Webhook struct {
+	ID            string
+	Address       string
+	URL           string
+	Confirmations int
+	Secret        []byte
+	Created       time.Time
+	// ScannedHeight and ScannedHash are the last block searched for
+	// activity.
+	ScannedHeight int
+	ScannedHash   []byte
+}
+
+// Payload is the JSON body POSTed to a webhook.
+type Payload struct {
+	Delivery      uint64 `json:"delivery"`
+	Webhook       string `json:"webhook"`
+	Event         string `json:"event"`
+	Address       string `json:"address"`
+	TxID          string `json:"txid"`
+	Received      int    `json:"received"`
+	Sent          int    `json:"sent"`
+	BlockHash     string `json:"blockHash,omitempty"`
+	Height        int    `json:"height"`
+	Confirmations int    `json:"confirmations"`
+	Timestamp     int64  `json:"timestamp"`
+}
+
+// Add registers a webhook for address. Only activity after it is added is
+// reported.
+func Add(chain *blockchain.BlockChain, address, target string, confirmations int) (*Webhook, error) {
+	parsed, err := wallet.ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook URL must be an http or https URL")
+	}
+
+	if confirmations < 0 {
+		return nil, fmt.Errorf("confirmations must not be negative")
+	}
+
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	hook := &Webhook{
+		ID:            hex.EncodeToString(id),
+		Address:       parsed.String(),
+		URL:           target,
+		Confirmations: confirmations,
+		Secret:        secret,
+		Created:       time.Now().UTC(),
+		ScannedHeight: chain.GetBestHeight(),
+		ScannedHash:   chain.LastHash,
+	}
+
+	// Spends are recognized by the outputs the address owns.
+	owned := chain.FindUnspentOutputs(blockchain.LockingScript(parsed))
+
+	err = chain.Store.Update(func(batch blockchain.StoreBatch) error {
+		for _, utxo := range owned {
+			err := batch.PutIndex(ownedKey(hook.ID, utxo.TxID, utxo.Index), encodeInt(utxo.Output.Value))
+			if err != nil {
+				return err
+			}
+		}
+
+		return putHook(batch, hook)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+// Remove deletes a webhook and what it has seen. Its deliveries stay in the
+// log; pending ones fail.
+func Remove(chain *blockchain.BlockChain, id string) error {
+	if _, err := Get(chain, id); err != nil {
+		return err
+	}
+
+	keys := [][]byte{hookKey(id)}
+	for _, prefix := range [][]byte{ownedPrefix, seenPrefix} {
+		err := chain.Store.ScanIndex(hookScope(prefix, id), func(key, value []byte) error {
+			keys = append(keys, bytes.Clone(key))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return chain.Store.Update(func(batch blockchain.StoreBatch) error {
+		for _, key := range keys {
+			err := batch.DeleteIndex(key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Get returns the webhook with id.
+func Get(chain *blockchain.BlockChain, id string) (*Webhook, error) {
+	data, err := chain.Store.GetIndex(hookKey(id))
+	if err == blockchain.ErrNotFound {
+		return nil, fmt.Errorf("webhook %s does not exist", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var hook Webhook
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&hook)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hook, nil
+}
+
+// List returns the webhooks, ordered by ID.
+func List(chain *blockchain.BlockChain) ([]Webhook, error) {
+	hooks := []Webhook{}
+
+	err := chain.Store.ScanIndex(hookPrefix, func(key, value []byte) error {
+		var hook Webhook
+		err := gob.NewDecoder(bytes.NewReader(value)).Decode(&hook)
+		if err != nil {
+			return err
+		}
+
+		hooks = append(hooks, hook)
+		return nil
+	})
+
+	return hooks, err
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the SignatureHeader value for body,
+// for receivers checking that a payload came from the node.
+func Verify(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+func putHook(batch blockchain.StoreBatch, hook *Webhook) error {
+	var encoded bytes.Buffer
+	err := gob.NewEncoder(&encoded).Encode(hook)
+	if err != nil {
+		return err
+	}
+
+	return batch.PutIndex(hookKey(hook.ID), encoded.Bytes())
+}
+
+func hookKey(id string) []byte {
+	return append(bytes.Clone(hookPrefix), id...)
+}
+
+// hookScope returns the prefix of the keys of one webhook under prefix.
+func hookScope(prefix []byte, id string) []byte {
+	return append(append(bytes.Clone(prefix), id...), '-')
+}
+
+func ownedKey(id string, txID []byte, out int) []byte {
+	return binary.BigEndian.AppendUint32(append(hookScope(ownedPrefix, id), txID...), uint32(out))
+}
+
+func seenKey(id string, txID []byte) []byte {
+	return append(hookScope(seenPrefix, id), txID...)
+}
+
+func deliveryKey(seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(bytes.Clone(deliveryPrefix), seq)
+}
+
+func encodeInt(value int) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(value))
+}
+
+func decodeInt(data []byte) int {
+	return int(binary.BigEndian.Uint64(data))
+}